@@ -0,0 +1,30 @@
+"""Tests for the embedding LRU cache — pure Python, no embedding model needed."""
+
+from __future__ import annotations
+
+import pytest
+
+from vecfs_embed.cache import EmbeddingCache
+
+
+class TestEmbeddingCache:
+    def test_returns_stored_vector(self) -> None:
+        cache = EmbeddingCache(2)
+        cache.put("a", [1.0])
+        assert cache.get("a") == [1.0]
+        assert cache.get("b") is None
+
+    def test_evicts_least_recently_used_at_capacity(self) -> None:
+        cache = EmbeddingCache(2)
+        cache.put("a", [1.0])
+        cache.put("b", [2.0])
+        cache.get("a")
+        cache.put("c", [3.0])
+        assert len(cache) == 2
+        assert cache.get("b") is None
+        assert cache.get("a") == [1.0]
+        assert cache.get("c") == [3.0]
+
+    def test_rejects_zero_size(self) -> None:
+        with pytest.raises(ValueError):
+            EmbeddingCache(0)
@@ -0,0 +1,421 @@
+"""Tests for the embed module using a fake embedder — no model download needed."""
+
+from __future__ import annotations
+
+from dataclasses import dataclass
+from typing import Sequence
+
+import pytest
+
+from vecfs_embed import embed as embed_module
+from vecfs_embed import retry as retry_module
+from vecfs_embed.cache import EmbeddingCache
+from vecfs_embed.embed import (
+    WARMUP_TEXT,
+    embed_batch,
+    embed_expanded_query,
+    embed_single,
+    warm_up,
+)
+from vecfs_embed.sparsify import sparse_mean
+
+MODEL = "fake:model"
+
+
+@dataclass
+class _FakeResult:
+    embeddings: list[list[float]]
+
+
+class _RecordingEmbedder:
+    """Deterministic stand-in that derives a dense vector from each text."""
+
+    def __init__(self) -> None:
+        self.calls: list[tuple[str, list[str]]] = []
+
+    @staticmethod
+    def _vector(text: str) -> list[float]:
+        dense = [0.0] * 8
+        for i, ch in enumerate(text):
+            dense[(ord(ch) + i) % 8] += 1.0
+        return dense
+
+    async def embed_query(self, texts: Sequence[str]) -> _FakeResult:
+        self.calls.append(("query", list(texts)))
+        return _FakeResult([self._vector(t) for t in texts])
+
+    async def embed_documents(self, texts: Sequence[str]) -> _FakeResult:
+        self.calls.append(("document", list(texts)))
+        return _FakeResult([self._vector(t) for t in texts])
+
+
+@pytest.fixture
+def fake_embedder(monkeypatch: pytest.MonkeyPatch) -> _RecordingEmbedder:
+    fake = _RecordingEmbedder()
+    monkeypatch.setattr(embed_module, "_get_embedder", lambda model, dims: fake)
+    return fake
+
+
+class TestNormalisationConsistency:
+    @pytest.mark.asyncio
+    async def test_memorised_text_matches_its_query(
+        self, fake_embedder: _RecordingEmbedder
+    ) -> None:
+        [stored] = await embed_batch(
+            ["Hello  World"], model=MODEL, mode="document", normalise_text=True
+        )
+        query = await embed_single(
+            "hello world", model=MODEL, mode="query", normalise_text=True
+        )
+        assert stored.vector == query.vector
+
+    @pytest.mark.asyncio
+    async def test_query_and_document_receive_identical_text(
+        self, fake_embedder: _RecordingEmbedder
+    ) -> None:
+        await embed_single(
+            "Hello  World", model=MODEL, mode="document", normalise_text=True
+        )
+        await embed_single(
+            "hello world", model=MODEL, mode="query", normalise_text=True
+        )
+        assert fake_embedder.calls == [
+            ("document", ["hello world"]),
+            ("query", ["hello world"]),
+        ]
+
+    @pytest.mark.asyncio
+    async def test_text_is_verbatim_without_normalisation(
+        self, fake_embedder: _RecordingEmbedder
+    ) -> None:
+        stored = await embed_single("Hello  World", model=MODEL, mode="document")
+        query = await embed_single("hello world", model=MODEL, mode="query")
+        assert fake_embedder.calls[0] == ("document", ["Hello  World"])
+        assert stored.vector != query.vector
+
+    @pytest.mark.asyncio
+    async def test_batch_honours_query_mode(
+        self, fake_embedder: _RecordingEmbedder
+    ) -> None:
+        await embed_batch(["a", "b"], model=MODEL, mode="query")
+        assert fake_embedder.calls == [("query", ["a", "b"])]
+
+
+class _FlakyEmbedder(_RecordingEmbedder):
+    """Fails with a 503 a set number of times before embedding."""
+
+    def __init__(self, failures: int) -> None:
+        super().__init__()
+        self.failures = failures
+
+    async def embed_query(self, texts: Sequence[str]) -> _FakeResult:
+        if self.failures > 0:
+            self.failures -= 1
+            error = RuntimeError("Service Unavailable")
+            error.status_code = 503  # type: ignore[attr-defined]
+            raise error
+        return await super().embed_query(texts)
+
+
+def _install_flaky(monkeypatch: pytest.MonkeyPatch, failures: int) -> _FlakyEmbedder:
+    """Serve embeddings from a flaky fake and make retry backoff instant."""
+
+    async def instant(delay: float) -> None:
+        return None
+
+    flaky = _FlakyEmbedder(failures)
+    monkeypatch.setattr(embed_module, "_get_embedder", lambda model, dims: flaky)
+    monkeypatch.setattr(retry_module.asyncio, "sleep", instant)
+    return flaky
+
+
+class TestRetries:
+    @pytest.mark.asyncio
+    async def test_transient_failures_are_retried(
+        self, monkeypatch: pytest.MonkeyPatch
+    ) -> None:
+        flaky = _install_flaky(monkeypatch, failures=2)
+        result = await embed_single("hello", model=MODEL, max_attempts=3)
+        assert result.vector
+        assert flaky.calls == [("query", ["hello"])]
+
+    @pytest.mark.asyncio
+    async def test_failures_beyond_max_attempts_propagate(
+        self, monkeypatch: pytest.MonkeyPatch
+    ) -> None:
+        _install_flaky(monkeypatch, failures=2)
+        with pytest.raises(RuntimeError):
+            await embed_single("hello", model=MODEL, max_attempts=2)
+
+
+class TestWarmUp:
+    @pytest.mark.asyncio
+    async def test_waits_for_a_loading_server(
+        self, monkeypatch: pytest.MonkeyPatch
+    ) -> None:
+        # More failures than the default attempts for a normal call.
+        loading = _install_flaky(monkeypatch, failures=5)
+        seconds = await warm_up(model=MODEL)
+        assert seconds >= 0
+        assert loading.calls == [("query", [WARMUP_TEXT])]
+
+    @pytest.mark.asyncio
+    async def test_later_calls_succeed_first_time(
+        self, monkeypatch: pytest.MonkeyPatch
+    ) -> None:
+        loading = _install_flaky(monkeypatch, failures=2)
+        await warm_up(model=MODEL)
+        result = await embed_single("hello", model=MODEL, max_attempts=1)
+        assert result.vector
+        assert loading.calls[-1] == ("query", ["hello"])
+
+    @pytest.mark.asyncio
+    async def test_gives_up_after_max_attempts(
+        self, monkeypatch: pytest.MonkeyPatch
+    ) -> None:
+        _install_flaky(monkeypatch, failures=3)
+        with pytest.raises(RuntimeError):
+            await warm_up(model=MODEL, max_attempts=3)
+
+
+class _ShortEmbedder(_RecordingEmbedder):
+    """Drops the last embedding, as a misbehaving endpoint might."""
+
+    async def embed_documents(self, texts: Sequence[str]) -> _FakeResult:
+        result = await super().embed_documents(texts)
+        return _FakeResult(result.embeddings[:-1])
+
+
+class TestBatchOrdering:
+    @pytest.mark.asyncio
+    async def test_results_follow_input_order(
+        self, fake_embedder: _RecordingEmbedder
+    ) -> None:
+        texts = ["alpha", "bravo charlie", "delta", "echo foxtrot golf"]
+        batch = await embed_batch(texts, model=MODEL, mode="document")
+        singles = [
+            await embed_single(t, model=MODEL, mode="document") for t in texts
+        ]
+        assert [r.vector for r in batch] == [r.vector for r in singles]
+
+    @pytest.mark.asyncio
+    async def test_count_mismatch_is_an_error(
+        self, monkeypatch: pytest.MonkeyPatch
+    ) -> None:
+        short = _ShortEmbedder()
+        monkeypatch.setattr(embed_module, "_get_embedder", lambda model, dims: short)
+        with pytest.raises(RuntimeError, match="2 vectors for 3 inputs"):
+            await embed_batch(["a", "b", "c"], model=MODEL)
+
+
+class _BlankOnceEmbedder(_RecordingEmbedder):
+    """Returns an all-zero vector for the first request only."""
+
+    async def embed_documents(self, texts: Sequence[str]) -> _FakeResult:
+        result = await super().embed_documents(texts)
+        if len(self.calls) == 1:
+            result.embeddings[0] = [0.0] * 8
+        return result
+
+
+@pytest.fixture
+def blank_once(monkeypatch: pytest.MonkeyPatch) -> _BlankOnceEmbedder:
+    fake = _BlankOnceEmbedder()
+    monkeypatch.setattr(embed_module, "_get_embedder", lambda model, dims: fake)
+    return fake
+
+
+class TestRetryOnEmpty:
+    @pytest.mark.asyncio
+    async def test_empty_result_is_requested_again(
+        self, blank_once: _BlankOnceEmbedder
+    ) -> None:
+        [first, second] = await embed_batch(
+            ["alpha", "bravo"], model=MODEL, retry_on_empty=True
+        )
+        assert first.vector
+        assert second.vector
+        assert blank_once.calls == [
+            ("document", ["alpha", "bravo"]),
+            ("document", ["alpha"]),
+        ]
+
+    @pytest.mark.asyncio
+    async def test_empty_result_is_kept_without_the_option(
+        self, blank_once: _BlankOnceEmbedder
+    ) -> None:
+        [first, _] = await embed_batch(["alpha", "bravo"], model=MODEL)
+        assert first.vector == {}
+        assert len(blank_once.calls) == 1
+
+
+class TestCache:
+    @pytest.mark.asyncio
+    async def test_repeated_text_is_not_re_embedded(
+        self, fake_embedder: _RecordingEmbedder
+    ) -> None:
+        cache = EmbeddingCache(8)
+        first = await embed_single("hello", model=MODEL, cache=cache)
+        second = await embed_single("hello", model=MODEL, cache=cache)
+        assert first.vector == second.vector
+        assert fake_embedder.calls == [("query", ["hello"])]
+
+    @pytest.mark.asyncio
+    async def test_batch_only_sends_misses_once(
+        self, fake_embedder: _RecordingEmbedder
+    ) -> None:
+        cache = EmbeddingCache(8)
+        await embed_single("a", model=MODEL, mode="document", cache=cache)
+        results = await embed_batch(["a", "b", "b"], model=MODEL, cache=cache)
+        assert len(results) == 3
+        assert results[1].vector == results[2].vector
+        assert fake_embedder.calls[1] == ("document", ["b"])
+
+    @pytest.mark.asyncio
+    async def test_modes_are_cached_separately(
+        self, fake_embedder: _RecordingEmbedder
+    ) -> None:
+        cache = EmbeddingCache(8)
+        await embed_single("hello", model=MODEL, mode="query", cache=cache)
+        await embed_single("hello", model=MODEL, mode="document", cache=cache)
+        assert len(fake_embedder.calls) == 2
+
+    @pytest.mark.asyncio
+    async def test_evicted_text_is_embedded_again(
+        self, fake_embedder: _RecordingEmbedder
+    ) -> None:
+        cache = EmbeddingCache(1)
+        await embed_single("a", model=MODEL, cache=cache)
+        await embed_single("b", model=MODEL, cache=cache)
+        await embed_single("a", model=MODEL, cache=cache)
+        assert [texts for _, texts in fake_embedder.calls] == [["a"], ["b"], ["a"]]
+
+
+class _BagOfWordsEmbedder(_RecordingEmbedder):
+    """Gives each distinct word its own dimension, so wording decides similarity."""
+
+    def __init__(self) -> None:
+        super().__init__()
+        self.vocabulary: dict[str, int] = {}
+
+    def _vector(self, text: str) -> list[float]:  # type: ignore[override]
+        dense = [0.0] * 8
+        for word in text.split():
+            index = self.vocabulary.setdefault(word, len(self.vocabulary))
+            dense[index] += 1.0
+        return dense
+
+
+def _cosine(a: dict[str, float], b: dict[str, float]) -> float:
+    dot = sum(v * b.get(k, 0.0) for k, v in a.items())
+    norm_a = sum(v * v for v in a.values()) ** 0.5
+    norm_b = sum(v * v for v in b.values()) ** 0.5
+    return dot / (norm_a * norm_b)
+
+
+@pytest.fixture
+def bag_of_words(monkeypatch: pytest.MonkeyPatch) -> _BagOfWordsEmbedder:
+    fake = _BagOfWordsEmbedder()
+    monkeypatch.setattr(embed_module, "_get_embedder", lambda model, dims: fake)
+    return fake
+
+
+class TestQueryExpansion:
+    @pytest.mark.asyncio
+    async def test_vector_is_mean_of_variants(
+        self, bag_of_words: _BagOfWordsEmbedder
+    ) -> None:
+        synonyms = {"auto": ["car", "vehicle"]}
+        expanded = await embed_expanded_query(
+            "auto repair", synonyms=synonyms, model=MODEL
+        )
+        variants = await embed_batch(
+            ["auto repair", "car repair", "vehicle repair"],
+            model=MODEL,
+            mode="query",
+        )
+        assert expanded.vector == sparse_mean([v.vector for v in variants])
+        assert bag_of_words.calls[0] == (
+            "query",
+            ["auto repair", "car repair", "vehicle repair"],
+        )
+
+    @pytest.mark.asyncio
+    async def test_expansion_improves_recall_of_synonym_wording(
+        self, bag_of_words: _BagOfWordsEmbedder
+    ) -> None:
+        [stored] = await embed_batch(["car repair"], model=MODEL)
+        plain = await embed_single("auto repair", model=MODEL)
+        expanded = await embed_expanded_query(
+            "auto repair", synonyms={"auto": ["car"]}, model=MODEL
+        )
+        assert _cosine(expanded.vector, stored.vector) > _cosine(
+            plain.vector, stored.vector
+        )
+
+    @pytest.mark.asyncio
+    async def test_without_synonyms_matches_plain_query(
+        self, fake_embedder: _RecordingEmbedder
+    ) -> None:
+        plain = await embed_single("hello", model=MODEL)
+        expanded = await embed_expanded_query("hello", synonyms={}, model=MODEL)
+        assert expanded.vector == plain.vector
+
+
+class _SettingsEmbedder:
+    def __init__(self, model: str, settings: dict) -> None:
+        self.settings = settings
+
+
+class TestUserAgent:
+    def test_default_user_agent_names_vecfs(
+        self, monkeypatch: pytest.MonkeyPatch
+    ) -> None:
+        monkeypatch.delenv("VECFS_EMBED_USER_AGENT", raising=False)
+        monkeypatch.setattr(embed_module, "Embedder", _SettingsEmbedder)
+        embedder = embed_module._build_embedder(MODEL)
+        header = embedder.settings["extra_headers"]["User-Agent"]
+        assert header == embed_module.DEFAULT_USER_AGENT
+        assert header.startswith("vecfs/")
+
+    def test_user_agent_can_be_overridden(
+        self, monkeypatch: pytest.MonkeyPatch
+    ) -> None:
+        monkeypatch.setenv("VECFS_EMBED_USER_AGENT", "acme-agent/2.0")
+        monkeypatch.setattr(embed_module, "Embedder", _SettingsEmbedder)
+        embedder = embed_module._build_embedder(MODEL, 64)
+        assert embedder.settings == {
+            "extra_headers": {"User-Agent": "acme-agent/2.0"},
+            "dimensions": 64,
+        }
+
+
+class _OversizedEmbedder:
+    """Ignores the dims setting and always returns 768 dimensions."""
+
+    async def embed_query(self, texts: Sequence[str]) -> _FakeResult:
+        return _FakeResult([[1.0] * 768 for _ in texts])
+
+    embed_documents = embed_query
+
+
+class TestDims:
+    @pytest.mark.asyncio
+    async def test_oversized_vectors_are_truncated(
+        self, monkeypatch: pytest.MonkeyPatch
+    ) -> None:
+        fake = _OversizedEmbedder()
+        monkeypatch.setattr(embed_module, "_get_embedder", lambda model, dims: fake)
+        result = await embed_single("hello", model=MODEL, dims=128, threshold=0.0)
+        assert result.dense_dimensions == 128
+        assert result.non_zero_count == 128
+        assert all(int(index) < 128 for index in result.vector)
+
+    @pytest.mark.asyncio
+    async def test_vectors_are_kept_whole_without_dims(
+        self, monkeypatch: pytest.MonkeyPatch
+    ) -> None:
+        fake = _OversizedEmbedder()
+        monkeypatch.setattr(embed_module, "_get_embedder", lambda model, dims: fake)
+        result = await embed_single("hello", model=MODEL, threshold=0.0)
+        assert result.dense_dimensions == 768
@@ -0,0 +1,45 @@
+"""Tests for synonym query expansion — pure Python, no embedding model needed."""
+
+from __future__ import annotations
+
+import json
+from pathlib import Path
+
+import pytest
+
+from vecfs_embed.expand import load_synonyms, query_variants
+
+
+class TestQueryVariants:
+    def test_original_comes_first(self) -> None:
+        variants = query_variants("auto repair", {"auto": ["car"]})
+        assert variants == ["auto repair", "car repair"]
+
+    def test_matches_words_case_insensitively(self) -> None:
+        assert query_variants("Auto repair", {"auto": ["car"]})[1] == "car repair"
+
+    def test_caps_the_number_of_variants(self) -> None:
+        synonyms = {"auto": ["car", "vehicle"], "repair": ["fix", "service"]}
+        variants = query_variants("auto repair", synonyms, max_variants=3)
+        assert variants == [
+            "auto repair",
+            "car repair",
+            "vehicle repair",
+            "auto fix",
+        ]
+
+    def test_no_synonyms_returns_only_the_query(self) -> None:
+        assert query_variants("hello world", {}) == ["hello world"]
+
+
+class TestLoadSynonyms:
+    def test_lowercases_keys(self, tmp_path: Path) -> None:
+        path = tmp_path / "synonyms.json"
+        path.write_text(json.dumps({"Auto": ["car"]}))
+        assert load_synonyms(path) == {"auto": ["car"]}
+
+    def test_rejects_non_list_values(self, tmp_path: Path) -> None:
+        path = tmp_path / "synonyms.json"
+        path.write_text(json.dumps({"auto": "car"}))
+        with pytest.raises(ValueError):
+            load_synonyms(path)
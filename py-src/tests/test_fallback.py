@@ -0,0 +1,94 @@
+"""Tests for falling back between embedders — no embedding model needed."""
+
+from __future__ import annotations
+
+import logging
+from dataclasses import dataclass
+from typing import Sequence
+
+import pytest
+
+from vecfs_embed import embed as embed_module
+from vecfs_embed.embed import embed_single
+from vecfs_embed.fallback import FallbackEmbedder, split_models
+
+
+@dataclass
+class _FakeResult:
+    embeddings: list[list[float]]
+
+
+class _FakeEmbedder:
+    """Answers with a vector chosen by model name; `down:` models raise."""
+
+    def __init__(self, model: str, settings: dict | None = None) -> None:
+        self.model = model
+        self.fail = model.startswith("down:")
+        self.calls = 0
+
+    async def _embed(self, texts: Sequence[str]) -> _FakeResult:
+        self.calls += 1
+        if self.fail:
+            raise ConnectionError(f"{self.model} is unreachable")
+        dense = [1.0, 0.0] if self.model.startswith("first:") else [0.0, 1.0]
+        return _FakeResult([dense for _ in texts])
+
+    embed_query = _embed
+    embed_documents = _embed
+
+
+class TestSplitModels:
+    def test_splits_and_trims(self) -> None:
+        assert split_models(" a:x , b:y,") == ["a:x", "b:y"]
+
+
+class TestFallbackEmbedder:
+    @pytest.mark.asyncio
+    async def test_uses_primary_when_it_answers(self) -> None:
+        primary = _FakeEmbedder("first:model")
+        secondary = _FakeEmbedder("second:model")
+        fallback = FallbackEmbedder([("first", primary), ("second", secondary)])
+        result = await fallback.embed_query(["hi"])
+        assert result.embeddings == [[1.0, 0.0]]
+        assert secondary.calls == 0
+
+    @pytest.mark.asyncio
+    async def test_falls_back_when_primary_fails(
+        self, caplog: pytest.LogCaptureFixture
+    ) -> None:
+        primary = _FakeEmbedder("down:model")
+        secondary = _FakeEmbedder("second:model")
+        fallback = FallbackEmbedder([("down", primary), ("second", secondary)])
+        with caplog.at_level(logging.INFO, logger="vecfs_embed.fallback"):
+            result = await fallback.embed_documents(["hi", "there"])
+        assert result.embeddings == [[0.0, 1.0], [0.0, 1.0]]
+        assert primary.calls == 1
+        assert "Embedding with down failed" in caplog.text
+        assert "Embedded with fallback model second" in caplog.text
+
+    @pytest.mark.asyncio
+    async def test_raises_last_error_when_all_fail(self) -> None:
+        fallback = FallbackEmbedder(
+            [("a", _FakeEmbedder("down:a")), ("b", _FakeEmbedder("down:b"))]
+        )
+        with pytest.raises(ConnectionError, match="down:b"):
+            await fallback.embed_query(["hi"])
+
+    def test_needs_an_embedder(self) -> None:
+        with pytest.raises(ValueError):
+            FallbackEmbedder([])
+
+
+class TestModelList:
+    @pytest.mark.asyncio
+    async def test_comma_separated_model_falls_back(
+        self, monkeypatch: pytest.MonkeyPatch
+    ) -> None:
+        monkeypatch.setattr(embed_module, "Embedder", _FakeEmbedder)
+        monkeypatch.setattr(
+            embed_module, "_get_embedder", embed_module._build_embedder
+        )
+        result = await embed_single(
+            "hello", model="down:model,second:model", max_attempts=1
+        )
+        assert result.vector == {"1": 1.0}
@@ -29,6 +29,14 @@ MODEL = "sentence-transformers:all-MiniLM-L6-v2"
 EXPECTED_DIMS = 384
 
 
+def _cosine(a: dict[str, float], b: dict[str, float]) -> float:
+    """Cosine similarity of two sparse vectors in VecFS JSON form."""
+    dot = sum(v * b[k] for k, v in a.items() if k in b)
+    norm_a = math.sqrt(sum(v * v for v in a.values()))
+    norm_b = math.sqrt(sum(v * v for v in b.values()))
+    return dot / (norm_a * norm_b)
+
+
 @pytest.fixture(scope="module")
 def doc_texts() -> list[str]:
     """Read every Markdown file in docs/ and return their contents."""
@@ -95,6 +103,28 @@ class TestEmbedSingle:
         assert result.dense_dimensions == EXPECTED_DIMS
         assert result.non_zero_count > 0
 
+    @pytest.mark.asyncio
+    async def test_normalised_case_variants_produce_identical_vectors(
+        self,
+    ) -> None:
+        upper = await embed_single(
+            "Sparse Vector  Storage", model=MODEL, normalise_text=True
+        )
+        lower = await embed_single(
+            "sparse vector storage", model=MODEL, normalise_text=True
+        )
+        assert upper.vector == lower.vector
+
+    @pytest.mark.asyncio
+    async def test_memorised_phrase_matches_normalised_query(self) -> None:
+        stored = await embed_single(
+            "Hello  World", model=MODEL, mode="document", normalise_text=True
+        )
+        query = await embed_single(
+            "hello world", model=MODEL, mode="query", normalise_text=True
+        )
+        assert _cosine(stored.vector, query.vector) == pytest.approx(1.0, abs=1e-3)
+
     @pytest.mark.asyncio
     async def test_to_dict_is_json_serialisable(self, first_doc: str) -> None:
         result = await embed_single(first_doc, model=MODEL, mode="document")
@@ -0,0 +1,49 @@
+"""Tests for per-provider default model resolution."""
+
+from __future__ import annotations
+
+import pytest
+
+from vecfs_embed.models import DEFAULT_MODEL, PROVIDER_DEFAULT_MODELS, resolve_model
+
+
+class TestResolveModel:
+    @pytest.mark.parametrize(
+        "provider,expected",
+        [
+            ("sentence-transformers", "sentence-transformers:all-MiniLM-L6-v2"),
+            ("openai", "openai:text-embedding-3-small"),
+            ("google-gla", "google-gla:gemini-embedding-001"),
+            ("google-vertex", "google-vertex:gemini-embedding-001"),
+            ("cohere", "cohere:embed-v4.0"),
+            ("voyageai", "voyageai:voyage-3.5"),
+        ],
+    )
+    def test_provider_picks_its_own_default(
+        self, provider: str, expected: str
+    ) -> None:
+        assert resolve_model(provider) == expected
+
+    def test_every_provider_is_covered(self) -> None:
+        for provider, model in PROVIDER_DEFAULT_MODELS.items():
+            assert resolve_model(provider) == f"{provider}:{model}"
+
+    def test_trailing_colon_counts_as_unset(self) -> None:
+        assert resolve_model("openai:") == "openai:text-embedding-3-small"
+
+    def test_unset_falls_back_to_global_default(self) -> None:
+        assert resolve_model(None) == DEFAULT_MODEL
+        assert resolve_model("") == DEFAULT_MODEL
+
+    def test_explicit_model_is_unchanged(self) -> None:
+        assert resolve_model("openai:text-embedding-3-large") == (
+            "openai:text-embedding-3-large"
+        )
+
+    def test_unknown_provider_is_unchanged(self) -> None:
+        assert resolve_model("some-provider") == "some-provider"
+
+    def test_fallback_list_resolves_each_model(self) -> None:
+        assert resolve_model("openai, sentence-transformers:custom") == (
+            "openai:text-embedding-3-small,sentence-transformers:custom"
+        )
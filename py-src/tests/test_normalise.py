@@ -0,0 +1,33 @@
+"""Tests for the normalise module — pure Python, no embedding model needed."""
+
+from __future__ import annotations
+
+from vecfs_embed.normalise import normalise_text, prepare_texts
+
+
+class TestNormaliseText:
+    def test_lowercases(self) -> None:
+        assert normalise_text("Hello World") == "hello world"
+
+    def test_collapses_whitespace(self) -> None:
+        assert normalise_text("  hello \t\n  world  ") == "hello world"
+
+    def test_composes_unicode(self) -> None:
+        decomposed = "cafe\u0301"
+        assert normalise_text(decomposed) == "caf\u00e9"
+
+    def test_differently_cased_inputs_match(self) -> None:
+        assert normalise_text("Sparse  VECTOR") == normalise_text("sparse vector")
+
+    def test_empty_string(self) -> None:
+        assert normalise_text("") == ""
+
+
+class TestPrepareTexts:
+    def test_passthrough_when_disabled(self) -> None:
+        texts = ["Hello  World"]
+        assert prepare_texts(texts, normalise=False) == ["Hello  World"]
+
+    def test_normalises_each_text_when_enabled(self) -> None:
+        texts = ["Hello  World", "FOO"]
+        assert prepare_texts(texts, normalise=True) == ["hello world", "foo"]
@@ -0,0 +1,89 @@
+"""Tests for embedder reuse — no embedding model needed."""
+
+from __future__ import annotations
+
+import threading
+import time
+from concurrent.futures import ThreadPoolExecutor
+
+import pytest
+
+from vecfs_embed import embed as embed_module
+from vecfs_embed.registry import EmbedderRegistry
+
+
+class _CountingFactory:
+    def __init__(self, delay: float = 0.0) -> None:
+        self.built: list[tuple[str, int | None]] = []
+        self.delay = delay
+        self._lock = threading.Lock()
+
+    def __call__(self, model: str, dims: int | None) -> object:
+        time.sleep(self.delay)
+        with self._lock:
+            self.built.append((model, dims))
+        return object()
+
+
+class TestEmbedderRegistry:
+    def test_same_settings_reuse_one_instance(self) -> None:
+        factory = _CountingFactory()
+        registry = EmbedderRegistry(factory)
+        first = registry.get("openai:text-embedding-3-small")
+        second = registry.get("openai:text-embedding-3-small")
+        assert first is second
+        assert len(factory.built) == 1
+
+    def test_different_settings_build_separately(self) -> None:
+        factory = _CountingFactory()
+        registry = EmbedderRegistry(factory)
+        small = registry.get("openai:text-embedding-3-small")
+        reduced = registry.get("openai:text-embedding-3-small", 256)
+        assert small is not reduced
+        assert factory.built == [
+            ("openai:text-embedding-3-small", None),
+            ("openai:text-embedding-3-small", 256),
+        ]
+
+    def test_concurrent_first_use_builds_once(self) -> None:
+        factory = _CountingFactory(delay=0.05)
+        registry = EmbedderRegistry(factory)
+        with ThreadPoolExecutor(max_workers=8) as pool:
+            instances = list(pool.map(lambda _: registry.get("m"), range(8)))
+        assert len(factory.built) == 1
+        assert all(i is instances[0] for i in instances)
+
+    def test_clear_forces_a_rebuild(self) -> None:
+        factory = _CountingFactory()
+        registry = EmbedderRegistry(factory)
+        registry.get("m")
+        registry.clear()
+        registry.get("m")
+        assert len(factory.built) == 2
+
+
+class _FakeEmbedder:
+    instances = 0
+
+    def __init__(self, model: str, settings: object = None) -> None:
+        type(self).instances += 1
+        self.model = model
+        self.settings = settings
+
+
+class TestSharedEmbedder:
+    def test_two_calls_with_same_settings_build_one_embedder(
+        self, monkeypatch: pytest.MonkeyPatch
+    ) -> None:
+        monkeypatch.setattr(embed_module, "Embedder", _FakeEmbedder)
+        monkeypatch.setattr(
+            embed_module,
+            "_registry",
+            EmbedderRegistry(embed_module._build_embedder),
+        )
+        _FakeEmbedder.instances = 0
+        first = embed_module._get_embedder("fake:model", 128)
+        second = embed_module._get_embedder("fake:model", 128)
+        assert first is second
+        assert _FakeEmbedder.instances == 1
+        assert first.settings["dimensions"] == 128
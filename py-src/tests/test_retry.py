@@ -0,0 +1,86 @@
+"""Tests for the retry helper — no network or embedding model needed."""
+
+from __future__ import annotations
+
+import pytest
+
+from vecfs_embed.retry import is_transient, with_retries
+
+
+class _HTTPError(Exception):
+    """Mimics a provider error carrying an HTTP status code."""
+
+    def __init__(self, status_code: int) -> None:
+        super().__init__(f"HTTP {status_code}")
+        self.status_code = status_code
+
+
+class _Flaky:
+    """Fails with the given errors in turn, then returns a value."""
+
+    def __init__(self, errors: list[Exception], value: str = "ok") -> None:
+        self.errors = list(errors)
+        self.value = value
+        self.calls = 0
+
+    async def __call__(self) -> str:
+        self.calls += 1
+        if self.errors:
+            raise self.errors.pop(0)
+        return self.value
+
+
+class _Sleeps:
+    def __init__(self) -> None:
+        self.delays: list[float] = []
+
+    async def __call__(self, delay: float) -> None:
+        self.delays.append(delay)
+
+
+class TestIsTransient:
+    @pytest.mark.parametrize("status", [429, 500, 502, 503])
+    def test_retryable_statuses(self, status: int) -> None:
+        assert is_transient(_HTTPError(status))
+
+    @pytest.mark.parametrize("status", [400, 401, 404])
+    def test_client_errors_are_permanent(self, status: int) -> None:
+        assert not is_transient(_HTTPError(status))
+
+    def test_network_errors_are_transient(self) -> None:
+        assert is_transient(ConnectionRefusedError())
+        assert is_transient(TimeoutError())
+
+    def test_other_errors_are_permanent(self) -> None:
+        assert not is_transient(ValueError("bad input"))
+
+
+class TestWithRetries:
+    @pytest.mark.asyncio
+    async def test_succeeds_after_two_transient_failures(self) -> None:
+        call = _Flaky([_HTTPError(503), _HTTPError(503)])
+        sleeps = _Sleeps()
+        assert await with_retries(call, max_attempts=3, sleep=sleeps) == "ok"
+        assert call.calls == 3
+        assert sleeps.delays == [0.5, 1.0]
+
+    @pytest.mark.asyncio
+    async def test_permanent_error_is_not_retried(self) -> None:
+        call = _Flaky([_HTTPError(400)])
+        sleeps = _Sleeps()
+        with pytest.raises(_HTTPError):
+            await with_retries(call, sleep=sleeps)
+        assert call.calls == 1
+        assert sleeps.delays == []
+
+    @pytest.mark.asyncio
+    async def test_gives_up_after_max_attempts(self) -> None:
+        call = _Flaky([_HTTPError(503)] * 5)
+        with pytest.raises(_HTTPError):
+            await with_retries(call, max_attempts=2, sleep=_Sleeps())
+        assert call.calls == 2
+
+    @pytest.mark.asyncio
+    async def test_rejects_zero_attempts(self) -> None:
+        with pytest.raises(ValueError):
+            await with_retries(_Flaky([]), max_attempts=0)
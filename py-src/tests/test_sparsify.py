@@ -9,9 +9,11 @@ import pytest
 from vecfs_embed.sparsify import (
     l2_normalise,
     magnitude_stats,
+    sparse_mean,
     sparsity_at_thresholds,
     to_sparse_threshold,
     to_sparse_topk,
+    truncate_dims,
 )
 
 
@@ -68,6 +70,18 @@ class TestToSparseThreshold:
         assert "2" in sparse
 
 
+class TestTruncateDims:
+    def test_keeps_leading_components(self) -> None:
+        assert truncate_dims([1.0, 2.0, 3.0, 4.0], 2) == [1.0, 2.0]
+
+    def test_short_vector_unchanged(self) -> None:
+        assert truncate_dims([1.0, 2.0], 8) == [1.0, 2.0]
+
+    def test_rejects_non_positive_dims(self) -> None:
+        with pytest.raises(ValueError):
+            truncate_dims([1.0], 0)
+
+
 class TestToSparseTopK:
     def test_keeps_k_largest(self) -> None:
         dense = [0.1, 0.9, 0.3, 0.7, 0.5]
@@ -117,3 +131,15 @@ class TestSparsityAtThresholds:
         result = sparsity_at_thresholds(vectors, thresholds=[0.0])
         # After normalisation both non-zero values are ~0.707, which is > 0
         assert result["0.0"]["mean_retained_pct"] == pytest.approx(66.7, abs=0.1)
+
+
+class TestSparseMean:
+    def test_averages_each_dimension(self) -> None:
+        mean = sparse_mean([{"0": 1.0, "1": 0.5}, {"0": 0.5, "2": 1.0}])
+        assert mean == {"0": 0.75, "1": 0.25, "2": 0.5}
+
+    def test_drops_dimensions_that_cancel(self) -> None:
+        assert sparse_mean([{"0": 1.0}, {"0": -1.0}]) == {}
+
+    def test_empty_input(self) -> None:
+        assert sparse_mean([]) == {}
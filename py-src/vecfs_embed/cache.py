@@ -0,0 +1,47 @@
+"""
+In-memory LRU cache of dense embeddings.
+
+Agent loops often embed the same query text many times. Passing one
+:class:`EmbeddingCache` to every embed call skips the provider round
+trip for text that has been seen before.
+"""
+
+from __future__ import annotations
+
+from collections import OrderedDict
+from typing import Hashable
+
+DEFAULT_CACHE_SIZE = 1024
+
+
+class EmbeddingCache:
+    """
+    Least-recently-used map from an embedding request to its dense vector.
+
+    Keys combine the model, dimensions, mode and the exact (prepared)
+    text, so a cached query embedding is never reused as a document
+    embedding. When full, the least recently used entry is evicted.
+    """
+
+    def __init__(self, maxsize: int = DEFAULT_CACHE_SIZE) -> None:
+        if maxsize < 1:
+            raise ValueError(f"maxsize must be at least 1, got {maxsize}")
+        self.maxsize = maxsize
+        self._entries: OrderedDict[Hashable, list[float]] = OrderedDict()
+
+    def __len__(self) -> int:
+        return len(self._entries)
+
+    def get(self, key: Hashable) -> list[float] | None:
+        """Return the cached vector and mark it as recently used."""
+        vector = self._entries.get(key)
+        if vector is not None:
+            self._entries.move_to_end(key)
+        return vector
+
+    def put(self, key: Hashable, vector: list[float]) -> None:
+        """Store a vector, evicting the least recently used if full."""
+        self._entries[key] = vector
+        self._entries.move_to_end(key)
+        while len(self._entries) > self.maxsize:
+            self._entries.popitem(last=False)
@@ -1,10 +1,11 @@
 """
 Command-line interface for vecfs-embed.
 
-Supports three modes:
-  - Single text embedding (default)
+Supports four modes:
+  - Single text embedding (default), optionally with query expansion
   - Batch embedding (--batch)
   - Calibration (--calibrate)
+  - Warm-up (--warmup)
 """
 
 from __future__ import annotations
@@ -15,9 +16,19 @@ import json
 import os
 import sys
 
-from .embed import calibrate, embed_batch, embed_single
+from .cache import EmbeddingCache
+from .embed import (
+    DEFAULT_WARMUP_ATTEMPTS,
+    calibrate,
+    embed_batch,
+    embed_expanded_query,
+    embed_single,
+    warm_up,
+)
+from .expand import load_synonyms
+from .models import DEFAULT_MODEL, resolve_model
+from .retry import DEFAULT_MAX_ATTEMPTS
 
-DEFAULT_MODEL = "sentence-transformers:all-MiniLM-L6-v2"
 DEFAULT_THRESHOLD = 0.01
 
 
@@ -49,10 +60,18 @@ def _parse_args() -> argparse.Namespace:
         action="store_true",
         help="Calibrate mode: read sample texts from stdin and report magnitude statistics.",
     )
+    parser.add_argument(
+        "--warmup",
+        action="store_true",
+        help="Embed a trivial text so the model is loaded before real queries, "
+        f"retrying at least {DEFAULT_WARMUP_ATTEMPTS} times while it loads.",
+    )
     parser.add_argument(
         "--model",
         default=os.environ.get("VECFS_EMBED_MODEL", DEFAULT_MODEL),
-        help=f"Embedding model string (default: {DEFAULT_MODEL}, env: VECFS_EMBED_MODEL).",
+        help=f"Embedding model string, or a provider name to use its default model; "
+        f"separate several with commas to fall back to each in turn "
+        f"(default: {DEFAULT_MODEL}, env: VECFS_EMBED_MODEL).",
     )
     parser.add_argument(
         "--dims",
@@ -67,7 +86,51 @@ def _parse_args() -> argparse.Namespace:
         help=f"Sparsification threshold (default: {DEFAULT_THRESHOLD}, env: VECFS_EMBED_THRESHOLD).",
     )
 
-    return parser.parse_args()
+    parser.add_argument(
+        "--max-attempts",
+        type=int,
+        default=_env_int("VECFS_EMBED_MAX_ATTEMPTS") or DEFAULT_MAX_ATTEMPTS,
+        help="Attempts per embedding call; 429, 5xx and network errors are "
+        f"retried with backoff (default: {DEFAULT_MAX_ATTEMPTS}, "
+        "env: VECFS_EMBED_MAX_ATTEMPTS).",
+    )
+    parser.add_argument(
+        "--retry-on-empty",
+        action="store_true",
+        default=_env_flag("VECFS_EMBED_RETRY_ON_EMPTY"),
+        help="Re-request an embedding once if it comes back empty or all zeros "
+        "(env: VECFS_EMBED_RETRY_ON_EMPTY).",
+    )
+    parser.add_argument(
+        "--expand",
+        action="store_true",
+        default=_env_flag("VECFS_EMBED_EXPAND"),
+        help="In query mode, also embed synonym rewordings of the text and "
+        "output the mean vector; needs --synonyms (env: VECFS_EMBED_EXPAND).",
+    )
+    parser.add_argument(
+        "--synonyms",
+        default=os.environ.get("VECFS_EMBED_SYNONYMS"),
+        help="JSON file mapping words to lists of synonyms, used by --expand "
+        "(env: VECFS_EMBED_SYNONYMS).",
+    )
+    parser.add_argument(
+        "--normalise-text",
+        action="store_true",
+        default=_env_flag("VECFS_EMBED_NORMALISE_TEXT"),
+        help="Lowercase, NFC-normalise and collapse whitespace before embedding "
+        "(env: VECFS_EMBED_NORMALISE_TEXT).",
+    )
+
+    args = parser.parse_args()
+    args.model = resolve_model(args.model)
+    if args.expand and not args.synonyms:
+        parser.error("--expand requires --synonyms or VECFS_EMBED_SYNONYMS.")
+    return args
+
+
+def _env_flag(name: str) -> bool:
+    return os.environ.get(name, "").strip().lower() in ("1", "true")
 
 
 def _env_int(name: str) -> int | None:
@@ -81,12 +144,29 @@ def _read_stdin_lines() -> list[str]:
 
 
 async def _run(args: argparse.Namespace) -> None:
+    if args.warmup:
+        seconds = await warm_up(
+            model=args.model,
+            dims=args.dims,
+            max_attempts=max(args.max_attempts, DEFAULT_WARMUP_ATTEMPTS),
+        )
+        result = {"model": args.model, "seconds": round(seconds, 3)}
+        print(json.dumps(result, indent=2))
+        return
+
     if args.calibrate:
         texts = _read_stdin_lines()
         if not texts:
             print("Error: --calibrate requires input on stdin (one text per line).", file=sys.stderr)
             sys.exit(1)
-        result = await calibrate(texts, model=args.model, dims=args.dims)
+        result = await calibrate(
+            texts,
+            model=args.model,
+            dims=args.dims,
+            normalise_text=args.normalise_text,
+            max_attempts=args.max_attempts,
+            retry_on_empty=args.retry_on_empty,
+        )
         print(json.dumps(result.to_dict(), indent=2))
         return
 
@@ -95,12 +175,17 @@ async def _run(args: argparse.Namespace) -> None:
         if not texts:
             print("Error: --batch requires input on stdin (one text per line).", file=sys.stderr)
             sys.exit(1)
+        # A batch-sized cache sends each distinct line to the provider once.
         results = await embed_batch(
             texts,
             model=args.model,
             mode=args.mode,
             dims=args.dims,
             threshold=args.threshold,
+            normalise_text=args.normalise_text,
+            max_attempts=args.max_attempts,
+            retry_on_empty=args.retry_on_empty,
+            cache=EmbeddingCache(len(texts)),
         )
         print(json.dumps([r.to_dict() for r in results], indent=2))
         return
@@ -113,12 +198,29 @@ async def _run(args: argparse.Namespace) -> None:
         print("Error: no input text provided.", file=sys.stderr)
         sys.exit(1)
 
+    if args.expand and args.mode == "query":
+        result = await embed_expanded_query(
+            text,
+            synonyms=load_synonyms(args.synonyms),
+            model=args.model,
+            dims=args.dims,
+            threshold=args.threshold,
+            normalise_text=args.normalise_text,
+            max_attempts=args.max_attempts,
+            retry_on_empty=args.retry_on_empty,
+        )
+        print(json.dumps(result.to_dict(), indent=2))
+        return
+
     result = await embed_single(
         text,
         model=args.model,
         mode=args.mode,
         dims=args.dims,
         threshold=args.threshold,
+        normalise_text=args.normalise_text,
+        max_attempts=args.max_attempts,
+        retry_on_empty=args.retry_on_empty,
     )
     print(json.dumps(result.to_dict(), indent=2))
 
@@ -7,18 +7,56 @@ dense → sparse pipeline.
 
 from __future__ import annotations
 
+import logging
+import os
+import time
 from dataclasses import dataclass
+from importlib.metadata import PackageNotFoundError, version
 from typing import Any, Sequence
 
 from pydantic_ai import Embedder
 from pydantic_ai.embeddings import EmbeddingSettings
 
+from .cache import EmbeddingCache
+from .expand import DEFAULT_MAX_VARIANTS, query_variants
+from .fallback import MODEL_SEPARATOR, FallbackEmbedder, split_models
+from .normalise import prepare_texts
+from .registry import EmbedderRegistry
+from .retry import DEFAULT_MAX_ATTEMPTS, with_retries
 from .sparsify import (
     magnitude_stats,
+    sparse_mean,
     sparsity_at_thresholds,
     to_sparse_threshold,
+    truncate_dims,
 )
 
+logger = logging.getLogger(__name__)
+
+# A model server that loads lazily can take a minute to answer its first
+# request; eight attempts back off for about a minute in total.
+DEFAULT_WARMUP_ATTEMPTS = 8
+WARMUP_TEXT = "warm up"
+
+
+def _package_version() -> str:
+    try:
+        return version("vecfs-embed")
+    except PackageNotFoundError:  # running from a source checkout
+        return "dev"
+
+
+DEFAULT_USER_AGENT = f"vecfs/{_package_version()}"
+
+
+def user_agent() -> str:
+    """
+    The User-Agent sent with embedding requests to remote providers, so
+    endpoint operators can identify VecFS traffic. ``VECFS_EMBED_USER_AGENT``
+    overrides the default ``vecfs/<version>``.
+    """
+    return os.environ.get("VECFS_EMBED_USER_AGENT") or DEFAULT_USER_AGENT
+
 
 @dataclass
 class EmbedResult:
@@ -65,33 +103,126 @@ class CalibrateResult:
 def _build_embedder(
     model: str,
     dims: int | None = None,
-) -> Embedder:
-    """Create a Pydantic AI Embedder with optional dimension reduction."""
-    settings: EmbeddingSettings | None = None
+) -> Embedder | FallbackEmbedder:
+    """
+    Create a Pydantic AI Embedder with optional dimension reduction.
+    Requests carry the :func:`user_agent` header; local models ignore it.
+    A comma-separated *model* builds a :class:`FallbackEmbedder` that
+    tries each model in turn.
+    """
+    if MODEL_SEPARATOR in model:
+        return FallbackEmbedder(
+            [(m, _build_embedder(m, dims)) for m in split_models(model)]
+        )
+    settings: EmbeddingSettings = {"extra_headers": {"User-Agent": user_agent()}}
     if dims is not None:
-        settings = {"dimensions": dims}
+        settings["dimensions"] = dims
     return Embedder(model, settings=settings)
 
 
-async def embed_single(
-    text: str,
+_registry: EmbedderRegistry[Embedder | FallbackEmbedder] = EmbedderRegistry(
+    _build_embedder
+)
+
+
+def _get_embedder(model: str, dims: int | None = None) -> Embedder | FallbackEmbedder:
+    """Return a shared Embedder for the settings, building it on first use."""
+    return _registry.get(model, dims)
+
+
+async def _embed_dense(
+    texts: Sequence[str],
     *,
     model: str,
-    mode: str = "query",
-    dims: int | None = None,
-    threshold: float = 0.01,
-) -> EmbedResult:
-    """Embed a single text and return a sparse vector result."""
-    embedder = _build_embedder(model, dims)
+    mode: str,
+    dims: int | None,
+    normalise_text: bool,
+    max_attempts: int = DEFAULT_MAX_ATTEMPTS,
+    retry_on_empty: bool = False,
+    cache: EmbeddingCache | None = None,
+) -> list[list[float]]:
+    """
+    The single path from text to dense vectors.
+
+    Every public entry point goes through here so that queries and
+    documents are normalised identically; otherwise a memorised phrase
+    would not match the same phrase used as a search query. Transient
+    provider failures are retried up to *max_attempts* times. With
+    *retry_on_empty*, inputs that come back as empty or all-zero vectors
+    are embedded once more before the result is accepted. With a
+    *cache*, only texts not already cached are sent to the provider, and
+    the provider is not loaded at all when every text is a hit.
+    """
+    prepared = prepare_texts(texts, normalise=normalise_text)
+    if cache is None:
+        return await _fetch_dense(
+            prepared, model, mode, dims, max_attempts, retry_on_empty
+        )
 
-    if mode == "query":
-        result = await embedder.embed_query(text)
-    else:
-        result = await embedder.embed_documents(text)
+    keys = [(model, dims, mode, text) for text in prepared]
+    cached = [cache.get(key) for key in keys]
+    missing = list(dict.fromkeys(t for t, v in zip(prepared, cached) if v is None))
+    fetched: dict[str, list[float]] = {}
+    if missing:
+        vectors = await _fetch_dense(
+            missing, model, mode, dims, max_attempts, retry_on_empty
+        )
+        fetched = dict(zip(missing, vectors))
+
+    dense: list[list[float]] = []
+    for key, text, vector in zip(keys, prepared, cached):
+        if vector is None:
+            vector = fetched[text]
+            if any(vector):
+                cache.put(key, vector)
+        dense.append(vector)
+    return dense
+
+
+async def _fetch_dense(
+    texts: list[str],
+    model: str,
+    mode: str,
+    dims: int | None,
+    max_attempts: int,
+    retry_on_empty: bool,
+) -> list[list[float]]:
+    """
+    Request embeddings for already-prepared texts from the provider.
+    Providers and local models that ignore the *dims* setting return
+    longer vectors, which are truncated to *dims* here.
+    """
+    embedder = _get_embedder(model, dims)
+    embed = embedder.embed_query if mode == "query" else embedder.embed_documents
+
+    async def embed_all(batch: list[str]) -> list[list[float]]:
+        result = await with_retries(lambda: embed(batch), max_attempts=max_attempts)
+        # Results are matched to inputs by position, which relies on the
+        # provider returning one embedding per input in input order.
+        if len(result.embeddings) != len(batch):
+            raise RuntimeError(
+                f"Embedding provider returned {len(result.embeddings)} vectors "
+                f"for {len(batch)} inputs."
+            )
+        return [list(e) for e in result.embeddings]
+
+    dense = await embed_all(texts)
+    empty = [i for i, vector in enumerate(dense) if not any(vector)]
+    if retry_on_empty and empty:
+        logger.warning(
+            "Embedding returned %d empty vector(s); retrying them once", len(empty)
+        )
+        retried = await embed_all([texts[i] for i in empty])
+        for i, vector in zip(empty, retried):
+            dense[i] = vector
+    if dims is not None:
+        dense = [truncate_dims(vector, dims) for vector in dense]
+    return dense
 
-    dense = list(result.embeddings[0])
-    sparse = to_sparse_threshold(dense, threshold, normalise=True)
 
+def _to_result(dense: list[float], model: str, threshold: float) -> EmbedResult:
+    """Sparsify a dense vector into an :class:`EmbedResult`."""
+    sparse = to_sparse_threshold(dense, threshold, normalise=True)
     return EmbedResult(
         vector=sparse,
         model=model,
@@ -101,6 +232,41 @@ async def embed_single(
     )
 
 
+async def embed_single(
+    text: str,
+    *,
+    model: str,
+    mode: str = "query",
+    dims: int | None = None,
+    threshold: float = 0.01,
+    normalise_text: bool = False,
+    max_attempts: int = DEFAULT_MAX_ATTEMPTS,
+    retry_on_empty: bool = False,
+    cache: EmbeddingCache | None = None,
+) -> EmbedResult:
+    """
+    Embed a single text and return a sparse vector result.
+
+    When *normalise_text* is True the text is lowercased, NFC-composed
+    and whitespace-collapsed before embedding. Transient provider errors
+    (429, 5xx, network failures) are retried up to *max_attempts* times.
+    When *retry_on_empty* is True, an empty or all-zero embedding is
+    requested once more, since some embedders return one transiently.
+    Pass the same *cache* across calls to avoid re-embedding repeated text.
+    """
+    [dense] = await _embed_dense(
+        [text],
+        model=model,
+        mode=mode,
+        dims=dims,
+        normalise_text=normalise_text,
+        max_attempts=max_attempts,
+        retry_on_empty=retry_on_empty,
+        cache=cache,
+    )
+    return _to_result(dense, model, threshold)
+
+
 async def embed_batch(
     texts: Sequence[str],
     *,
@@ -108,25 +274,75 @@ async def embed_batch(
     mode: str = "document",
     dims: int | None = None,
     threshold: float = 0.01,
+    normalise_text: bool = False,
+    max_attempts: int = DEFAULT_MAX_ATTEMPTS,
+    retry_on_empty: bool = False,
+    cache: EmbeddingCache | None = None,
 ) -> list[EmbedResult]:
-    """Embed multiple texts in one call and return sparse vector results."""
-    embedder = _build_embedder(model, dims)
-    result = await embedder.embed_documents(list(texts))
-
-    results: list[EmbedResult] = []
-    for embedding in result.embeddings:
-        dense = list(embedding)
-        sparse = to_sparse_threshold(dense, threshold, normalise=True)
-        results.append(
-            EmbedResult(
-                vector=sparse,
-                model=model,
-                dense_dimensions=len(dense),
-                non_zero_count=len(sparse),
-                threshold=threshold,
-            )
-        )
-    return results
+    """
+    Embed multiple texts in one call and return sparse vector results.
+
+    The i-th result belongs to the i-th text. Providers are trusted to
+    return embeddings in input order; a count mismatch raises an error.
+    *normalise_text*, *max_attempts*, *retry_on_empty* and *cache* behave
+    as in :func:`embed_single`; only the empty results are re-requested.
+    """
+    dense_vectors = await _embed_dense(
+        texts,
+        model=model,
+        mode=mode,
+        dims=dims,
+        normalise_text=normalise_text,
+        max_attempts=max_attempts,
+        retry_on_empty=retry_on_empty,
+        cache=cache,
+    )
+    return [_to_result(dense, model, threshold) for dense in dense_vectors]
+
+
+async def embed_expanded_query(
+    text: str,
+    *,
+    synonyms: dict[str, list[str]],
+    model: str,
+    max_variants: int = DEFAULT_MAX_VARIANTS,
+    dims: int | None = None,
+    threshold: float = 0.01,
+    normalise_text: bool = False,
+    max_attempts: int = DEFAULT_MAX_ATTEMPTS,
+    retry_on_empty: bool = False,
+    cache: EmbeddingCache | None = None,
+) -> EmbedResult:
+    """
+    Embed a query together with synonym rewordings of it and return the
+    mean of their sparse vectors, so the search also reaches memories
+    worded with the synonyms.
+
+    The variants come from :func:`~vecfs_embed.expand.query_variants` and
+    are embedded in one provider call. With no applicable synonyms this
+    is the same as :func:`embed_single` in query mode. Other arguments
+    behave as in :func:`embed_single`.
+    """
+    variants = query_variants(text, synonyms, max_variants)
+    results = await embed_batch(
+        variants,
+        model=model,
+        mode="query",
+        dims=dims,
+        threshold=threshold,
+        normalise_text=normalise_text,
+        max_attempts=max_attempts,
+        retry_on_empty=retry_on_empty,
+        cache=cache,
+    )
+    vector = sparse_mean([r.vector for r in results])
+    return EmbedResult(
+        vector=vector,
+        model=model,
+        dense_dimensions=results[0].dense_dimensions,
+        non_zero_count=len(vector),
+        threshold=threshold,
+    )
 
 
 async def calibrate(
@@ -134,15 +350,26 @@ async def calibrate(
     *,
     model: str,
     dims: int | None = None,
+    normalise_text: bool = False,
+    max_attempts: int = DEFAULT_MAX_ATTEMPTS,
+    retry_on_empty: bool = False,
 ) -> CalibrateResult:
     """
     Embed a batch of sample texts and report magnitude statistics
     to help choose a sparsification threshold.
-    """
-    embedder = _build_embedder(model, dims)
-    result = await embedder.embed_documents(list(texts))
 
-    dense_vectors = [list(e) for e in result.embeddings]
+    Pass the same *normalise_text* setting used for embedding so the
+    statistics reflect the vectors that will actually be stored.
+    """
+    dense_vectors = await _embed_dense(
+        texts,
+        model=model,
+        mode="document",
+        dims=dims,
+        normalise_text=normalise_text,
+        max_attempts=max_attempts,
+        retry_on_empty=retry_on_empty,
+    )
     stats = magnitude_stats(dense_vectors)
     sparsity = sparsity_at_thresholds(dense_vectors)
 
@@ -153,3 +380,30 @@ async def calibrate(
         magnitude_stats=stats,
         sparsity_at_thresholds=sparsity,
     )
+
+
+async def warm_up(
+    *,
+    model: str,
+    dims: int | None = None,
+    max_attempts: int = DEFAULT_WARMUP_ATTEMPTS,
+) -> float:
+    """
+    Embed a trivial text so the model is loaded before the first real call,
+    and return the seconds it took.
+
+    Inference servers such as TEI load their model on the first request
+    and answer 503 until it is ready, and local models are downloaded on
+    first use. Transient failures are retried up to *max_attempts* times,
+    so this returns once the model answers and raises if it never does.
+    """
+    started = time.monotonic()
+    await _embed_dense(
+        [WARMUP_TEXT],
+        model=model,
+        mode="query",
+        dims=dims,
+        normalise_text=False,
+        max_attempts=max_attempts,
+    )
+    return time.monotonic() - started
@@ -0,0 +1,57 @@
+"""
+Synonym-based query expansion.
+
+A query only matches stored text that uses similar wording, so "auto
+repair" can miss a memory about "car repair". Expansion embeds a few
+rewordings of the query, made by swapping in configured synonyms, and
+searches with the mean of their vectors.
+"""
+
+from __future__ import annotations
+
+import json
+from pathlib import Path
+
+DEFAULT_MAX_VARIANTS = 3
+
+
+def load_synonyms(path: str | Path) -> dict[str, list[str]]:
+    """
+    Read a synonyms file: a JSON object mapping a word to a list of
+    alternatives, e.g. ``{"auto": ["car", "vehicle"]}``.
+
+    Keys are lowercased so lookups are case-insensitive.
+    """
+    data = json.loads(Path(path).read_text(encoding="utf-8"))
+    if not isinstance(data, dict) or not all(
+        isinstance(v, list) and all(isinstance(s, str) for s in v)
+        for v in data.values()
+    ):
+        raise ValueError(
+            f"Synonyms file {path} must be a JSON object of word to list of words."
+        )
+    return {str(k).lower(): v for k, v in data.items()}
+
+
+def query_variants(
+    text: str,
+    synonyms: dict[str, list[str]],
+    max_variants: int = DEFAULT_MAX_VARIANTS,
+) -> list[str]:
+    """
+    Return *text* followed by up to *max_variants* rewordings of it.
+
+    Each variant replaces one word that has synonyms with one of them,
+    taking words left to right and synonyms in file order. Duplicates
+    are dropped.
+    """
+    words = text.split()
+    variants = [text]
+    for i, word in enumerate(words):
+        for synonym in synonyms.get(word.lower(), []):
+            if len(variants) > max_variants:
+                return variants
+            variant = " ".join(words[:i] + [synonym] + words[i + 1 :])
+            if variant not in variants:
+                variants.append(variant)
+    return variants
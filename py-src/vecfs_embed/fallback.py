@@ -0,0 +1,66 @@
+"""
+Fall back to other embedding models when the first one fails.
+
+A hosted endpoint can be down while a local inference server serving the
+same model is up. A model setting listing several models separated by
+commas, such as ``<primary>:<model>,<fallback>:<model>``, tries each in
+order and uses the first that answers. The models should
+produce vectors in the same space; otherwise memories embedded through
+the fallback will not match queries embedded through the primary.
+"""
+
+from __future__ import annotations
+
+import logging
+from typing import Any, Awaitable, Callable, Sequence
+
+logger = logging.getLogger(__name__)
+
+MODEL_SEPARATOR = ","
+
+
+def split_models(model: str) -> list[str]:
+    """Split a model setting into its models, primary first."""
+    return [m.strip() for m in model.split(MODEL_SEPARATOR) if m.strip()]
+
+
+class FallbackEmbedder:
+    """
+    An embedder that tries each of several named embedders in order.
+
+    Each embedder needs the ``embed_query`` and ``embed_documents``
+    methods of a Pydantic AI ``Embedder``. A call that raises falls
+    through to the next embedder with a warning; the error from the last
+    one is raised if none succeeds.
+    """
+
+    def __init__(self, embedders: Sequence[tuple[str, Any]]) -> None:
+        if not embedders:
+            raise ValueError("FallbackEmbedder needs at least one embedder")
+        self._embedders = list(embedders)
+
+    async def embed_query(self, texts: Sequence[str]) -> Any:
+        return await self._first_success(lambda e: e.embed_query(texts))
+
+    async def embed_documents(self, texts: Sequence[str]) -> Any:
+        return await self._first_success(lambda e: e.embed_documents(texts))
+
+    async def _first_success(self, call: Callable[[Any], Awaitable[Any]]) -> Any:
+        *fallible, (last_name, last) = self._embedders
+        for position, (name, embedder) in enumerate(fallible):
+            try:
+                result = await call(embedder)
+            except Exception as error:
+                logger.warning(
+                    "Embedding with %s failed (%s); trying the next model",
+                    name,
+                    error,
+                )
+                continue
+            if position > 0:
+                logger.info("Embedded with fallback model %s", name)
+            return result
+        result = await call(last)
+        if fallible:
+            logger.info("Embedded with fallback model %s", last_name)
+        return result
@@ -0,0 +1,42 @@
+"""
+Default embedding models per provider.
+
+Model strings take the Pydantic AI form ``provider:model``. A bare
+provider name such as ``openai`` selects that provider's recommended
+embedding model, because a single global default only suits one
+provider.
+"""
+
+from __future__ import annotations
+
+from .fallback import MODEL_SEPARATOR, split_models
+
+DEFAULT_MODEL = "sentence-transformers:all-MiniLM-L6-v2"
+
+PROVIDER_DEFAULT_MODELS: dict[str, str] = {
+    "sentence-transformers": "all-MiniLM-L6-v2",
+    "openai": "text-embedding-3-small",
+    "google-gla": "gemini-embedding-001",
+    "google-vertex": "gemini-embedding-001",
+    "cohere": "embed-v4.0",
+    "voyageai": "voyage-3.5",
+}
+
+
+def resolve_model(model: str | None) -> str:
+    """
+    Resolve a model setting to a full ``provider:model`` string.
+
+    An empty setting falls back to ``DEFAULT_MODEL``. A known provider
+    name without a model picks that provider's default. Anything else is
+    returned unchanged for Pydantic AI to interpret. A comma-separated
+    list of fallback models is resolved model by model.
+    """
+    if not model:
+        return DEFAULT_MODEL
+    if MODEL_SEPARATOR in model:
+        return MODEL_SEPARATOR.join(resolve_model(m) for m in split_models(model))
+    provider = model.rstrip(":")
+    if ":" not in provider and provider in PROVIDER_DEFAULT_MODELS:
+        return f"{provider}:{PROVIDER_DEFAULT_MODELS[provider]}"
+    return model
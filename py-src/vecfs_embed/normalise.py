@@ -0,0 +1,32 @@
+"""
+Text normalisation applied before embedding.
+
+Embedding models treat casing, Unicode composition and runs of
+whitespace as meaningful, so "Hello  World" and "hello world" produce
+different vectors. Normalising both the stored text and the query the
+same way makes them match.
+"""
+
+from __future__ import annotations
+
+import unicodedata
+from typing import Sequence
+
+
+def normalise_text(text: str) -> str:
+    """
+    Normalise text for embedding.
+
+    Applies Unicode NFC composition, lowercases, and collapses every
+    run of whitespace to a single space with no leading or trailing
+    whitespace.
+    """
+    composed = unicodedata.normalize("NFC", text)
+    return " ".join(composed.lower().split())
+
+
+def prepare_texts(texts: Sequence[str], *, normalise: bool) -> list[str]:
+    """Return *texts* as a list, normalised when *normalise* is True."""
+    if not normalise:
+        return list(texts)
+    return [normalise_text(t) for t in texts]
@@ -0,0 +1,45 @@
+"""
+Thread-safe reuse of embedder instances.
+
+Building an embedder can be expensive: a local Sentence Transformers
+model is loaded from disk and a cloud provider sets up an HTTP client.
+The registry builds each distinct configuration once, on first use, and
+hands the same instance to every later caller.
+"""
+
+from __future__ import annotations
+
+import threading
+from typing import Callable, Generic, Hashable, TypeVar
+
+E = TypeVar("E")
+
+
+class EmbedderRegistry(Generic[E]):
+    """
+    Lazily built, shared embedders keyed by their configuration.
+
+    *factory* is called with the key's ``(model, dims)`` settings the
+    first time a configuration is requested. A lock makes concurrent
+    first requests for the same key build only one instance.
+    """
+
+    def __init__(self, factory: Callable[[str, int | None], E]) -> None:
+        self._factory = factory
+        self._instances: dict[Hashable, E] = {}
+        self._lock = threading.Lock()
+
+    def get(self, model: str, dims: int | None = None) -> E:
+        """Return the embedder for *model* and *dims*, building it if needed."""
+        key = (model, dims)
+        with self._lock:
+            instance = self._instances.get(key)
+            if instance is None:
+                instance = self._factory(model, dims)
+                self._instances[key] = instance
+            return instance
+
+    def clear(self) -> None:
+        """Drop every cached instance so the next request rebuilds it."""
+        with self._lock:
+            self._instances.clear()
@@ -0,0 +1,71 @@
+"""
+Retry with exponential backoff for transient embedding failures.
+
+Local inference servers such as TEI reject requests while warming up,
+and hosted providers return 429 or 503 under load. Retrying those a few
+times turns a failed agent step into a short delay. Client errors such
+as 400 or 401 are never retried because repeating them cannot succeed.
+"""
+
+from __future__ import annotations
+
+import asyncio
+import logging
+from typing import Awaitable, Callable, TypeVar
+
+T = TypeVar("T")
+
+DEFAULT_MAX_ATTEMPTS = 3
+DEFAULT_BASE_DELAY = 0.5
+
+logger = logging.getLogger(__name__)
+
+
+def is_transient(error: BaseException) -> bool:
+    """
+    Return True for errors worth retrying: HTTP 429 and 5xx responses,
+    and network-level failures (connection refused, resets, timeouts).
+    """
+    status = getattr(error, "status_code", None)
+    if isinstance(status, int):
+        return status == 429 or status >= 500
+    if isinstance(error, (ConnectionError, TimeoutError)):
+        return True
+    try:
+        import httpx
+    except ImportError:
+        return False
+    return isinstance(error, httpx.TransportError)
+
+
+async def with_retries(
+    call: Callable[[], Awaitable[T]],
+    *,
+    max_attempts: int = DEFAULT_MAX_ATTEMPTS,
+    base_delay: float = DEFAULT_BASE_DELAY,
+    sleep: Callable[[float], Awaitable[object]] | None = None,
+) -> T:
+    """
+    Await *call*, retrying transient failures up to *max_attempts* times
+    in total. The delay doubles after each failure, starting at
+    *base_delay* seconds. The last error is raised if every attempt fails.
+    *sleep* defaults to :func:`asyncio.sleep`.
+    """
+    if max_attempts < 1:
+        raise ValueError(f"max_attempts must be at least 1, got {max_attempts}")
+    for attempt in range(1, max_attempts + 1):
+        try:
+            return await call()
+        except Exception as error:
+            if attempt >= max_attempts or not is_transient(error):
+                raise
+            delay = base_delay * 2 ** (attempt - 1)
+            logger.warning(
+                "Embedding attempt %d/%d failed (%s); retrying in %.1fs",
+                attempt,
+                max_attempts,
+                error,
+                delay,
+            )
+            await (sleep or asyncio.sleep)(delay)
+    raise AssertionError("unreachable")  # the loop always returns or raises
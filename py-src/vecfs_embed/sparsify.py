@@ -23,6 +23,22 @@ def l2_normalise(dense: Sequence[float]) -> list[float]:
     return [v / norm for v in dense]
 
 
+def truncate_dims(dense: Sequence[float], dims: int) -> list[float]:
+    """
+    Keep the first *dims* components of a dense vector.
+
+    Models trained for shortened embeddings, such as OpenAI's
+    text-embedding-3 family, put the most information in the leading
+    dimensions, and truncation is how their own ``dimensions`` setting
+    works. Unlike a random projection or feature hashing it needs no
+    shared seed, so the same text always reduces to the same vector.
+    Vectors already no longer than *dims* are returned unchanged.
+    """
+    if dims < 1:
+        raise ValueError(f"dims must be at least 1, got {dims}")
+    return list(dense[:dims])
+
+
 def to_sparse_threshold(
     dense: Sequence[float],
     threshold: float = 0.01,
@@ -61,6 +77,23 @@ def to_sparse_topk(
     return {str(i): v for i, v in indexed[:k] if v != 0.0}
 
 
+def sparse_mean(vectors: Sequence[dict[str, float]]) -> dict[str, float]:
+    """
+    Return the component-wise mean of sparse vectors.
+
+    A dimension missing from a vector counts as zero, so it is kept with
+    a proportionally smaller weight. Returns an empty dict for no input.
+    """
+    if not vectors:
+        return {}
+    totals: dict[str, float] = {}
+    for vector in vectors:
+        for key, value in vector.items():
+            totals[key] = totals.get(key, 0.0) + value
+    n = len(vectors)
+    return {key: total / n for key, total in totals.items() if total != 0.0}
+
+
 def magnitude_stats(vectors: list[list[float]]) -> dict:
     """
     Compute magnitude statistics across a batch of dense vectors.
@@ -0,0 +1,106 @@
+import { z } from "zod";
+import { similarityHistogram } from "./histogram.js";
+import { ToolCallContext } from "./tool-timeout.js";
+import { mean } from "./sparse-vector.js";
+import { VecFSEntry } from "./types.js";
+import { RpcError, INVALID_PARAMS_CODE } from "./rpc-errors.js";
+import {
+  ToolContext,
+  ToolHandlerMap,
+  ToolResult,
+  enterStage,
+} from "./tool-context.js";
+import {
+  vectorShapeSchema,
+  ensureVectorIsObjectOrArray,
+  namespaceSchema,
+  filterSchema,
+  validateArgs,
+  normalizeVector,
+} from "./tool-args.js";
+
+const histogramArgsSchema = z.object({
+  vector: vectorShapeSchema,
+  buckets: z.number().int().min(1).max(100).optional(),
+  namespace: namespaceSchema.optional(),
+});
+
+const centroidArgsSchema = z.object({
+  ids: z.array(z.string()).min(1).optional(),
+  filter: filterSchema.optional(),
+  limit: z.number().int().positive().optional(),
+  namespace: namespaceSchema.optional(),
+});
+
+/**
+ * Builds the tools that describe the store as a whole:
+ * `similarity_histogram` and `centroid`.
+ */
+export function analysisTools(context: ToolContext): ToolHandlerMap {
+  const { storageFor } = context;
+  return {
+    async similarity_histogram(
+      args: unknown,
+      ctx?: ToolCallContext,
+    ): Promise<ToolResult> {
+      const { vector, buckets, namespace } = validateArgs(
+        histogramArgsSchema,
+        ensureVectorIsObjectOrArray(args),
+        "similarity_histogram",
+      );
+      enterStage(ctx, "storage");
+      const store = await storageFor(namespace);
+      const ranked = await store.rank(normalizeVector(vector));
+      enterStage(ctx, "render");
+      const histogram = similarityHistogram(
+        ranked.map((r) => r.similarity),
+        buckets,
+      );
+      return {
+        content: [{ type: "text", text: JSON.stringify(histogram, null, 2) }],
+      };
+    },
+
+    async centroid(args: unknown, ctx?: ToolCallContext): Promise<ToolResult> {
+      const { ids, filter, limit, namespace } = validateArgs(
+        centroidArgsSchema,
+        args,
+        "centroid",
+      );
+      if ((ids === undefined) === (filter === undefined)) {
+        throw new RpcError(
+          INVALID_PARAMS_CODE,
+          "Pass exactly one of 'ids' or 'filter'.",
+        );
+      }
+      enterStage(ctx, "storage");
+      const store = await storageFor(namespace);
+      const group: VecFSEntry[] = [];
+      // A repeated ID counts once, so it cannot pull the centroid its way.
+      for (const id of new Set(ids)) {
+        const entry = await store.get(id);
+        if (!entry) {
+          return {
+            content: [{ type: "text", text: `Entry not found: ${id}` }],
+          };
+        }
+        group.push(entry);
+      }
+      if (filter) group.push(...(await store.matching(filter)));
+      if (group.length === 0) {
+        return {
+          content: [{ type: "text", text: "No entries match the filter." }],
+        };
+      }
+      const centroid = mean(group.map((e) => e.vector));
+      const nearest = await store.search(centroid, limit);
+      enterStage(ctx, "render");
+      const text = JSON.stringify(
+        { centroid, count: group.length, nearest },
+        null,
+        2,
+      );
+      return { content: [{ type: "text", text }] };
+    },
+  };
+}
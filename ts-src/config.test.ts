@@ -0,0 +1,173 @@
+import { describe, it, expect } from "vitest";
+import { loadConfig, resolveDataFile } from "./config.js";
+import { registerSimilarity } from "./sparse-vector.js";
+import * as path from "path";
+
+describe("loadConfig", () => {
+  it("should apply defaults for an empty environment", () => {
+    const config = loadConfig({});
+    expect(config.dataFile).toBe("./vecfs-data.jsonl");
+    expect(config.port).toBe(3000);
+    expect(config.shardDir).toBeUndefined();
+    expect(config.storage.appendOnly).toBe(false);
+    expect(config.storage.tombstoneDeletes).toBe(false);
+    expect(config.storage.batchWrites).toBe(false);
+    expect(config.tools.emptyResultMessage).toBeUndefined();
+  });
+
+  it("should read values from the environment", () => {
+    const config = loadConfig({
+      VECFS_FILE: "/tmp/memory.jsonl",
+      PORT: "8080",
+      VECFS_APPEND_ONLY: "true",
+      VECFS_TOMBSTONE_DELETES: "1",
+      VECFS_EMPTY_RESULT_MESSAGE: "Nothing found.",
+      VECFS_STORE_TEXT: "truncated",
+      VECFS_STORE_TEXT_MAX_CHARS: "50",
+      VECFS_MAX_RESPONSE_BYTES: "65536",
+      VECFS_SEARCH_CHUNK_BYTES: "16384",
+      VECFS_TOOL_TIMEOUT_MS: "5000",
+      VECFS_TERMS_FILE: "/tmp/terms.json",
+      VECFS_SEARCH_CANDIDATE_CAP: "1000",
+      VECFS_SEARCH_METRIC: "dot",
+      VECFS_SCORE_FLUSH_MS: "2000",
+      VECFS_SCORE_FLUSH_THRESHOLD: "50",
+      VECFS_BATCH_WRITES: "true",
+      VECFS_FLUSH_INTERVAL_MS: "1000",
+      VECFS_FSYNC: "1",
+      VECFS_CHECKSUMS: "true",
+      VECFS_MAX_ENTRIES: "10000",
+      VECFS_EVICTION: "lowest_score",
+      VECFS_SOFT_DELETES: "true",
+      VECFS_VECTOR_FIELD: "embedding",
+      VECFS_KEEP_VERSIONS: "3",
+      VECFS_BACKUPS: "4",
+      VECFS_SCORE_HALF_LIFE_DAYS: "14",
+    });
+    expect(config.dataFile).toBe("/tmp/memory.jsonl");
+    expect(config.port).toBe(8080);
+    expect(config.toolTimeoutMs).toBe(5000);
+    expect(config.storage.appendOnly).toBe(true);
+    expect(config.storage.tombstoneDeletes).toBe(true);
+    expect(config.storage.termsFile).toBe("/tmp/terms.json");
+    expect(config.storage.candidateCap).toBe(1000);
+    expect(config.storage.metric).toBe("dot");
+    expect(config.storage.scoreFlushMs).toBe(2000);
+    expect(config.storage.scoreFlushThreshold).toBe(50);
+    expect(config.storage.batchWrites).toBe(true);
+    expect(config.storage.flushIntervalMs).toBe(1000);
+    expect(config.storage.fsync).toBe(true);
+    expect(config.storage.checksums).toBe(true);
+    expect(config.storage.maxEntries).toBe(10000);
+    expect(config.storage.eviction).toBe("lowest_score");
+    expect(config.storage.softDeletes).toBe(true);
+    expect(config.storage.vectorField).toBe("embedding");
+    expect(config.storage.keepVersions).toBe(3);
+    expect(config.storage.backups).toBe(4);
+    expect(config.storage.scoreHalfLifeMs).toBe(14 * 24 * 60 * 60 * 1000);
+    expect(config.tools.emptyResultMessage).toBe("Nothing found.");
+    expect(config.tools.storeText).toBe("truncated");
+    expect(config.tools.storeTextMaxChars).toBe(50);
+    expect(config.tools.maxResponseBytes).toBe(65536);
+    expect(config.tools.chunkBytes).toBe(16384);
+  });
+
+  it("should reject an unknown store-text mode", () => {
+    expect(() => loadConfig({ VECFS_STORE_TEXT: "partial" })).toThrow(
+      "VECFS_STORE_TEXT",
+    );
+  });
+
+  it("should reject an unknown search metric", () => {
+    expect(() => loadConfig({ VECFS_SEARCH_METRIC: "euclid" })).toThrow(
+      "VECFS_SEARCH_METRIC",
+    );
+  });
+
+  it("should accept a registered custom search metric", () => {
+    registerSimilarity("configTestMetric", () => 0);
+
+    const config = loadConfig({ VECFS_SEARCH_METRIC: "configTestMetric" });
+    expect(config.storage.metric).toBe("configTestMetric");
+    const builtIn = loadConfig({ VECFS_SEARCH_METRIC: "Euclidean" });
+    expect(builtIn.storage.metric).toBe("euclidean");
+  });
+
+  it("should reject an unknown eviction policy", () => {
+    expect(() => loadConfig({ VECFS_EVICTION: "random" })).toThrow(
+      "VECFS_EVICTION",
+    );
+  });
+
+  it("should reject a non-positive integer setting", () => {
+    expect(() => loadConfig({ VECFS_STORE_TEXT_MAX_CHARS: "-5" })).toThrow(
+      "positive integer",
+    );
+  });
+
+  it("should reject an invalid port", () => {
+    for (const port of ["0", "-1", "http", "80.5"]) {
+      expect(() => loadConfig({ PORT: port })).toThrow(
+        "PORT must be a positive integer",
+      );
+    }
+    expect(() => loadConfig({ PORT: "65536" })).toThrow(
+      "PORT must be between 1 and 65535",
+    );
+    expect(loadConfig({ PORT: "65535" }).port).toBe(65535);
+    expect(loadConfig({}).port).toBe(3000);
+  });
+
+  it("should read a fractional feedback weight", () => {
+    const config = loadConfig({ VECFS_RANKING_FEEDBACK_WEIGHT: "0.5" });
+    expect(config.storage.feedbackWeight).toBe(0.5);
+    expect(loadConfig({}).storage.feedbackWeight).toBeUndefined();
+    expect(() =>
+      loadConfig({ VECFS_RANKING_FEEDBACK_WEIGHT: "strong" }),
+    ).toThrow("VECFS_RANKING_FEEDBACK_WEIGHT must be a positive number");
+  });
+});
+
+describe("resolveDataFile", () => {
+  const base = path.resolve("/srv/vecfs");
+
+  it("should leave the path alone without a base directory", () => {
+    expect(resolveDataFile("../data.jsonl")).toBe("../data.jsonl");
+  });
+
+  it("should accept paths inside the base directory", () => {
+    expect(resolveDataFile("memory.jsonl", base)).toBe(
+      path.join(base, "memory.jsonl"),
+    );
+    expect(resolveDataFile(path.join(base, "a/b.jsonl"), base)).toBe(
+      path.join(base, "a/b.jsonl"),
+    );
+    expect(resolveDataFile("..data.jsonl", base)).toBe(
+      path.join(base, "..data.jsonl"),
+    );
+  });
+
+  it("should reject traversal outside the base directory", () => {
+    expect(() => resolveDataFile("../../etc/passwd", base)).toThrow(
+      "outside VECFS_BASE_DIR",
+    );
+    expect(() => resolveDataFile("/etc/passwd", base)).toThrow(
+      "outside VECFS_BASE_DIR",
+    );
+  });
+
+  it("should apply VECFS_BASE_DIR when loading config", () => {
+    expect(() =>
+      loadConfig({ VECFS_BASE_DIR: base, VECFS_FILE: "../x.jsonl" }),
+    ).toThrow("outside VECFS_BASE_DIR");
+  });
+
+  it("should confine VECFS_SHARD_DIR to the base directory", () => {
+    expect(
+      loadConfig({ VECFS_BASE_DIR: base, VECFS_SHARD_DIR: "shards" }).shardDir,
+    ).toBe(path.join(base, "shards"));
+    expect(() =>
+      loadConfig({ VECFS_BASE_DIR: base, VECFS_SHARD_DIR: "../shards" }),
+    ).toThrow("VECFS_SHARD_DIR '../shards' resolves outside VECFS_BASE_DIR");
+  });
+});
@@ -0,0 +1,187 @@
+import { StorageOptions } from "./storage-options.js";
+import { EvictionPolicy } from "./retention.js";
+import { ToolHandlerOptions, StoreTextMode } from "./tool-context.js";
+import { SimilarityMetric } from "./types.js";
+import { similarityNames } from "./sparse-vector.js";
+import * as path from "path";
+
+/**
+ * Runtime configuration for the VecFS MCP server.
+ *
+ * MCP clients can only pass settings to a server through environment
+ * variables, so every tuneable is read from the environment here.
+ */
+export interface ServerConfig {
+  /** Path to the JSONL storage file (`VECFS_FILE`). */
+  dataFile: string;
+  /** Directory of per-namespace storage files (`VECFS_SHARD_DIR`). */
+  shardDir?: string;
+  /** Port for HTTP/SSE mode (`PORT`). */
+  port: number;
+  /** Deadline for one tool call in ms (`VECFS_TOOL_TIMEOUT_MS`). */
+  toolTimeoutMs?: number;
+  /** Persistence options passed to the storage layer. */
+  storage: StorageOptions;
+  /** Response options passed to the tool handlers. */
+  tools: ToolHandlerOptions;
+}
+
+/** Reads a boolean flag; only "true" and "1" enable it. */
+function envFlag(env: NodeJS.ProcessEnv, name: string): boolean {
+  const value = env[name]?.trim().toLowerCase();
+  return value === "true" || value === "1";
+}
+
+/** Reads an optional positive integer. */
+function envInt(env: NodeJS.ProcessEnv, name: string): number | undefined {
+  const raw = env[name]?.trim();
+  if (!raw) return undefined;
+  const value = Number(raw);
+  if (!Number.isInteger(value) || value <= 0) {
+    throw new Error(`${name} must be a positive integer, got '${raw}'.`);
+  }
+  return value;
+}
+
+/** Highest valid TCP port. */
+const MAX_PORT = 65535;
+
+/** Reads the HTTP port, defaulting to 3000 and rejecting invalid ports. */
+function envPort(env: NodeJS.ProcessEnv): number {
+  const port = envInt(env, "PORT") ?? 3000;
+  if (port > MAX_PORT) {
+    throw new Error(`PORT must be between 1 and ${MAX_PORT}, got '${port}'.`);
+  }
+  return port;
+}
+
+/** Reads an optional positive number, which may have a fraction. */
+function envNumber(env: NodeJS.ProcessEnv, name: string): number | undefined {
+  const raw = env[name]?.trim();
+  if (!raw) return undefined;
+  const value = Number(raw);
+  if (!Number.isFinite(value) || value <= 0) {
+    throw new Error(`${name} must be a positive number, got '${raw}'.`);
+  }
+  return value;
+}
+
+/** Reads an optional positive whole number of days, in milliseconds. */
+function envDaysMs(env: NodeJS.ProcessEnv, name: string): number | undefined {
+  const days = envInt(env, name);
+  return days === undefined ? undefined : days * 24 * 60 * 60 * 1000;
+}
+
+/** Reads the store-text mode, rejecting unknown values. */
+function envStoreText(env: NodeJS.ProcessEnv): StoreTextMode | undefined {
+  const raw = env.VECFS_STORE_TEXT?.trim().toLowerCase();
+  if (!raw) return undefined;
+  if (raw === "full" || raw === "truncated" || raw === "none") return raw;
+  throw new Error(
+    `VECFS_STORE_TEXT must be one of full, truncated or none, got '${raw}'.`,
+  );
+}
+
+/**
+ * Reads the default search metric, rejecting names that are not
+ * registered. The server loads `VECFS_METRICS_MODULE` before its config,
+ * so metrics registered there can be named here.
+ */
+function envMetric(env: NodeJS.ProcessEnv): SimilarityMetric | undefined {
+  const raw = env.VECFS_SEARCH_METRIC?.trim();
+  if (!raw) return undefined;
+  const names = similarityNames();
+  const metric = names.includes(raw) ? raw : raw.toLowerCase();
+  if (names.includes(metric)) return metric;
+  throw new Error(
+    `VECFS_SEARCH_METRIC must be one of ${names.join(", ")}, got '${raw}'.`,
+  );
+}
+
+/** Reads the eviction policy, rejecting unknown values. */
+function envEviction(env: NodeJS.ProcessEnv): EvictionPolicy | undefined {
+  const raw = env.VECFS_EVICTION?.trim().toLowerCase();
+  if (!raw) return undefined;
+  if (raw === "reject" || raw === "lru" || raw === "lowest_score") return raw;
+  throw new Error(
+    `VECFS_EVICTION must be one of reject, lru or lowest_score, got '${raw}'.`,
+  );
+}
+
+/**
+ * Resolves the storage file path, confining it to `baseDir` when one is
+ * given. A relative path is then taken relative to the base, and any path
+ * that resolves outside it, such as `../../etc/passwd`, is rejected.
+ * Symbolic links are not followed.
+ *
+ * @param name - The variable the path came from, for the error message.
+ */
+export function resolveDataFile(
+  file: string,
+  baseDir?: string,
+  name = "VECFS_FILE",
+): string {
+  if (!baseDir) return file;
+  const base = path.resolve(baseDir);
+  const resolved = path.resolve(base, file);
+  const relative = path.relative(base, resolved);
+  const escapes = relative === ".." || relative.startsWith(`..${path.sep}`);
+  if (escapes || path.isAbsolute(relative)) {
+    throw new Error(
+      `${name} '${file}' resolves outside VECFS_BASE_DIR '${base}'.`,
+    );
+  }
+  return resolved;
+}
+
+/**
+ * Builds the server configuration from environment variables.
+ *
+ * @param env - The environment to read. Defaults to `process.env`.
+ * @throws Error if a variable holds a value that cannot be used.
+ */
+export function loadConfig(env: NodeJS.ProcessEnv = process.env): ServerConfig {
+  return {
+    dataFile: resolveDataFile(
+      env.VECFS_FILE || "./vecfs-data.jsonl",
+      env.VECFS_BASE_DIR || undefined,
+    ),
+    shardDir: env.VECFS_SHARD_DIR
+      ? resolveDataFile(
+          env.VECFS_SHARD_DIR,
+          env.VECFS_BASE_DIR || undefined,
+          "VECFS_SHARD_DIR",
+        )
+      : undefined,
+    port: envPort(env),
+    toolTimeoutMs: envInt(env, "VECFS_TOOL_TIMEOUT_MS"),
+    storage: {
+      appendOnly: envFlag(env, "VECFS_APPEND_ONLY"),
+      tombstoneDeletes: envFlag(env, "VECFS_TOMBSTONE_DELETES"),
+      termsFile: env.VECFS_TERMS_FILE || undefined,
+      candidateCap: envInt(env, "VECFS_SEARCH_CANDIDATE_CAP"),
+      metric: envMetric(env),
+      scoreFlushMs: envInt(env, "VECFS_SCORE_FLUSH_MS"),
+      scoreFlushThreshold: envInt(env, "VECFS_SCORE_FLUSH_THRESHOLD"),
+      batchWrites: envFlag(env, "VECFS_BATCH_WRITES"),
+      flushIntervalMs: envInt(env, "VECFS_FLUSH_INTERVAL_MS"),
+      fsync: envFlag(env, "VECFS_FSYNC"),
+      checksums: envFlag(env, "VECFS_CHECKSUMS"),
+      maxEntries: envInt(env, "VECFS_MAX_ENTRIES"),
+      eviction: envEviction(env),
+      softDeletes: envFlag(env, "VECFS_SOFT_DELETES"),
+      vectorField: env.VECFS_VECTOR_FIELD?.trim() || undefined,
+      keepVersions: envInt(env, "VECFS_KEEP_VERSIONS"),
+      backups: envInt(env, "VECFS_BACKUPS"),
+      scoreHalfLifeMs: envDaysMs(env, "VECFS_SCORE_HALF_LIFE_DAYS"),
+      feedbackWeight: envNumber(env, "VECFS_RANKING_FEEDBACK_WEIGHT"),
+    },
+    tools: {
+      emptyResultMessage: env.VECFS_EMPTY_RESULT_MESSAGE || undefined,
+      storeText: envStoreText(env),
+      storeTextMaxChars: envInt(env, "VECFS_STORE_TEXT_MAX_CHARS"),
+      maxResponseBytes: envInt(env, "VECFS_MAX_RESPONSE_BYTES"),
+      chunkBytes: envInt(env, "VECFS_SEARCH_CHUNK_BYTES"),
+    },
+  };
+}
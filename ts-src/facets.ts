@@ -0,0 +1,77 @@
+import { VecFSEntry } from "./types.js";
+
+/**
+ * Counts how often each value of a metadata field occurs across entries.
+ *
+ * Scalar values are counted under their string form. Array values (such as
+ * a `tags` list) contribute one count per element. Entries without the
+ * field, or with an object value, are not counted.
+ *
+ * @param entries - The entries to tally, typically a scored search set.
+ * @param field - The metadata key to facet on.
+ * @returns A map of facet value to occurrence count.
+ */
+export function countFacets(
+  entries: VecFSEntry[],
+  field: string,
+): Record<string, number> {
+  const counts: Record<string, number> = {};
+  for (const entry of entries) {
+    const value = entry.metadata?.[field];
+    const values = Array.isArray(value) ? value : [value];
+    for (const v of values) {
+      if (v === undefined || v === null || typeof v === "object") continue;
+      const key = String(v);
+      counts[key] = (counts[key] ?? 0) + 1;
+    }
+  }
+  return counts;
+}
+
+/**
+ * Summary of one metadata key across a set of entries.
+ */
+export interface MetadataKeySummary {
+  /** Number of entries that carry the key. */
+  count: number;
+  /** Distinct JSON value types seen for the key, sorted alphabetically. */
+  types: string[];
+}
+
+/** Returns the JSON type name of a metadata value. */
+function jsonType(value: unknown): string {
+  if (value === null) return "null";
+  if (Array.isArray(value)) return "array";
+  return typeof value;
+}
+
+/**
+ * Lists every metadata key present across entries in a single pass,
+ * with how many entries carry it and which value types occur.
+ *
+ * @param entries - The entries to inspect.
+ * @returns A map of key to summary, ordered by key name.
+ */
+export function summarizeMetadataKeys(
+  entries: VecFSEntry[],
+): Record<string, MetadataKeySummary> {
+  const seen = new Map<string, { count: number; types: Set<string> }>();
+  for (const entry of entries) {
+    for (const [key, value] of Object.entries(entry.metadata ?? {})) {
+      if (value === undefined) continue;
+      let summary = seen.get(key);
+      if (!summary) {
+        summary = { count: 0, types: new Set() };
+        seen.set(key, summary);
+      }
+      summary.count++;
+      summary.types.add(jsonType(value));
+    }
+  }
+  const result: Record<string, MetadataKeySummary> = {};
+  for (const key of [...seen.keys()].sort()) {
+    const { count, types } = seen.get(key)!;
+    result[key] = { count, types: [...types].sort() };
+  }
+  return result;
+}
@@ -0,0 +1,9 @@
+/**
+ * A clock for the `clock` storage option that stands still until moved,
+ * by setting or adding to its `ms` property. Used by tests.
+ */
+export function fixedClock(ms: number) {
+  const clock = () => clock.ms;
+  clock.ms = ms;
+  return clock;
+}
@@ -0,0 +1,28 @@
+import { describe, it, expect } from "vitest";
+import { similarityHistogram } from "./histogram.js";
+
+describe("similarityHistogram", () => {
+  it("should use ten buckets by default", () => {
+    const histogram = similarityHistogram([0.05, 0.55, 0.95]);
+    expect(histogram).toHaveLength(10);
+    expect(histogram[0].count).toBe(1);
+    expect(histogram[5].count).toBe(1);
+    expect(histogram[9].count).toBe(1);
+  });
+
+  it("should place a similarity of 1 in the last bucket", () => {
+    const histogram = similarityHistogram([1], 4);
+    expect(histogram.map((b) => b.count)).toEqual([0, 0, 0, 1]);
+    expect(histogram[3].max).toBe(1);
+  });
+
+  it("should place negative similarities in the first bucket", () => {
+    const histogram = similarityHistogram([-0.5, -1], 2);
+    expect(histogram.map((b) => b.count)).toEqual([2, 0]);
+  });
+
+  it("should return empty buckets for no values", () => {
+    const histogram = similarityHistogram([], 3);
+    expect(histogram.map((b) => b.count)).toEqual([0, 0, 0]);
+  });
+});
@@ -0,0 +1,45 @@
+/**
+ * One bucket of a similarity histogram.
+ */
+export interface HistogramBucket {
+  /** Inclusive lower bound of the bucket. */
+  min: number;
+  /** Upper bound of the bucket (exclusive, except for the last bucket). */
+  max: number;
+  /** Number of similarities that fell in the bucket. */
+  count: number;
+}
+
+/** Default number of buckets in a similarity histogram. */
+export const DEFAULT_HISTOGRAM_BUCKETS = 10;
+
+/**
+ * Buckets similarity values evenly over the range 0 to 1.
+ *
+ * Used to choose a minimum-similarity threshold by showing how a query's
+ * similarities are distributed across the store. Negative similarities are
+ * counted in the first bucket and a similarity of exactly 1 in the last, so
+ * the counts always sum to the number of values.
+ *
+ * @param similarities - The similarity of each scored entry.
+ * @param buckets - Number of equal-width buckets. Defaults to 10.
+ * @returns The buckets in ascending order.
+ */
+export function similarityHistogram(
+  similarities: number[],
+  buckets: number = DEFAULT_HISTOGRAM_BUCKETS,
+): HistogramBucket[] {
+  const width = 1 / buckets;
+  const histogram: HistogramBucket[] = Array.from(
+    { length: buckets },
+    (_, i) => ({ min: i * width, max: (i + 1) * width, count: 0 }),
+  );
+  for (const similarity of similarities) {
+    const index = Math.min(
+      buckets - 1,
+      Math.max(0, Math.floor(similarity / width)),
+    );
+    histogram[index].count++;
+  }
+  return histogram;
+}
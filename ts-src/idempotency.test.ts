@@ -0,0 +1,58 @@
+import { describe, it, expect } from "vitest";
+import { IdempotencyCache } from "./idempotency.js";
+
+describe("IdempotencyCache", () => {
+  it("should run a key's call once and replay its result", async () => {
+    const cache = new IdempotencyCache<number>();
+    let calls = 0;
+    const call = async () => ++calls;
+
+    expect(await cache.run("k", call)).toBe(1);
+    expect(await cache.run("k", call)).toBe(1);
+    expect(await cache.run("other", call)).toBe(2);
+    expect(calls).toBe(2);
+  });
+
+  it("should share a call that is still running", async () => {
+    const cache = new IdempotencyCache<string>();
+    let calls = 0;
+    const call = () => {
+      calls++;
+      return new Promise<string>((resolve) =>
+        setTimeout(() => resolve("done"), 10),
+      );
+    };
+
+    const results = await Promise.all([
+      cache.run("k", call),
+      cache.run("k", call),
+    ]);
+    expect(results).toEqual(["done", "done"]);
+    expect(calls).toBe(1);
+  });
+
+  it("should forget a key once its TTL has passed", async () => {
+    let now = 0;
+    const cache = new IdempotencyCache<number>(1000, () => now);
+    let calls = 0;
+    const call = async () => ++calls;
+
+    await cache.run("k", call);
+    now = 999;
+    expect(await cache.run("k", call)).toBe(1);
+    now = 1000;
+    expect(await cache.run("k", call)).toBe(2);
+    expect(cache.size).toBe(1);
+  });
+
+  it("should forget a call that failed so it can be retried", async () => {
+    const cache = new IdempotencyCache<string>();
+
+    await expect(
+      cache.run("k", async () => {
+        throw new Error("disk full");
+      }),
+    ).rejects.toThrow("disk full");
+    expect(await cache.run("k", async () => "stored")).toBe("stored");
+  });
+});
@@ -0,0 +1,70 @@
+/**
+ * Short-lived memory of completed calls, keyed by a client-chosen
+ * idempotency key.
+ *
+ * A client that times out waiting for a write cannot tell whether it
+ * happened, so it retries. When the retry carries the same key as the
+ * original, the original's result is returned instead of writing again.
+ */
+
+/** How long a key is remembered by default: ten minutes. */
+export const DEFAULT_IDEMPOTENCY_TTL_MS = 10 * 60 * 1000;
+
+interface Remembered<T> {
+  result: Promise<T>;
+  expiresAt: number;
+}
+
+/**
+ * Remembers the result of each keyed call for `ttlMs`. The result is kept
+ * as a promise, so a retry that arrives while the original is still
+ * running waits for it rather than running alongside it. A call that fails
+ * is forgotten so that it can be retried.
+ */
+export class IdempotencyCache<T> {
+  private ttlMs: number;
+  private clock: () => number;
+  private calls = new Map<string, Remembered<T>>();
+
+  constructor(
+    ttlMs: number = DEFAULT_IDEMPOTENCY_TTL_MS,
+    clock: () => number = Date.now,
+  ) {
+    this.ttlMs = ttlMs;
+    this.clock = clock;
+  }
+
+  /**
+   * Runs `call` unless a call with the same key ran within the TTL, in
+   * which case that call's result is returned and `call` is not run.
+   */
+  run(key: string, call: () => Promise<T>): Promise<T> {
+    const now = this.clock();
+    this.forgetExpired(now);
+    const remembered = this.calls.get(key);
+    if (remembered) return remembered.result;
+
+    const result = call();
+    this.calls.set(key, { result, expiresAt: now + this.ttlMs });
+    result.catch(() => {
+      if (this.calls.get(key)?.result === result) this.calls.delete(key);
+    });
+    return result;
+  }
+
+  /** Number of keys currently remembered. */
+  get size(): number {
+    return this.calls.size;
+  }
+
+  /**
+   * Drops expired keys. Every key lives for the same TTL, so keys expire
+   * in insertion order and the scan stops at the first live one.
+   */
+  private forgetExpired(now: number): void {
+    for (const [key, { expiresAt }] of this.calls) {
+      if (expiresAt > now) return;
+      this.calls.delete(key);
+    }
+  }
+}
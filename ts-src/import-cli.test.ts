@@ -0,0 +1,185 @@
+import { describe, it, expect, beforeEach, afterEach } from "vitest";
+import * as fs from "fs/promises";
+import {
+  importCorpus,
+  parseCorpus,
+  parseCsv,
+  parseJsonl,
+} from "./import-cli.js";
+import { VecFSStorage } from "./storage.js";
+import { createToolHandlers } from "./tool-handlers.js";
+import { SparseVector } from "./types.js";
+
+const JSONL = `{"id": "deploy", "text": "Deploy with the blue-green script."}
+
+{"id": "rollback", "text": "Roll back by redeploying the last tag.", "metadata": {"tags": ["ops"]}}
+`;
+
+const CSV = `id,text,source
+deploy,"Deploy with the blue-green script, then check health.",wiki
+"quoted ""id""","Line one
+line two",
+`;
+
+/** Stands in for vecfs-embed: one dimension per text length. */
+async function byLength(texts: string[]): Promise<SparseVector[]> {
+  return texts.map((text) => ({ [text.length]: 1 }));
+}
+
+describe("parseJsonl", () => {
+  it("should read one row per non-blank line", () => {
+    expect(parseJsonl(JSONL)).toEqual([
+      {
+        id: "deploy",
+        text: "Deploy with the blue-green script.",
+        metadata: undefined,
+      },
+      {
+        id: "rollback",
+        text: "Roll back by redeploying the last tag.",
+        metadata: { tags: ["ops"] },
+      },
+    ]);
+  });
+
+  it("should name the line of an invalid row", () => {
+    expect(() => parseJsonl('{"id": "a", "text": "x"}\n{"id": "b"}')).toThrow(
+      "Line 2: expected a non-empty string 'text'.",
+    );
+    expect(() => parseJsonl("not json")).toThrow("Line 1:");
+  });
+});
+
+describe("parseCsv", () => {
+  it("should read quoted fields and keep other columns as metadata", () => {
+    expect(parseCsv(CSV)).toEqual([
+      {
+        id: "deploy",
+        text: "Deploy with the blue-green script, then check health.",
+        metadata: { source: "wiki" },
+      },
+      { id: 'quoted "id"', text: "Line one\nline two", metadata: {} },
+    ]);
+  });
+
+  it("should need id and text columns", () => {
+    expect(() => parseCsv("name,body\na,b\n")).toThrow("'id' and 'text'");
+  });
+
+  it("should read CRLF line endings", () => {
+    expect(parseCsv("id,text\r\na,hello\r\n")).toEqual([
+      { id: "a", text: "hello", metadata: {} },
+    ]);
+  });
+});
+
+describe("parseCorpus", () => {
+  it("should choose the format from the file name", () => {
+    expect(parseCorpus(CSV, "corpus.CSV")).toHaveLength(2);
+    expect(parseCorpus(JSONL, "corpus.jsonl")).toHaveLength(2);
+  });
+});
+
+describe("importCorpus", () => {
+  const testFilePath = "./test-import-cli.jsonl";
+
+  beforeEach(async () => {
+    try {
+      await fs.unlink(testFilePath);
+    } catch {}
+  });
+
+  afterEach(async () => {
+    try {
+      await fs.unlink(testFilePath);
+    } catch {}
+  });
+
+  it("should store every row of a JSONL and a CSV corpus", async () => {
+    const storage = new VecFSStorage(testFilePath);
+    await storage.ensureFile();
+    const handlers = createToolHandlers(storage);
+    const memorizeBatch = (entries: unknown) =>
+      handlers.memorize_batch({ entries });
+
+    await importCorpus(parseJsonl(JSONL), byLength, memorizeBatch);
+    await importCorpus(parseCsv(CSV), byLength, memorizeBatch);
+
+    const reloaded = new VecFSStorage(testFilePath);
+    const { entries } = await reloaded.list();
+    expect(entries.map((e) => e.id).sort()).toEqual([
+      "deploy",
+      'quoted "id"',
+      "rollback",
+    ]);
+    const rollback = await reloaded.get("rollback");
+    expect(rollback?.metadata).toEqual({
+      tags: ["ops"],
+      text: "Roll back by redeploying the last tag.",
+    });
+    expect((await reloaded.get("deploy"))?.metadata?.source).toBe("wiki");
+  });
+
+  it("should embed in batches of the given size", async () => {
+    const batches: number[] = [];
+    const rows = ["a", "b", "c", "d", "e"].map((id) => ({ id, text: id }));
+
+    await importCorpus(
+      rows,
+      async (texts) => {
+        batches.push(texts.length);
+        return byLength(texts);
+      },
+      async () => undefined,
+      2,
+    );
+    expect(batches).toEqual([2, 2, 1]);
+  });
+
+  it("should embed batches concurrently and store each row once", async () => {
+    const rows = Array.from({ length: 20 }, (_, i) => ({
+      id: `row-${i}`,
+      text: "x".repeat(i + 1),
+    }));
+    let inFlight = 0;
+    let mostInFlight = 0;
+    const stored: string[] = [];
+
+    await importCorpus(
+      rows,
+      async (texts) => {
+        inFlight++;
+        mostInFlight = Math.max(mostInFlight, inFlight);
+        // Later batches finish first, to check storing stays in order.
+        await new Promise((r) => setTimeout(r, 30 - texts[0].length));
+        inFlight--;
+        return byLength(texts);
+      },
+      async (entries) => {
+        stored.push(...entries.map((e) => e.id));
+      },
+      2,
+      3,
+    );
+    expect(mostInFlight).toBe(3);
+    expect(stored).toEqual(rows.map((row) => row.id));
+  });
+
+  it("should stop at the first batch that fails", async () => {
+    const stored: string[] = [];
+    const rows = ["a", "b", "c", "d"].map((id) => ({ id, text: id }));
+
+    await expect(
+      importCorpus(
+        rows,
+        async (texts) => (texts[0] === "c" ? [] : byLength(texts)),
+        async (entries) => {
+          stored.push(...entries.map((e) => e.id));
+        },
+        1,
+        3,
+      ),
+    ).rejects.toThrow("0 vectors for 1 texts");
+    expect(stored).toEqual(["a", "b"]);
+  });
+});
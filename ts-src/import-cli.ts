@@ -0,0 +1,184 @@
+/**
+ * Helpers for the `vecfs import` subcommand, which fills the store from a
+ * JSONL or CSV file of texts.
+ */
+
+import { Embedder, SparseVector } from "./types.js";
+
+/** Rows embedded and stored together; vecfs-embed runs once per batch. */
+export const IMPORT_BATCH_SIZE = 100;
+
+/** One text to import, with the ID to store it under. */
+export interface CorpusRow {
+  id: string;
+  text: string;
+  metadata?: Record<string, unknown>;
+}
+
+/** A row with its vector, in the form the `memorize_batch` tool takes. */
+export interface ImportedEntry extends CorpusRow {
+  vector: SparseVector;
+}
+
+/**
+ * Reads the rows of a corpus file: CSV when the name ends in `.csv`,
+ * otherwise JSONL.
+ *
+ * @throws Error naming the line or row that is not a valid row.
+ */
+export function parseCorpus(content: string, fileName: string): CorpusRow[] {
+  return fileName.toLowerCase().endsWith(".csv")
+    ? parseCsv(content)
+    : parseJsonl(content);
+}
+
+/**
+ * Reads JSONL with one `{"id", "text", "metadata"}` object per line.
+ * `metadata` is optional and blank lines are skipped.
+ */
+export function parseJsonl(content: string): CorpusRow[] {
+  return content.split("\n").flatMap((line, i) => {
+    if (line.trim() === "") return [];
+    let row: unknown;
+    try {
+      row = JSON.parse(line);
+    } catch (error) {
+      throw new Error(`Line ${i + 1}: ${(error as Error).message}`);
+    }
+    return [checkRow(row, `Line ${i + 1}`)];
+  });
+}
+
+/**
+ * Reads CSV whose header row names an `id` and a `text` column. Every
+ * other column becomes a string metadata field, left out where the cell
+ * is empty. Fields may be quoted to hold commas, quotes (doubled) and
+ * line breaks.
+ */
+export function parseCsv(content: string): CorpusRow[] {
+  const [header, ...records] = parseCsvRecords(content);
+  if (!header) return [];
+  const idColumn = header.indexOf("id");
+  const textColumn = header.indexOf("text");
+  if (idColumn < 0 || textColumn < 0) {
+    throw new Error("CSV header must have 'id' and 'text' columns.");
+  }
+  return records.map((fields, i) => {
+    const metadata: Record<string, string> = {};
+    header.forEach((name, column) => {
+      if (column === idColumn || column === textColumn) return;
+      if (fields[column]) metadata[name] = fields[column];
+    });
+    const row = { id: fields[idColumn], text: fields[textColumn], metadata };
+    return checkRow(row, `Row ${i + 2}`);
+  });
+}
+
+/** Splits CSV into records of fields, skipping blank lines. */
+function parseCsvRecords(content: string): string[][] {
+  const records: string[][] = [];
+  let record: string[] = [];
+  let field = "";
+  let quoted = false;
+  for (let i = 0; i < content.length; i++) {
+    const ch = content[i];
+    if (quoted) {
+      if (ch !== '"') {
+        field += ch;
+      } else if (content[i + 1] === '"') {
+        field += '"';
+        i++;
+      } else {
+        quoted = false;
+      }
+    } else if (ch === '"') {
+      quoted = true;
+    } else if (ch === ",") {
+      record.push(field);
+      field = "";
+    } else if (ch === "\n") {
+      records.push([...record, field]);
+      record = [];
+      field = "";
+    } else if (ch !== "\r") {
+      field += ch;
+    }
+  }
+  if (quoted) throw new Error("CSV ends inside a quoted field.");
+  records.push([...record, field]);
+  return records.filter((r) => r.some((f) => f !== ""));
+}
+
+/** Checks that a parsed row has an ID and some text. */
+function checkRow(row: unknown, where: string): CorpusRow {
+  const { id, text, metadata } = (row ?? {}) as Record<string, unknown>;
+  if (typeof id !== "string" || id === "") {
+    throw new Error(`${where}: expected a non-empty string 'id'.`);
+  }
+  if (typeof text !== "string" || text.trim() === "") {
+    throw new Error(`${where}: expected a non-empty string 'text'.`);
+  }
+  const isObject =
+    typeof metadata === "object" &&
+    metadata !== null &&
+    !Array.isArray(metadata);
+  if (metadata !== undefined && !isObject) {
+    throw new Error(`${where}: 'metadata' must be an object.`);
+  }
+  return { id, text, metadata: metadata as Record<string, unknown> };
+}
+
+/**
+ * Embeds and stores rows a batch at a time, so a large corpus never has
+ * to be embedded in one call. Up to `concurrency` batches are embedded at
+ * once, but batches are stored one after another in file order, so a
+ * later row still replaces an earlier one with the same ID. Batches
+ * already stored stay stored if a later one fails; importing again is
+ * safe, as rows replace entries with the same ID.
+ *
+ * @param embed - Embeds a batch of texts, returning vectors in order.
+ * @param store - Stores a batch of embedded rows.
+ * @param concurrency - Most batches being embedded at the same time.
+ * @throws Error if `embed` returns a different number of vectors than
+ *   texts.
+ */
+export async function importCorpus(
+  rows: CorpusRow[],
+  embed: Embedder,
+  store: (entries: ImportedEntry[]) => Promise<unknown>,
+  batchSize: number = IMPORT_BATCH_SIZE,
+  concurrency: number = 1,
+): Promise<void> {
+  const batches: CorpusRow[][] = [];
+  for (let start = 0; start < rows.length; start += batchSize) {
+    batches.push(rows.slice(start, start + batchSize));
+  }
+  const embedded: Promise<ImportedEntry[]>[] = [];
+  const startEmbedding = (index: number) => {
+    if (index >= batches.length) return;
+    embedded[index] = embedBatch(batches[index], embed);
+    // Failures are reported when the batch's turn to be stored comes.
+    embedded[index].catch(() => undefined);
+  };
+  for (let i = 0; i < concurrency; i++) startEmbedding(i);
+  for (let i = 0; i < batches.length; i++) {
+    const entries = await embedded[i];
+    startEmbedding(i + concurrency);
+    await store(entries);
+  }
+}
+
+/** Embeds one batch of rows, pairing each row with its vector. */
+async function embedBatch(
+  batch: CorpusRow[],
+  embed: Embedder,
+): Promise<ImportedEntry[]> {
+  const vectors = await embed(batch.map((row) => row.text));
+  if (vectors.length !== batch.length) {
+    throw new Error(
+      `Embedder returned ${vectors.length} vectors for ` +
+        `${batch.length} texts.`,
+    );
+  }
+  return batch.map((row, i) => ({ ...row, vector: vectors[i] }));
+}
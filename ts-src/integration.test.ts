@@ -7,13 +7,36 @@ import * as path from "path";
 // Mock embedding helper
 // ---------------------------------------------------------------------------
 
-function mockEmbed(text: string): Record<string, number> {
+interface MockEmbedOptions {
+  /** L2-normalise the counts; false keeps raw word counts. */
+  normalize?: boolean;
+  /** Number of dimensions words are hashed into. */
+  dims?: number;
+  /** Words shorter than this are dropped. */
+  minLength?: number;
+  /** Words dropped regardless of length. */
+  stopwords?: Iterable<string>;
+}
+
+/**
+ * Hashes each word of the text into one of `dims` (default 100)
+ * dimensions and counts occurrences. Words shorter than `minLength`
+ * (default 3) and `stopwords` are skipped. The counts are L2-normalised
+ * unless `normalize` is false, which keeps raw word counts for tests that
+ * assert on magnitude.
+ */
+function mockEmbed(
+  text: string,
+  options: MockEmbedOptions = {},
+): Record<string, number> {
+  const { normalize = true, dims = 100, minLength = 3 } = options;
+  const stopwords = new Set(options.stopwords);
   const vector: Record<string, number> = {};
   const words = text
     .toLowerCase()
     .replace(/[^\w\s]/g, "")
     .split(/\s+/)
-    .filter((w) => w.length > 2);
+    .filter((w) => w.length >= minLength && !stopwords.has(w));
 
   if (words.length === 0) return {};
 
@@ -23,10 +46,12 @@ function mockEmbed(text: string): Record<string, number> {
       hash = (hash << 5) - hash + word.charCodeAt(i);
       hash |= 0;
     }
-    const dim = Math.abs(hash) % 100;
+    const dim = Math.abs(hash) % dims;
     vector[dim.toString()] = (vector[dim.toString()] || 0) + 1;
   }
 
+  if (!normalize) return vector;
+
   let sumSq = 0;
   for (const k in vector) {
     sumSq += vector[k] * vector[k];
@@ -42,6 +67,47 @@ function mockEmbed(text: string): Record<string, number> {
   return vector;
 }
 
+describe("mockEmbed", () => {
+  it("should return integer word counts when not normalizing", () => {
+    const raw = mockEmbed("storage storage storage vector", {
+      normalize: false,
+    });
+    const values = Object.values(raw).sort();
+
+    expect(values).toEqual([1, 3]);
+    expect(values.every(Number.isInteger)).toBe(true);
+  });
+
+  it("should L2-normalize by default", () => {
+    const vector = mockEmbed("storage storage storage vector");
+    const sumSq = Object.values(vector).reduce((sum, v) => sum + v * v, 0);
+
+    expect(sumSq).toBeCloseTo(1);
+  });
+
+  it("should only use dimensions below dims", () => {
+    const text = "sparse vector storage keeps memories across agent sessions";
+    const keys = Object.keys(mockEmbed(text, { dims: 16 })).map(Number);
+
+    expect(keys.length).toBeGreaterThan(0);
+    expect(keys.every((k) => k >= 0 && k < 16)).toBe(true);
+  });
+
+  it("should skip stopwords regardless of length", () => {
+    const text = "the quick brown fox";
+    const vector = mockEmbed(text, { normalize: false, stopwords: ["the"] });
+
+    expect(vector).toEqual(mockEmbed("quick brown fox", { normalize: false }));
+    expect(vector).not.toEqual(mockEmbed(text, { normalize: false }));
+  });
+
+  it("should skip words shorter than minLength", () => {
+    const vector = mockEmbed("the quick brown fox", { minLength: 4 });
+
+    expect(vector).toEqual(mockEmbed("quick brown"));
+  });
+});
+
 // ---------------------------------------------------------------------------
 // JSON-RPC types
 // ---------------------------------------------------------------------------
@@ -180,6 +246,57 @@ describe("MCP Integration Test", () => {
     expect(toolNames).toContain("delete");
   });
 
+  it("should answer an unknown method with code -32601", async () => {
+    const error = await sendRequest("no/such/method", {}).catch((e) => e);
+    expect(error.code).toBe(-32601);
+  });
+
+  it("should answer malformed tool calls with code -32602", async () => {
+    const badArgs = await sendRequest("tools/call", {
+      name: "get",
+      arguments: {},
+    }).catch((e) => e);
+    expect(badArgs.code).toBe(-32602);
+    expect(badArgs.message).toContain("Invalid arguments for 'get'");
+
+    const unknownTool = await sendRequest("tools/call", {
+      name: "no_such_tool",
+      arguments: {},
+    }).catch((e) => e);
+    expect(unknownTool.code).toBe(-32602);
+    expect(unknownTool.message).toContain("Unknown tool: no_such_tool");
+  });
+
+  it("should answer a JSON-RPC batch with an array of responses", async () => {
+    const listId = ++requestCounter;
+    const callId = ++requestCounter;
+    const batch: JSONRPCRequest[] = [
+      { jsonrpc: "2.0", id: listId, method: "tools/list", params: {} },
+      {
+        jsonrpc: "2.0",
+        id: callId,
+        method: "tools/call",
+        params: { name: "count", arguments: {} },
+      },
+    ];
+
+    const responses = await new Promise<JSONRPCResponse[]>((resolve) => {
+      const onData = (data: Buffer) => {
+        for (const line of data.toString().split("\n")) {
+          if (!line.trim().startsWith("[")) continue;
+          serverProcess.stdout?.off("data", onData);
+          resolve(JSON.parse(line));
+        }
+      };
+      serverProcess.stdout?.on("data", onData);
+      serverProcess.stdin?.write(JSON.stringify(batch) + "\n");
+    });
+
+    expect(responses.map((r) => r.id)).toEqual([listId, callId]);
+    expect(responses[0].result.tools.length).toBeGreaterThan(0);
+    expect(responses[1].result.content[0].text).toContain("count");
+  });
+
   // -----------------------------------------------------------------------
   // Basic memorize / search
   // -----------------------------------------------------------------------
@@ -210,6 +327,27 @@ describe("MCP Integration Test", () => {
     expect(content[0].similarity).toBeCloseTo(1);
   });
 
+  it("should expose stored entries as resources", async () => {
+    await sendRequest("tools/call", {
+      name: "memorize",
+      arguments: { id: "resource-1", text: "readable", vector: { "0": 1 } },
+    });
+
+    const listed = await sendRequest("resources/list", {});
+    const uris = listed.resources.map((r: any) => r.uri);
+    expect(uris).toContain("vecfs://entry/resource-1");
+
+    const read = await sendRequest("resources/read", {
+      uri: "vecfs://entry/resource-1",
+    });
+    expect(read.contents[0].text).toBe("readable");
+
+    const missing = await sendRequest("resources/read", {
+      uri: "vecfs://entry/no-such-entry",
+    }).catch((e) => e);
+    expect(missing.code).toBe(-32002);
+  });
+
   // -----------------------------------------------------------------------
   // Context relevance among noise
   // -----------------------------------------------------------------------
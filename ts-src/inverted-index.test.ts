@@ -0,0 +1,38 @@
+import { describe, it, expect } from "vitest";
+import { buildInvertedIndex, overlapCandidates } from "./inverted-index.js";
+import { VecFSEntry } from "./types.js";
+
+function entry(id: string, vector: Record<number, number>): VecFSEntry {
+  return { id, vector, metadata: {}, score: 0, timestamp: 0 };
+}
+
+describe("inverted index", () => {
+  const entries = [
+    entry("a", { 1: 1 }),
+    entry("b", { 1: 1, 2: 1 }),
+    entry("c", { 3: 1 }),
+  ];
+
+  it("should list entries under each of their dimensions", () => {
+    const index = buildInvertedIndex(entries);
+    expect(index.get(1)!.map((e) => e.id)).toEqual(["a", "b"]);
+    expect(index.get(3)!.map((e) => e.id)).toEqual(["c"]);
+    expect(index.has(4)).toBe(false);
+  });
+
+  it("should order candidates by overlap and bound them by the cap", () => {
+    const index = buildInvertedIndex(entries);
+
+    const all = overlapCandidates(index, { 1: 1, 2: 1 }, 10);
+    expect(all.map((e) => e.id)).toEqual(["b", "a"]);
+    const capped = overlapCandidates(index, { 1: 1, 2: 1 }, 1);
+    expect(capped.map((e) => e.id)).toEqual(["b"]);
+  });
+
+  it("should skip entries rejected by the predicate", () => {
+    const index = buildInvertedIndex(entries);
+
+    const result = overlapCandidates(index, { 1: 1 }, 10, (e) => e.id !== "a");
+    expect(result.map((e) => e.id)).toEqual(["b"]);
+  });
+});
@@ -0,0 +1,55 @@
+import { SparseVector, VecFSEntry } from "./types.js";
+
+/**
+ * Posting lists from dimension index to the entries with a value in that
+ * dimension. Because vectors are sparse, a query only touches the entries
+ * that share at least one of its dimensions.
+ */
+export type InvertedIndex = Map<number, VecFSEntry[]>;
+
+/**
+ * Builds posting lists for the given entries.
+ *
+ * @param entries - The entries to index, in store order.
+ */
+export function buildInvertedIndex(entries: VecFSEntry[]): InvertedIndex {
+  const index: InvertedIndex = new Map();
+  for (const entry of entries) {
+    for (const key of Object.keys(entry.vector)) {
+      const dimension = Number(key);
+      const postings = index.get(dimension);
+      if (postings) postings.push(entry);
+      else index.set(dimension, [entry]);
+    }
+  }
+  return index;
+}
+
+/**
+ * Selects up to `cap` candidate entries for a query, preferring those that
+ * share the most dimensions with it. Ties keep the order in which entries
+ * were first touched, which follows the query's dimensions and store order.
+ * Entries sharing no dimension with the query are never candidates.
+ *
+ * @param index - Posting lists for the store.
+ * @param query - The query vector.
+ * @param cap - Maximum number of candidates to return.
+ * @param accept - Optional predicate an entry must pass to be counted.
+ */
+export function overlapCandidates(
+  index: InvertedIndex,
+  query: SparseVector,
+  cap: number,
+  accept?: (entry: VecFSEntry) => boolean,
+): VecFSEntry[] {
+  const overlap = new Map<VecFSEntry, number>();
+  for (const key of Object.keys(query)) {
+    for (const entry of index.get(Number(key)) ?? []) {
+      if (accept && !accept(entry)) continue;
+      overlap.set(entry, (overlap.get(entry) ?? 0) + 1);
+    }
+  }
+  return [...overlap.keys()]
+    .sort((a, b) => overlap.get(b)! - overlap.get(a)!)
+    .slice(0, cap);
+}
@@ -0,0 +1,364 @@
+import { describe, it, expect, beforeEach, afterEach } from "vitest";
+import { VecFSStorage } from "./storage.js";
+import * as fs from "fs/promises";
+
+describe("entry lifecycle", () => {
+  const testFilePath = "./test-lifecycle.jsonl";
+
+  beforeEach(async () => {
+    try {
+      await fs.unlink(testFilePath);
+    } catch {}
+  });
+
+  afterEach(async () => {
+    try {
+      await fs.unlink(testFilePath);
+    } catch {}
+  });
+
+  it("should delete an existing entry", async () => {
+    const storage = new VecFSStorage(testFilePath);
+    await storage.ensureFile();
+
+    await storage.store({
+      id: "to-delete",
+      vector: { 0: 1 },
+      metadata: {},
+      score: 0,
+    });
+
+    const deleted = await storage.delete("to-delete");
+    expect(deleted).toBe(true);
+
+    const results = await storage.search({ 0: 1 });
+    expect(results.find((r) => r.id === "to-delete")).toBeUndefined();
+  });
+
+  it("should return false when deleting nonexistent entry", async () => {
+    const storage = new VecFSStorage(testFilePath);
+    await storage.ensureFile();
+
+    const deleted = await storage.delete("nonexistent");
+    expect(deleted).toBe(false);
+  });
+
+  it("should persist data correctly after delete", async () => {
+    const storage = new VecFSStorage(testFilePath);
+    await storage.ensureFile();
+
+    await storage.store({
+      id: "keep",
+      vector: { 0: 1 },
+      metadata: {},
+      score: 0,
+    });
+    await storage.store({
+      id: "remove",
+      vector: { 1: 1 },
+      metadata: {},
+      score: 0,
+    });
+    await storage.delete("remove");
+
+    // Read from a fresh instance to verify file persistence
+    const storage2 = new VecFSStorage(testFilePath);
+    const results = await storage2.search({ 0: 1 }, 10);
+
+    expect(results.find((r) => r.id === "keep")).toBeDefined();
+    expect(results.find((r) => r.id === "remove")).toBeUndefined();
+  });
+
+  describe("tombstone deletes", () => {
+    it("should append a tombstone and hide the entry across reloads", async () => {
+      const storage = new VecFSStorage(testFilePath, {
+        tombstoneDeletes: true,
+      });
+      await storage.ensureFile();
+
+      await storage.store({
+        id: "keep",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await storage.store({
+        id: "gone",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      const before = await fs.readFile(testFilePath, "utf-8");
+
+      expect(await storage.delete("gone")).toBe(true);
+
+      const after = await fs.readFile(testFilePath, "utf-8");
+      expect(after.startsWith(before)).toBe(true);
+      expect(after.slice(before.length).trim()).toBe(
+        JSON.stringify({ id: "gone", deleted: true }),
+      );
+
+      const results = await storage.search({ 0: 1 }, 10);
+      expect(results.map((r) => r.id)).toEqual(["keep"]);
+
+      const reloaded = new VecFSStorage(testFilePath);
+      const reloadedResults = await reloaded.search({ 0: 1 }, 10);
+      expect(reloadedResults.map((r) => r.id)).toEqual(["keep"]);
+    });
+
+    it("should still rewrite updates when only deletes use tombstones", async () => {
+      const storage = new VecFSStorage(testFilePath, {
+        tombstoneDeletes: true,
+      });
+      await storage.ensureFile();
+
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await storage.updateScore("a", 1);
+
+      const lines = (await fs.readFile(testFilePath, "utf-8"))
+        .trim()
+        .split("\n");
+      expect(lines).toHaveLength(1);
+    });
+  });
+
+  describe("rename", () => {
+    it("should move an entry to a new ID preserving its data", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.ensureFile();
+
+      await storage.store({
+        id: "draft",
+        vector: { 0: 1, 3: 0.5 },
+        metadata: { text: "note" },
+        score: 2,
+      });
+      const [before] = await storage.search({ 0: 1 }, 1);
+
+      expect(await storage.rename("draft", "canonical")).toBe(true);
+
+      const reloaded = new VecFSStorage(testFilePath);
+      const results = await reloaded.search({ 0: 1 }, 10);
+      expect(results).toHaveLength(1);
+      expect(results[0].id).toBe("canonical");
+      expect(results[0].vector).toEqual(before.vector);
+      expect(results[0].metadata).toEqual({ text: "note" });
+      expect(results[0].score).toBe(2);
+      expect(results[0].timestamp).toBe(before.timestamp);
+    });
+
+    it("should return false when the source ID is missing", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.ensureFile();
+
+      expect(await storage.rename("missing", "other")).toBe(false);
+    });
+
+    it("should refuse to overwrite an existing destination", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.ensureFile();
+
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await storage.store({
+        id: "b",
+        vector: { 1: 1 },
+        metadata: {},
+        score: 0,
+      });
+
+      await expect(storage.rename("a", "b")).rejects.toThrow("already exists");
+      const results = await storage.search({ 0: 1 }, 10);
+      expect(results.map((r) => r.id).sort()).toEqual(["a", "b"]);
+    });
+
+    it("should refuse to rename onto a soft-deleted entry", async () => {
+      const storage = new VecFSStorage(testFilePath, { softDeletes: true });
+      await storage.ensureFile();
+      await storage.store({ id: "a", vector: { 0: 1 }, score: 0 });
+      await storage.store({ id: "b", vector: { 1: 1 }, score: 0 });
+      await storage.delete("b");
+
+      await expect(storage.rename("a", "b")).rejects.toThrow("soft-deleted");
+      expect((await storage.get("a"))?.vector).toEqual({ 0: 1 });
+      expect(await storage.purge(0)).toBe(1);
+    });
+
+    it("should survive reload in append-only mode", async () => {
+      const storage = new VecFSStorage(testFilePath, { appendOnly: true });
+      await storage.ensureFile();
+
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await storage.rename("a", "z");
+
+      const reloaded = new VecFSStorage(testFilePath);
+      const results = await reloaded.search({ 0: 1 }, 10);
+      expect(results.map((r) => r.id)).toEqual(["z"]);
+    });
+  });
+
+  describe("soft deletes", () => {
+    async function softStore(ids: string[]): Promise<VecFSStorage> {
+      const storage = new VecFSStorage(testFilePath, { softDeletes: true });
+      for (const id of ids) {
+        await storage.store({ id, vector: { 0: 1 }, metadata: {}, score: 0 });
+      }
+      return storage;
+    }
+
+    it("should hide a soft-deleted entry but keep it in the file", async () => {
+      const storage = await softStore(["keep", "gone"]);
+
+      expect(await storage.delete("gone")).toBe(true);
+
+      const results = await storage.search({ 0: 1 }, 10);
+      expect(results.map((r) => r.id)).toEqual(["keep"]);
+      expect(await storage.get("gone")).toBeUndefined();
+      expect(await storage.count()).toBe(1);
+
+      const reloaded = new VecFSStorage(testFilePath, { softDeletes: true });
+      const { entries, total } = await reloaded.list();
+      expect(entries.map((e) => e.id)).toEqual(["keep"]);
+      expect(total).toBe(1);
+
+      const records = (await fs.readFile(testFilePath, "utf-8"))
+        .trim()
+        .split("\n")
+        .map((line) => JSON.parse(line));
+      const gone = records.find((r) => r.id === "gone");
+      expect(typeof gone.deletedAt).toBe("number");
+    });
+
+    it("should keep a soft delete when reviving it fails", async () => {
+      const options = {
+        softDeletes: true,
+        maxEntries: 2,
+        eviction: "reject" as const,
+      };
+      const storage = new VecFSStorage(testFilePath, options);
+      const entry = (id: string) => ({
+        id,
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await storage.store(entry("gone"));
+      await storage.delete("gone");
+      await storage.store(entry("a"));
+      await storage.store(entry("b"));
+
+      await expect(storage.store(entry("gone"))).rejects.toThrow(
+        "Storage is full",
+      );
+      await expect(
+        storage.storeBatch([entry("gone"), entry("c")]),
+      ).rejects.toThrow("Storage is full");
+      await storage.compact();
+
+      expect(await storage.get("gone")).toBeUndefined();
+      const records = (await fs.readFile(testFilePath, "utf-8"))
+        .trim()
+        .split("\n")
+        .map((line) => JSON.parse(line));
+      const gone = records.find((r) => r.id === "gone");
+      expect(typeof gone?.deletedAt).toBe("number");
+      expect(await storage.purge(0)).toBe(1);
+    });
+
+    it("should report a second soft delete as not found", async () => {
+      const storage = await softStore(["a"]);
+      expect(await storage.delete("a")).toBe(true);
+      expect(await storage.delete("a")).toBe(false);
+    });
+
+    it("should remove a soft-deleted entry with a hard delete", async () => {
+      const storage = await softStore(["a"]);
+      await storage.delete("a");
+
+      expect(await storage.delete("a", { hard: true })).toBe(true);
+      expect(await fs.readFile(testFilePath, "utf-8")).toBe("");
+    });
+
+    it("should purge only entries deleted before the cutoff", async () => {
+      const storage = await softStore(["old", "recent"]);
+      await storage.delete("old");
+      const lines = (await fs.readFile(testFilePath, "utf-8"))
+        .trim()
+        .split("\n")
+        .map((line) => JSON.parse(line));
+      const backdated = lines.map((r) =>
+        r.id === "old" ? { ...r, deletedAt: Date.now() - 60_000 } : r,
+      );
+      await fs.writeFile(
+        testFilePath,
+        backdated.map((r) => JSON.stringify(r)).join("\n") + "\n",
+      );
+
+      const reloaded = new VecFSStorage(testFilePath, { softDeletes: true });
+      await reloaded.delete("recent");
+      expect(await reloaded.purge(30_000)).toBe(1);
+
+      const remaining = await fs.readFile(testFilePath, "utf-8");
+      expect(remaining).not.toContain('"old"');
+      expect(remaining).toContain('"recent"');
+      expect(await reloaded.purge(0)).toBe(1);
+      expect(await fs.readFile(testFilePath, "utf-8")).toBe("");
+    });
+
+    it("should purge with tombstones in append-only mode", async () => {
+      const storage = new VecFSStorage(testFilePath, {
+        softDeletes: true,
+        appendOnly: true,
+      });
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await storage.delete("a");
+
+      expect(await storage.purge(0)).toBe(1);
+
+      const lines = (await fs.readFile(testFilePath, "utf-8"))
+        .trim()
+        .split("\n");
+      const tombstone = JSON.stringify({ id: "a", deleted: true });
+      expect(lines[lines.length - 1]).toBe(tombstone);
+      const reloaded = new VecFSStorage(testFilePath, { softDeletes: true });
+      expect(await reloaded.purge(0)).toBe(0);
+    });
+
+    it("should revive a soft-deleted id when it is stored again", async () => {
+      const storage = await softStore(["a"]);
+      await storage.delete("a");
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+
+      expect((await storage.get("a"))?.deletedAt).toBeUndefined();
+      expect(await storage.purge(0)).toBe(0);
+      const lines = (await fs.readFile(testFilePath, "utf-8"))
+        .trim()
+        .split("\n");
+      expect(lines).toHaveLength(1);
+    });
+  });
+});
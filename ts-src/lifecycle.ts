@@ -0,0 +1,119 @@
+import { VecFSEntry } from "./types.js";
+import { isExpired } from "./retention.js";
+
+/**
+ * The cached state of a {@link VecFSStorage} that deleting, expiring and
+ * renaming entries change. The functions here change it in place and
+ * leave persisting the change to the store.
+ */
+export interface EntryState {
+  /** The entries the store holds, including any that have expired. */
+  entries: VecFSEntry[];
+  /** Soft-deleted entries by ID, kept until purged. */
+  softDeleted: Map<string, VecFSEntry>;
+  /** When each entry was last returned by a search. */
+  lastAccess: Map<string, number>;
+}
+
+/**
+ * What a delete changed: an entry marked with `deletedAt`, whose record
+ * must be written, or an ID whose record must be removed.
+ */
+export type Deletion = { marked: VecFSEntry } | { removed: string };
+
+/**
+ * Deletes an entry. With `soft`, the entry is moved to `softDeleted` with
+ * `deletedAt` set to `now`; otherwise it is dropped, and so is a record
+ * already soft-deleted under the ID. An expired entry counts as not found.
+ *
+ * @returns What changed, or undefined if there was nothing to delete.
+ */
+export function deleteEntry(
+  state: EntryState,
+  id: string,
+  soft: boolean,
+  now: number,
+): Deletion | undefined {
+  const { entries, softDeleted, lastAccess } = state;
+  const index = entries.findIndex((e) => e.id === id && !isExpired(e, now));
+  if (index < 0) {
+    return soft || !softDeleted.delete(id) ? undefined : { removed: id };
+  }
+  const [entry] = entries.splice(index, 1);
+  lastAccess.delete(id);
+  if (!soft) return { removed: id };
+  const marked: VecFSEntry = { ...entry, deletedAt: now };
+  softDeleted.set(id, marked);
+  return { marked };
+}
+
+/**
+ * Drops every entry that has expired by `now`.
+ *
+ * @returns The IDs of the dropped entries.
+ */
+export function sweepExpired(state: EntryState, now: number): string[] {
+  const { entries, lastAccess } = state;
+  const expired = entries.filter((e) => isExpired(e, now)).map((e) => e.id);
+  if (expired.length === 0) return expired;
+  const live = entries.filter((e) => !isExpired(e, now));
+  entries.splice(0, entries.length, ...live);
+  for (const id of expired) lastAccess.delete(id);
+  return expired;
+}
+
+/**
+ * Forgets the soft-deleted entries whose `deletedAt` is at or before
+ * `cutoff`.
+ *
+ * @returns The IDs of the forgotten entries.
+ */
+export function purgeSoftDeleted(
+  softDeleted: Map<string, VecFSEntry>,
+  cutoff: number,
+): string[] {
+  const purged = [...softDeleted.values()]
+    .filter((e) => (e.deletedAt ?? 0) <= cutoff)
+    .map((e) => e.id);
+  for (const id of purged) softDeleted.delete(id);
+  return purged;
+}
+
+/**
+ * Moves an entry to a new ID, keeping everything else about it. An
+ * expired entry under `newId` is dropped to make way, as `store` would
+ * replace it.
+ *
+ * @returns The renamed entry, or undefined if `oldId` was not found or
+ *          has expired.
+ * @throws Error if an entry with `newId` already exists, including a
+ *   soft-deleted one, which would otherwise be lost.
+ */
+export function renameEntry(
+  state: EntryState,
+  oldId: string,
+  newId: string,
+  now: number,
+): VecFSEntry | undefined {
+  const { entries, softDeleted, lastAccess } = state;
+  const entry = entries.find((e) => e.id === oldId && !isExpired(e, now));
+  if (!entry || oldId === newId) return entry;
+  const taken = entries.findIndex((e) => e.id === newId);
+  if (taken >= 0 && isExpired(entries[taken], now)) {
+    entries.splice(taken, 1);
+    lastAccess.delete(newId);
+  } else if (taken >= 0) {
+    throw new Error(`Cannot rename ${oldId}: entry ${newId} already exists.`);
+  }
+  if (softDeleted.has(newId)) {
+    throw new Error(
+      `Cannot rename ${oldId}: entry ${newId} already exists ` +
+        "(soft-deleted; purge it first).",
+    );
+  }
+  entry.id = newId;
+  const accessed = lastAccess.get(oldId);
+  lastAccess.delete(oldId);
+  if (accessed !== undefined) lastAccess.set(newId, accessed);
+  return entry;
+}
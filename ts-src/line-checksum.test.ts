@@ -0,0 +1,19 @@
+import { describe, it, expect } from "vitest";
+import { withChecksum, splitChecksum } from "./line-checksum.js";
+
+describe("line checksums", () => {
+  const json = JSON.stringify({ id: "a", text: "tab\there" });
+
+  it("should round-trip a line", () => {
+    expect(splitChecksum(withChecksum(json))).toEqual({ json, valid: true });
+  });
+
+  it("should detect a changed record", () => {
+    const line = withChecksum(json).replace('"a"', '"b"');
+    expect(splitChecksum(line).valid).toBe(false);
+  });
+
+  it("should pass through lines without a checksum", () => {
+    expect(splitChecksum(json)).toEqual({ json });
+  });
+});
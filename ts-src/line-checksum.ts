@@ -0,0 +1,40 @@
+import { createHash } from "crypto";
+
+/** Separates a record from its checksum; JSON never holds a raw tab. */
+const CHECKSUM_SEPARATOR = "\t";
+
+/** Hex digits of SHA-256 kept per line; enough to catch corruption. */
+const CHECKSUM_LENGTH = 8;
+
+/** Matches a trailing checksum so lines can be read with or without one. */
+const TRAILING_CHECKSUM = new RegExp(
+  `${CHECKSUM_SEPARATOR}([0-9a-f]{${CHECKSUM_LENGTH}})$`,
+);
+
+/** Short checksum of one serialised record. */
+export function lineChecksum(json: string): string {
+  return createHash("sha256")
+    .update(json)
+    .digest("hex")
+    .slice(0, CHECKSUM_LENGTH);
+}
+
+/** Appends the record's checksum, tab-separated, to a JSON line. */
+export function withChecksum(json: string): string {
+  return `${json}${CHECKSUM_SEPARATOR}${lineChecksum(json)}`;
+}
+
+/** A stored line split into its JSON record and optional checksum. */
+export interface CheckedLine {
+  json: string;
+  /** Whether the line's checksum matches; undefined if it has none. */
+  valid?: boolean;
+}
+
+/** Splits off and verifies a trailing checksum, if the line has one. */
+export function splitChecksum(line: string): CheckedLine {
+  const match = TRAILING_CHECKSUM.exec(line);
+  if (!match) return { json: line };
+  const json = line.slice(0, match.index);
+  return { json, valid: lineChecksum(json) === match[1] };
+}
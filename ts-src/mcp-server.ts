@@ -4,54 +4,192 @@ import { SSEServerTransport } from "@modelcontextprotocol/sdk/server/sse.js";
 import {
   CallToolRequestSchema,
   ListToolsRequestSchema,
+  ListResourcesRequestSchema,
+  ReadResourceRequestSchema,
 } from "@modelcontextprotocol/sdk/types.js";
+import * as fs from "fs/promises";
 import express from "express";
 import cors from "cors";
-import { VecFSStorage } from "./storage.js";
+import { VecFSStorage, DEFAULT_SEARCH_LIMIT } from "./storage.js";
 import { toolDefinitions } from "./tool-schemas.js";
 import { createToolHandlers } from "./tool-handlers.js";
+import { ToolHandlerMap } from "./tool-context.js";
+import { loadConfig } from "./config.js";
+import { callWithTimeout } from "./tool-timeout.js";
+import { JsonRpcBatcher } from "./stdio-batching.js";
+import { RpcError, INVALID_PARAMS_CODE } from "./rpc-errors.js";
+import { formatSearchTable, parseQueryVector } from "./search-cli.js";
+import { embedWithCommand } from "./reindex-cli.js";
+import {
+  IMPORT_BATCH_SIZE,
+  importCorpus,
+  parseCorpus,
+} from "./import-cli.js";
+import { ShardedStorage } from "./shards.js";
+import { createResourceHandlers } from "./resources.js";
+import { loadMetricsModule } from "./metrics-module.js";
 
 /**
  * The VecFS MCP Server.
  *
  * Provides vector storage and search capabilities to connected agents
- * via the Model Context Protocol.
+ * via the Model Context Protocol. Tool calls are answered by `handlers`,
+ * each limited to `toolTimeoutMs`.
  */
-const dataFile = process.env.VECFS_FILE || "./vecfs-data.jsonl";
-const storage = new VecFSStorage(dataFile);
-const handlers = createToolHandlers(storage);
-
-const server = new Server(
-  { name: "vecfs-server", version: "0.1.0" },
-  { capabilities: { tools: {} } },
-);
-
-server.setRequestHandler(ListToolsRequestSchema, async () => ({
-  tools: toolDefinitions,
-}));
-
-server.setRequestHandler(CallToolRequestSchema, async (request) => {
-  const { name, arguments: args } = request.params;
-  const handler = handlers[name];
-  if (!handler) {
-    throw new Error(`Unknown tool: ${name}`);
-  }
-  return handler(args);
-});
+function createServer(
+  handlers: ToolHandlerMap,
+  resources: ReturnType<typeof createResourceHandlers>,
+  toolTimeoutMs: number | undefined,
+): Server {
+  const server = new Server(
+    { name: "vecfs-server", version: "0.1.0" },
+    { capabilities: { tools: {}, resources: {} } },
+  );
+
+  server.setRequestHandler(ListToolsRequestSchema, async () => ({
+    tools: toolDefinitions,
+  }));
+
+  server.setRequestHandler(CallToolRequestSchema, async (request) => {
+    const { name, arguments: args } = request.params;
+    const handler = handlers[name];
+    if (!handler) {
+      throw new RpcError(INVALID_PARAMS_CODE, `Unknown tool: ${name}`);
+    }
+    return callWithTimeout(name, toolTimeoutMs, (ctx) => handler(args, ctx));
+  });
+
+  server.setRequestHandler(ListResourcesRequestSchema, async (request) =>
+    resources.list(request.params?.cursor),
+  );
+
+  server.setRequestHandler(ReadResourceRequestSchema, async (request) =>
+    resources.read(request.params.uri),
+  );
+
+  return server;
+}
+
+/** Reads all of standard input as text, for CLI subcommands. */
+async function readStdin(): Promise<string> {
+  const chunks: Buffer[] = [];
+  for await (const chunk of process.stdin) chunks.push(chunk);
+  return Buffer.concat(chunks).toString("utf-8");
+}
 
 /**
  * Main entry point.
  * Initialises storage then connects the server to either stdio or HTTP/SSE.
+ * With the `compact` subcommand, removes expired entries, compacts the
+ * storage file and exits; with `purge [days]`, removes entries soft-deleted
+ * at least that many days ago (default 0, meaning all of them) and exits;
+ * with `search [limit]`, searches with the vector read from stdin and
+ * prints the results as a table, or as JSON with `--json`; with `reindex`,
+ * re-embeds each entry's stored text with vecfs-embed and exits; with
+ * `import <file>`, embeds and stores the rows of a JSONL or CSV file,
+ * running up to `--concurrency N` vecfs-embed batches at once, or only
+ * counts them with `--dry-run`, and exits.
  */
 async function main() {
+  // Custom metrics are registered first so VECFS_SEARCH_METRIC can name them.
+  await loadMetricsModule(process.env.VECFS_METRICS_MODULE?.trim());
+  const config = loadConfig();
+  const storage = new VecFSStorage(config.dataFile, config.storage);
+  const shards = config.shardDir
+    ? new ShardedStorage(config.shardDir, config.storage)
+    : undefined;
+  const handlers = createToolHandlers(storage, { ...config.tools, shards });
+  process.on("SIGINT", () => shutdown(storage, shards));
+  process.on("SIGTERM", () => shutdown(storage, shards));
+
   await storage.ensureFile();
 
   const args = process.argv.slice(2);
+  if (args[0] === "compact") {
+    await storage.sweep();
+    await storage.compact();
+    await storage.close();
+    console.error(`Compacted ${config.dataFile}`);
+    return;
+  }
+  if (args[0] === "search") {
+    const [limitArg] = args.slice(1).filter((a) => !a.startsWith("--"));
+    const limit = limitArg ? parseInt(limitArg, 10) : DEFAULT_SEARCH_LIMIT;
+    if (!Number.isInteger(limit) || limit < 1) {
+      throw new Error(`search expects a positive limit, got '${limitArg}'.`);
+    }
+    const input = await readStdin();
+    const results = await storage.search(parseQueryVector(input), limit);
+    process.stdout.write(
+      args.includes("--json")
+        ? JSON.stringify(results, null, 2) + "\n"
+        : formatSearchTable(results, {
+            width: process.stdout.columns,
+            color: process.stdout.isTTY,
+          }),
+    );
+    return;
+  }
+  if (args[0] === "reindex") {
+    const { reindexed, skipped } = await storage.reindex(embedWithCommand);
+    await storage.close();
+    console.error(
+      `Reindexed ${reindexed} entries; skipped ${skipped} without stored text`,
+    );
+    return;
+  }
+  if (args[0] === "import") {
+    const flagAt = args.indexOf("--concurrency");
+    const concurrencyArg = flagAt < 0 ? "1" : args[flagAt + 1];
+    const concurrency = Number(concurrencyArg);
+    if (!Number.isInteger(concurrency) || concurrency < 1) {
+      throw new Error(
+        `import expects a positive --concurrency, got '${concurrencyArg}'.`,
+      );
+    }
+    const [file] = args.filter(
+      (a, i) => i > 0 && !a.startsWith("--") && i !== flagAt + 1,
+    );
+    if (!file) throw new Error("import expects a JSONL or CSV file.");
+    const rows = parseCorpus(await fs.readFile(file, "utf-8"), file);
+    if (args.includes("--dry-run")) {
+      console.error(`Would import ${rows.length} rows from ${file}`);
+      return;
+    }
+    await importCorpus(
+      rows,
+      embedWithCommand,
+      (entries) => handlers.memorize_batch({ entries }),
+      IMPORT_BATCH_SIZE,
+      concurrency,
+    );
+    await storage.close();
+    console.error(`Imported ${rows.length} rows from ${file}`);
+    return;
+  }
+  if (args[0] === "purge") {
+    const days = Number(args[1] ?? "0");
+    if (!Number.isFinite(days) || days < 0) {
+      throw new Error(`purge expects a number of days, got '${args[1]}'.`);
+    }
+    const purged = await storage.purge(days * 24 * 60 * 60 * 1000);
+    await storage.close();
+    console.error(`Purged ${purged} soft-deleted entries`);
+    return;
+  }
   const mode = args.includes("--http") ? "http" : "stdio";
-  const port = parseInt(process.env.PORT || "3000", 10);
+  const server = createServer(
+    handlers,
+    createResourceHandlers(storage),
+    config.toolTimeoutMs,
+  );
 
   if (mode === "stdio") {
-    const transport = new StdioServerTransport();
+    // The SDK transport reads one message per line; the batcher lets
+    // clients send JSON-RPC batches too.
+    const batcher = new JsonRpcBatcher(process.stdout);
+    process.stdin.pipe(batcher.input);
+    const transport = new StdioServerTransport(batcher.input, batcher.output);
     await server.connect(transport);
     console.error("VecFS MCP Server running on stdio");
   } else {
@@ -73,13 +211,25 @@ async function main() {
       await transport.handlePostMessage(req, res);
     });
 
-    app.listen(port, () => {
-      console.log(`VecFS MCP Server running on HTTP port ${port}`);
-      console.log(`SSE endpoint: http://localhost:${port}/sse`);
+    app.listen(config.port, () => {
+      console.log(`VecFS MCP Server running on HTTP port ${config.port}`);
+      console.log(`SSE endpoint: http://localhost:${config.port}/sse`);
     });
   }
 }
 
+/**
+ * Writes any batched or buffered changes before the process exits.
+ */
+async function shutdown(storage: VecFSStorage, shards?: ShardedStorage) {
+  try {
+    await storage.close();
+    await shards?.close();
+  } finally {
+    process.exit(0);
+  }
+}
+
 main().catch((error) => {
   console.error("Fatal error in main():", error);
   process.exit(1);
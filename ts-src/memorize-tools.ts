@@ -0,0 +1,143 @@
+import { z } from "zod";
+import { ToolCallContext } from "./tool-timeout.js";
+import {
+  ToolContext,
+  ToolHandlerMap,
+  ToolHandlerOptions,
+  ToolResult,
+  DEFAULT_STORE_TEXT_MAX_CHARS,
+  enterStage,
+} from "./tool-context.js";
+import {
+  vectorShapeSchema,
+  ensureVectorIsObjectOrArray,
+  namespaceSchema,
+  validateArgs,
+  normalizeVector,
+} from "./tool-args.js";
+
+const memorizeArgsSchema = z.object({
+  id: z.string(),
+  text: z.string().optional(),
+  vector: vectorShapeSchema,
+  metadata: z.record(z.string(), z.unknown()).optional(),
+  ttlSeconds: z.number().positive().optional(),
+  namespace: namespaceSchema.optional(),
+  idempotencyKey: z.string().min(1).optional(),
+});
+
+/**
+ * A batch of memorize entries. The array, and each entry's vector, may
+ * arrive as JSON strings from clients that stringify nested arguments.
+ */
+const memorizeBatchArgsSchema = z.object({
+  entries: z.preprocess(
+    (v) => {
+      const list = typeof v === "string" ? JSON.parse(v) : v;
+      return Array.isArray(list) ? list.map(ensureVectorIsObjectOrArray) : list;
+    },
+    z.array(memorizeArgsSchema.omit({ namespace: true })).min(1),
+  ),
+  namespace: namespaceSchema.optional(),
+});
+
+/**
+ * The `expiresAt` field for an entry memorized with a TTL, if any, measured
+ * from `now`.
+ */
+function expiry(
+  ttlSeconds: number | undefined,
+  now: number,
+): { expiresAt?: number } {
+  return ttlSeconds === undefined ? {} : { expiresAt: now + ttlSeconds * 1000 };
+}
+
+/**
+ * Applies the configured store-text mode to memorized text.
+ * Truncated text keeps the first `maxChars` characters followed by an ellipsis.
+ */
+function textToStore(
+  text: string | undefined,
+  options: ToolHandlerOptions,
+): string | undefined {
+  if (text === undefined) return undefined;
+  switch (options.storeText ?? "full") {
+    case "none":
+      return undefined;
+    case "truncated": {
+      const maxChars =
+        options.storeTextMaxChars ?? DEFAULT_STORE_TEXT_MAX_CHARS;
+      return text.length > maxChars ? text.slice(0, maxChars) + "…" : text;
+    }
+    default:
+      return text;
+  }
+}
+
+/** Builds the `memorize` and `memorize_batch` tools, which store entries. */
+export function memorizeTools(context: ToolContext): ToolHandlerMap {
+  const { options, storageFor, memorized } = context;
+  return {
+    async memorize(args: unknown, ctx?: ToolCallContext): Promise<ToolResult> {
+      const {
+        id,
+        text,
+        vector,
+        metadata,
+        ttlSeconds,
+        namespace,
+        idempotencyKey,
+      } = validateArgs(
+        memorizeArgsSchema,
+        ensureVectorIsObjectOrArray(args),
+        "memorize",
+      );
+      const sparseVector = normalizeVector(vector);
+      const write = async (): Promise<ToolResult> => {
+        enterStage(ctx, "storage");
+        const store = await storageFor(namespace);
+        await store.store({
+          id,
+          vector: sparseVector,
+          metadata: { ...metadata, text: textToStore(text, options) },
+          score: 0,
+          ...expiry(ttlSeconds, store.now()),
+        });
+        return {
+          content: [{ type: "text", text: `Stored entry: ${id}` }],
+        };
+      };
+      // A retry with the same key gets the first call's result, unwritten.
+      // Keys are per namespace, so tenants cannot replay each other's calls.
+      return idempotencyKey === undefined
+        ? write()
+        : memorized.run(JSON.stringify([namespace, idempotencyKey]), write);
+    },
+
+    async memorize_batch(
+      args: unknown,
+      ctx?: ToolCallContext,
+    ): Promise<ToolResult> {
+      const { entries, namespace } = validateArgs(
+        memorizeBatchArgsSchema,
+        args,
+        "memorize_batch",
+      );
+      const store = await storageFor(namespace);
+      const now = store.now();
+      const batch = entries.map((entry) => ({
+        id: entry.id,
+        vector: normalizeVector(entry.vector),
+        metadata: { ...entry.metadata, text: textToStore(entry.text, options) },
+        score: 0,
+        ...expiry(entry.ttlSeconds, now),
+      }));
+      enterStage(ctx, "storage");
+      const created = await store.storeBatch(batch);
+      const text =
+        `Stored ${batch.length} entries: ` +
+        `${created} new, ${batch.length - created} updated.`;
+      return { content: [{ type: "text", text }] };
+    },
+  };
+}
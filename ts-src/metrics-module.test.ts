@@ -0,0 +1,51 @@
+import { describe, it, expect, beforeAll, afterAll } from "vitest";
+import * as fs from "fs/promises";
+import * as os from "os";
+import * as path from "path";
+import { loadMetricsModule } from "./metrics-module.js";
+import { similarityFunction, similarityNames } from "./sparse-vector.js";
+
+describe("loadMetricsModule", () => {
+  let dir: string;
+
+  beforeAll(async () => {
+    dir = await fs.mkdtemp(path.join(os.tmpdir(), "vecfs-metrics-"));
+  });
+
+  afterAll(async () => {
+    await fs.rm(dir, { recursive: true, force: true });
+  });
+
+  it("should register the metrics the module adds", async () => {
+    const file = path.join(dir, "overlap.mjs");
+    await fs.writeFile(
+      file,
+      `export default (register) =>
+  register("module-overlap", (query, vector) =>
+    Object.keys(query).filter((dim) => dim in vector).length,
+  );
+`,
+    );
+
+    await loadMetricsModule(file);
+
+    expect(similarityNames()).toContain("module-overlap");
+    const overlap = similarityFunction("module-overlap");
+    expect(overlap({ 0: 1, 1: 1 }, { 1: 5, 2: 1 }, 0)).toBe(1);
+  });
+
+  it("should reject a module without a default function", async () => {
+    const file = path.join(dir, "empty.mjs");
+    await fs.writeFile(file, "export const metric = 1;\n");
+
+    await expect(loadMetricsModule(file)).rejects.toThrow(
+      "must export a default function",
+    );
+  });
+
+  it("should do nothing without a path", async () => {
+    const before = similarityNames();
+    await loadMetricsModule(undefined);
+    expect(similarityNames()).toEqual(before);
+  });
+});
@@ -0,0 +1,39 @@
+/**
+ * Loads custom similarity metrics from the module named by
+ * `VECFS_METRICS_MODULE`.
+ *
+ * The server is bundled into a single file, so a module that imported the
+ * metric registry itself would get a separate copy that searches never
+ * see. Instead the module's default export is called with the server's own
+ * {@link registerSimilarity}.
+ */
+
+import * as path from "path";
+import { pathToFileURL } from "url";
+import { registerSimilarity } from "./sparse-vector.js";
+
+/** The default export a metrics module must provide. */
+export type RegisterMetrics = (
+  register: typeof registerSimilarity,
+) => void | Promise<void>;
+
+/**
+ * Imports the metrics module at `modulePath`, resolved against the working
+ * directory, and lets it register its metrics. Does nothing without a path.
+ *
+ * @throws Error if the module cannot be imported or its default export is
+ *   not a function.
+ */
+export async function loadMetricsModule(
+  modulePath: string | undefined,
+): Promise<void> {
+  if (!modulePath) return;
+  const module = await import(pathToFileURL(path.resolve(modulePath)).href);
+  if (typeof module.default !== "function") {
+    throw new Error(
+      `VECFS_METRICS_MODULE ${modulePath} must export a default function ` +
+        "that registers its metrics.",
+    );
+  }
+  await (module.default as RegisterMetrics)(registerSimilarity);
+}
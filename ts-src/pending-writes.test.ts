@@ -0,0 +1,185 @@
+import { describe, it, expect, beforeEach, afterEach } from "vitest";
+import { VecFSStorage } from "./storage.js";
+import { StorageOptions } from "./storage-options.js";
+import * as fs from "fs/promises";
+
+describe("pending writes", () => {
+  const testFilePath = "./test-pending-writes.jsonl";
+
+  beforeEach(async () => {
+    try {
+      await fs.unlink(testFilePath);
+    } catch {}
+  });
+
+  afterEach(async () => {
+    try {
+      await fs.unlink(testFilePath);
+    } catch {}
+  });
+
+  describe("score coalescing", () => {
+    async function readRecords() {
+      return (await fs.readFile(testFilePath, "utf-8"))
+        .trim()
+        .split("\n")
+        .map((l) => JSON.parse(l));
+    }
+
+    async function storeOne(options: StorageOptions) {
+      const storage = new VecFSStorage(testFilePath, options);
+      await storage.ensureFile();
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      return storage;
+    }
+
+    it("should write several score updates in a single append", async () => {
+      const storage = await storeOne({
+        appendOnly: true,
+        scoreFlushMs: 60_000,
+      });
+
+      await storage.updateScore("a", 1);
+      await storage.updateScore("a", 1);
+      await storage.updateScore("a", 1);
+      expect(await readRecords()).toHaveLength(1);
+
+      await storage.flush();
+      const records = await readRecords();
+      expect(records).toHaveLength(2);
+      expect(records[1].score).toBe(3);
+    });
+
+    it("should let reads see buffered scores", async () => {
+      const storage = await storeOne({ scoreFlushMs: 60_000 });
+
+      await storage.updateScore("a", 2);
+      expect((await storage.get("a"))?.score).toBe(2);
+      expect((await readRecords())[0].score).toBe(0);
+      await storage.flush();
+    });
+
+    it("should flush once the dirty threshold is reached", async () => {
+      const storage = await storeOne({ scoreFlushThreshold: 2 });
+      await storage.store({
+        id: "b",
+        vector: { 1: 1 },
+        metadata: {},
+        score: 0,
+      });
+
+      await storage.updateScore("a", 1);
+      expect((await readRecords())[0].score).toBe(0);
+      await storage.updateScore("b", 1);
+      expect((await readRecords()).map((r) => r.score)).toEqual([1, 1]);
+    });
+
+    it("should flush after the interval elapses", async () => {
+      const storage = await storeOne({ scoreFlushMs: 10 });
+
+      await storage.updateScore("a", 5);
+      await new Promise((resolve) => setTimeout(resolve, 50));
+      expect((await readRecords())[0].score).toBe(5);
+    });
+
+    it("should not resurrect an entry deleted before the flush", async () => {
+      const storage = await storeOne({
+        appendOnly: true,
+        scoreFlushMs: 60_000,
+      });
+
+      await storage.updateScore("a", 1);
+      await storage.delete("a");
+      await storage.flush();
+
+      const reloaded = new VecFSStorage(testFilePath);
+      expect(await reloaded.get("a")).toBeUndefined();
+    });
+  });
+
+  describe("batched writes", () => {
+    async function readFile() {
+      return fs.readFile(testFilePath, "utf-8");
+    }
+
+    it("should keep mutations in memory until flushed", async () => {
+      const storage = new VecFSStorage(testFilePath, { batchWrites: true });
+      await storage.ensureFile();
+
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await storage.store({
+        id: "b",
+        vector: { 1: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await storage.updateScore("a", 2);
+      await storage.delete("b");
+      expect(await readFile()).toBe("");
+      expect((await storage.get("a"))?.score).toBe(2);
+
+      await storage.flush();
+      const reopened = new VecFSStorage(testFilePath);
+      expect((await reopened.get("a"))?.score).toBe(2);
+      expect(await reopened.get("b")).toBeUndefined();
+    });
+
+    it("should flush on close", async () => {
+      const storage = new VecFSStorage(testFilePath, { batchWrites: true });
+      await storage.ensureFile();
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await storage.rename("a", "z");
+
+      await storage.close();
+      const reopened = new VecFSStorage(testFilePath);
+      const page = await reopened.list();
+      expect(page.entries.map((e) => e.id)).toEqual(["z"]);
+    });
+
+    it("should flush in the background on an interval", async () => {
+      const storage = new VecFSStorage(testFilePath, {
+        batchWrites: true,
+        flushIntervalMs: 10,
+      });
+      await storage.ensureFile();
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+
+      await new Promise((resolve) => setTimeout(resolve, 50));
+      const reopened = new VecFSStorage(testFilePath);
+      expect(await reopened.get("a")).toBeDefined();
+    });
+
+    it("should still write every mutation by default", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.ensureFile();
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+
+      expect(await readFile()).toContain('"id":"a"');
+    });
+  });
+});
@@ -0,0 +1,97 @@
+import { VecFSEntry } from "./types.js";
+import { StorageOptions } from "./storage-options.js";
+
+/** The options that hold writes back instead of making them at once. */
+export type PendingWriteOptions = Pick<
+  StorageOptions,
+  "flushIntervalMs" | "scoreFlushMs" | "scoreFlushThreshold"
+>;
+
+/**
+ * Writes a {@link VecFSStorage} is holding back, under `batchWrites` or
+ * score coalescing, and the timer that flushes them in the background.
+ * Only records what is pending; the store does the writing.
+ */
+export class PendingWrites {
+  private options: PendingWriteOptions;
+  private flush: () => void;
+  private scores = new Set<VecFSEntry>();
+  private rewrite = false;
+  private timer: NodeJS.Timeout | null = null;
+
+  /**
+   * @param flush - Starts a flush of the store when the timer fires. It
+   *   must handle its own errors, as nothing awaits it.
+   */
+  constructor(options: PendingWriteOptions, flush: () => void) {
+    this.options = options;
+    this.flush = flush;
+  }
+
+  /** Whether score updates are buffered rather than written per call. */
+  coalescesScores(): boolean {
+    const { scoreFlushMs, scoreFlushThreshold } = this.options;
+    return scoreFlushMs !== undefined || scoreFlushThreshold !== undefined;
+  }
+
+  /**
+   * Records that the file is out of date and must be rewritten, scheduling
+   * a background flush when `flushIntervalMs` is set.
+   */
+  deferRewrite(): void {
+    this.rewrite = true;
+    if (this.options.flushIntervalMs !== undefined) {
+      this.schedule(this.options.flushIntervalMs);
+    }
+  }
+
+  /** Whether a rewrite has been deferred since the last one. */
+  rewritePending(): boolean {
+    return this.rewrite;
+  }
+
+  /**
+   * Marks an entry's score as unsaved, scheduling a timed flush unless the
+   * dirty threshold has been reached.
+   *
+   * @returns true if the threshold is reached and the scores should be
+   *          written now.
+   */
+  deferScore(entry: VecFSEntry): boolean {
+    this.scores.add(entry);
+    const { scoreFlushMs, scoreFlushThreshold } = this.options;
+    if (
+      scoreFlushThreshold !== undefined &&
+      this.scores.size >= scoreFlushThreshold
+    ) {
+      return true;
+    }
+    if (scoreFlushMs !== undefined) this.schedule(scoreFlushMs);
+    return false;
+  }
+
+  /**
+   * Takes the entries whose scores are unsaved, cancelling any scheduled
+   * flush since the caller is about to write.
+   */
+  takeScores(): VecFSEntry[] {
+    if (this.timer) clearTimeout(this.timer);
+    this.timer = null;
+    const scores = [...this.scores];
+    this.scores.clear();
+    return scores;
+  }
+
+  /** Forgets every pending write once the whole file has been rewritten. */
+  clear(): void {
+    this.scores.clear();
+    this.rewrite = false;
+  }
+
+  /** Starts a background flush after `delayMs` unless one is pending. */
+  private schedule(delayMs: number): void {
+    if (this.timer) return;
+    this.timer = setTimeout(this.flush, delayMs);
+    this.timer.unref();
+  }
+}
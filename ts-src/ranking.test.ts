@@ -0,0 +1,666 @@
+import { describe, it, expect, beforeEach, afterEach } from "vitest";
+import { VecFSStorage } from "./storage.js";
+import { StorageOptions } from "./storage-options.js";
+import { SparseVector } from "./types.js";
+import { registerSimilarity } from "./sparse-vector.js";
+import { seededRandom, randomSparseVector } from "./seeded-random.js";
+import { fixedClock } from "./fixed-clock.js";
+import * as fs from "fs/promises";
+
+describe("ranking", () => {
+  const testFilePath = "./test-ranking.jsonl";
+
+  beforeEach(async () => {
+    try {
+      await fs.unlink(testFilePath);
+    } catch {}
+  });
+
+  afterEach(async () => {
+    try {
+      await fs.unlink(testFilePath);
+    } catch {}
+  });
+
+  it("should sort search results by similarity", async () => {
+    const storage = new VecFSStorage(testFilePath);
+    await storage.ensureFile();
+
+    await storage.store({ id: "1", vector: { 0: 1 }, metadata: {}, score: 0 });
+    await storage.store({
+      id: "2",
+      vector: { 0: 0.5 },
+      metadata: {},
+      score: 0,
+    });
+
+    const results = await storage.search({ 0: 1 });
+    expect(results[0].id).toBe("1");
+    expect(results[1].id).toBe("2");
+  });
+
+  it("should boost ranking by feedback score when similarity is equal", async () => {
+    const storage = new VecFSStorage(testFilePath);
+    await storage.ensureFile();
+
+    const sameVector = { 0: 1, 1: 0 };
+    await storage.store({
+      id: "low-feedback",
+      vector: sameVector,
+      metadata: {},
+      score: 0,
+    });
+    await storage.store({
+      id: "high-feedback",
+      vector: sameVector,
+      metadata: {},
+      score: 10,
+    });
+
+    const results = await storage.search({ 0: 1, 1: 0 });
+    expect(results).toHaveLength(2);
+    expect(results[0].id).toBe("high-feedback");
+    expect(results[1].id).toBe("low-feedback");
+  });
+
+  it("should rank a high-priority entry above a slightly more similar one under boost", async () => {
+    const storage = new VecFSStorage(testFilePath);
+    await storage.ensureFile();
+
+    await storage.store({
+      id: "similar",
+      vector: { 0: 1, 1: 0.1 },
+      metadata: { priority: 0 },
+      score: 0,
+    });
+    await storage.store({
+      id: "important",
+      vector: { 0: 1, 1: 0.3 },
+      metadata: { priority: 5 },
+      score: 0,
+    });
+
+    const plain = await storage.search({ 0: 1 });
+    expect(plain[0].id).toBe("similar");
+
+    const boosted = await storage.search({ 0: 1 }, 5, {
+      boostField: "priority",
+      boostWeight: 0.1,
+    });
+    expect(boosted[0].id).toBe("important");
+    expect(boosted[1].id).toBe("similar");
+  });
+
+  it("should reject a non-numeric boost field", async () => {
+    const storage = new VecFSStorage(testFilePath);
+    await storage.ensureFile();
+
+    await storage.store({
+      id: "1",
+      vector: { 0: 1 },
+      metadata: { priority: "high" },
+      score: 0,
+    });
+
+    await expect(
+      storage.search({ 0: 1 }, 5, { boostField: "priority" }),
+    ).rejects.toThrow("not numeric");
+  });
+
+  describe("metadata filter", () => {
+    async function storeTeams() {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.ensureFile();
+      const teams = [
+        { id: "a", metadata: { source: "slack", team: "infra" } },
+        { id: "b", metadata: { source: "slack", team: "web" } },
+        { id: "c", metadata: { source: "email", team: "infra" } },
+      ];
+      for (const { id, metadata } of teams) {
+        await storage.store({ id, vector: { 0: 1 }, metadata, score: 0 });
+      }
+      return storage;
+    }
+
+    it("should only return entries matching every constraint", async () => {
+      const storage = await storeTeams();
+
+      const results = await storage.search({ 0: 1 }, 5, {
+        filter: { source: "slack", team: "infra" },
+      });
+      expect(results.map((r) => r.id)).toEqual(["a"]);
+    });
+
+    it("should apply the limit after filtering", async () => {
+      const storage = await storeTeams();
+
+      const results = await storage.search({ 0: 1 }, 1, {
+        filter: { team: "infra" },
+      });
+      expect(results).toHaveLength(1);
+      expect(["a", "c"]).toContain(results[0].id);
+    });
+
+    it("should treat an empty filter as no filter", async () => {
+      const storage = await storeTeams();
+
+      const results = await storage.search({ 0: 1 }, 5, { filter: {} });
+      expect(results).toHaveLength(3);
+    });
+
+    it("should match numbers, booleans and array elements", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.ensureFile();
+      await storage.store({
+        id: "x",
+        vector: { 0: 1 },
+        metadata: { priority: 1, pinned: true, tags: ["ops", "db"] },
+        score: 0,
+      });
+
+      const hits = await storage.search({ 0: 1 }, 5, {
+        filter: { priority: 1, pinned: true, tags: "db" },
+      });
+      expect(hits).toHaveLength(1);
+      const misses = await storage.search({ 0: 1 }, 5, {
+        filter: { priority: "1" },
+      });
+      expect(misses).toHaveLength(0);
+    });
+  });
+
+  describe("time range", () => {
+    async function storeDays() {
+      const clock = fixedClock(0);
+      const storage = new VecFSStorage(testFilePath, { clock });
+      const days = [
+        { id: "mon", at: 1000, team: "infra" },
+        { id: "tue", at: 2000, team: "web" },
+        { id: "wed", at: 3000, team: "infra" },
+      ];
+      for (const { id, at, team } of days) {
+        clock.ms = at;
+        await storage.store({
+          id,
+          vector: { 0: 1 },
+          metadata: { team },
+          score: 0,
+        });
+      }
+      return storage;
+    }
+
+    it("should exclude entries outside the window", async () => {
+      const storage = await storeDays();
+
+      const after = await storage.search({ 0: 1 }, 5, { after: 2000 });
+      expect(after.map((r) => r.id).sort()).toEqual(["tue", "wed"]);
+      const before = await storage.search({ 0: 1 }, 5, { before: 2000 });
+      expect(before.map((r) => r.id)).toEqual(["mon"]);
+      const both = await storage.search({ 0: 1 }, 5, {
+        after: 1500,
+        before: 2500,
+      });
+      expect(both.map((r) => r.id)).toEqual(["tue"]);
+    });
+
+    it("should combine with a metadata filter", async () => {
+      const storage = await storeDays();
+
+      const results = await storage.search({ 0: 1 }, 5, {
+        after: 1500,
+        filter: { team: "infra" },
+      });
+      expect(results.map((r) => r.id)).toEqual(["wed"]);
+    });
+  });
+
+  describe("candidate cap", () => {
+    async function storeOverlapping(storage: VecFSStorage) {
+      await storage.ensureFile();
+      // Overlap with the query {1,2,3}: one, three, two and no dimensions.
+      const vectors = [
+        { id: "one", vector: { 1: 1, 9: 5 } },
+        { id: "three", vector: { 1: 1, 2: 1, 3: 1 } },
+        { id: "two", vector: { 1: 1, 2: 1 } },
+        { id: "none", vector: { 8: 1 } },
+      ];
+      for (const { id, vector } of vectors) {
+        await storage.store({ id, vector, metadata: {}, score: 0 });
+      }
+    }
+
+    it("should only score the highest-overlap candidates", async () => {
+      const storage = new VecFSStorage(testFilePath, { candidateCap: 2 });
+      await storeOverlapping(storage);
+
+      const ranked = await storage.rank({ 1: 1, 2: 1, 3: 1 });
+      expect(ranked.map((r) => r.id)).toEqual(["three", "two"]);
+    });
+
+    it("should rank the best candidates like an uncapped search", async () => {
+      const capped = new VecFSStorage(testFilePath, { candidateCap: 3 });
+      await storeOverlapping(capped);
+      const uncapped = new VecFSStorage(testFilePath);
+
+      const query = { 1: 1, 2: 1, 3: 1 };
+      const [best] = await capped.search(query, 1);
+      const [expected] = await uncapped.search(query, 1);
+      expect(best.id).toBe(expected.id);
+      expect(best.similarity).toBeCloseTo(expected.similarity);
+    });
+
+    it("should see entries stored after the index was built", async () => {
+      const storage = new VecFSStorage(testFilePath, { candidateCap: 5 });
+      await storeOverlapping(storage);
+      await storage.rank({ 1: 1 });
+
+      await storage.store({
+        id: "late",
+        vector: { 4: 1 },
+        metadata: {},
+        score: 0,
+      });
+      const [hit] = await storage.search({ 4: 1 }, 1);
+      expect(hit.id).toBe("late");
+    });
+
+    it("should count only entries that pass the filter", async () => {
+      const storage = new VecFSStorage(testFilePath, { candidateCap: 1 });
+      await storage.ensureFile();
+      await storage.store({
+        id: "a",
+        vector: { 1: 1, 2: 1 },
+        metadata: { team: "web" },
+        score: 0,
+      });
+      await storage.store({
+        id: "b",
+        vector: { 1: 1 },
+        metadata: { team: "infra" },
+        score: 0,
+      });
+
+      const ranked = await storage.rank({ 1: 1, 2: 1 }, {
+        filter: { team: "infra" },
+      });
+      expect(ranked.map((r) => r.id)).toEqual(["b"]);
+    });
+  });
+
+  describe("dropZero", () => {
+    async function storeMixed() {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.ensureFile();
+      const vectors = [
+        { id: "shares-1", vector: { 1: 1, 5: 1 } },
+        { id: "shares-2", vector: { 2: 1 } },
+        { id: "disjoint-1", vector: { 7: 1 } },
+        { id: "disjoint-2", vector: { 8: 1 } },
+      ];
+      for (const { id, vector } of vectors) {
+        await storage.store({ id, vector, metadata: {}, score: 0 });
+      }
+      // Feedback would otherwise lift a disjoint entry into the results.
+      await storage.updateScore("disjoint-1", 10);
+      return storage;
+    }
+
+    it("should only return entries sharing a dimension", async () => {
+      const storage = await storeMixed();
+
+      const results = await storage.search({ 1: 1, 2: 1 }, 10, {
+        dropZero: true,
+      });
+      expect(results.map((r) => r.id).sort()).toEqual(["shares-1", "shares-2"]);
+    });
+
+    it("should pad with zero-similarity entries by default", async () => {
+      const storage = await storeMixed();
+
+      const results = await storage.search({ 1: 1, 2: 1 }, 10);
+      expect(results).toHaveLength(4);
+    });
+  });
+
+  describe("metric", () => {
+    async function storeVectors(vectors: Record<string, SparseVector>) {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.ensureFile();
+      for (const [id, vector] of Object.entries(vectors)) {
+        await storage.store({ id, vector, metadata: {}, score: 0 });
+      }
+      return storage;
+    }
+
+    async function order(storage: VecFSStorage, query: SparseVector) {
+      const ids = async (metric: string) =>
+        (await storage.rank(query, { metric })).map((r) => r.id);
+      return { cosine: await ids("cosine"), dot: await ids("dot") };
+    }
+
+    it("should rank normalised vectors the same as cosine", async () => {
+      const storage = await storeVectors({
+        a: { 1: 0.6, 2: 0.8 },
+        b: { 1: 1 },
+        c: { 2: 1 },
+        d: { 1: 0.8, 3: 0.6 },
+      });
+
+      const { cosine, dot } = await order(storage, { 1: 0.8, 2: 0.6 });
+      expect(dot).toEqual(cosine);
+    });
+
+    it("should let magnitude decide for unnormalised vectors", async () => {
+      const storage = await storeVectors({
+        long: { 1: 10 },
+        aligned: { 1: 1, 2: 1 },
+      });
+
+      const { cosine, dot } = await order(storage, { 1: 1, 2: 1 });
+      expect(cosine).toEqual(["aligned", "long"]);
+      expect(dot).toEqual(["long", "aligned"]);
+    });
+
+    it("should use the storage default when no metric is given", async () => {
+      const storage = new VecFSStorage(testFilePath, { metric: "dot" });
+      await storage.ensureFile();
+      await storage.store({
+        id: "a",
+        vector: { 1: 3 },
+        metadata: {},
+        score: 0,
+      });
+
+      const [hit] = await storage.rank({ 1: 2 });
+      expect(hit.similarity).toBe(6);
+    });
+
+    it("should rank with a registered custom metric", async () => {
+      // Counts shared dimensions, ignoring weights.
+      const overlap = (query: SparseVector, vector: SparseVector) =>
+        Object.keys(query).filter((k) => k in vector).length;
+      registerSimilarity("storage-test-overlap", overlap);
+      const storage = await storeVectors({
+        heavy: { 1: 10 },
+        broad: { 1: 0.1, 2: 0.1, 3: 0.1 },
+      });
+
+      const hits = await storage.search({ 1: 1, 2: 1, 3: 1 }, 5, {
+        metric: "storage-test-overlap",
+      });
+      expect(hits.map((r) => [r.id, r.similarity])).toEqual([
+        ["broad", 3],
+        ["heavy", 1],
+      ]);
+    });
+
+    it("should reject an unregistered metric", async () => {
+      const storage = await storeVectors({ a: { 1: 1 } });
+
+      await expect(
+        storage.search({ 1: 1 }, 5, { metric: "missing" }),
+      ).rejects.toThrow("Unknown similarity metric 'missing'");
+    });
+  });
+
+  describe("tie ordering", () => {
+    it("should order equal ranks by id whatever the file order", async () => {
+      const ids = ["c", "a", "d", "b"];
+      const orders: string[][] = [];
+      for (const fileOrder of [ids, [...ids].reverse()]) {
+        const log = fileOrder.map((id, i) => ({
+          id,
+          vector: { 0: 1 },
+          metadata: {},
+          score: 0,
+          timestamp: i,
+        }));
+        await fs.writeFile(
+          testFilePath,
+          log.map((e) => JSON.stringify(e)).join("\n") + "\n",
+        );
+        const storage = new VecFSStorage(testFilePath);
+        orders.push((await storage.rank({ 0: 1 })).map((r) => r.id));
+      }
+
+      expect(orders[0]).toEqual(["a", "b", "c", "d"]);
+      expect(orders[1]).toEqual(orders[0]);
+    });
+  });
+
+  describe("top-k search", () => {
+    it("should match the full ranking for several random seeds", async () => {
+      for (const seed of [1, 7, 99]) {
+        const random = seededRandom(seed);
+        const log = Array.from({ length: 200 }, (_, i) => ({
+          id: `e-${i}`,
+          vector: randomSparseVector(random, 50, 4),
+          metadata: {},
+          score: Math.floor(random() * 3) - 1,
+          timestamp: i,
+        }));
+        await fs.writeFile(
+          testFilePath,
+          log.map((e) => JSON.stringify(e)).join("\n") + "\n",
+        );
+        const storage = new VecFSStorage(testFilePath);
+        const query = randomSparseVector(random, 50, 6);
+
+        const ranked = await storage.rank(query);
+        for (const limit of [1, 5, 50, 200, 500]) {
+          const ids = (await storage.search(query, limit)).map((r) => r.id);
+          expect(ids).toEqual(ranked.slice(0, limit).map((r) => r.id));
+        }
+      }
+    });
+  });
+
+  describe("searchPage", () => {
+    async function storeTwelve() {
+      const storage = new VecFSStorage(testFilePath);
+      for (let i = 0; i < 12; i++) {
+        await storage.store({
+          id: `e-${i}`,
+          vector: { 0: 1, [i + 1]: i / 10 },
+          metadata: {},
+          score: 0,
+        });
+      }
+      return storage;
+    }
+
+    it("should page through results without gaps or overlaps", async () => {
+      const storage = await storeTwelve();
+      const ranked = (await storage.rank({ 0: 1 })).map((r) => r.id);
+
+      const pages = [];
+      for (const offset of [0, 5, 10]) {
+        const page = await storage.searchPage({ 0: 1 }, offset, 5);
+        expect(page.total).toBe(12);
+        pages.push(page.results.map((r) => r.id));
+      }
+      expect(pages.map((p) => p.length)).toEqual([5, 5, 2]);
+      expect(pages.flat()).toEqual(ranked);
+    });
+
+    it("should return an empty page past the last result", async () => {
+      const storage = await storeTwelve();
+
+      const page = await storage.searchPage({ 0: 1 }, 20, 5);
+      expect(page).toEqual({ results: [], total: 12 });
+    });
+
+    it("should count only entries the options let through", async () => {
+      const storage = await storeTwelve();
+
+      const page = await storage.searchPage({ 5: 1 }, 0, 5, {
+        dropZero: true,
+      });
+      expect(page.results.map((r) => r.id)).toEqual(["e-4"]);
+      expect(page.total).toBe(1);
+      const none = await storage.searchPage({ 99: 1 }, 0, 5, {
+        dropZero: true,
+      });
+      expect(none).toEqual({ results: [], total: 0 });
+    });
+  });
+
+  describe("recencyTiebreak", () => {
+    async function storeAged() {
+      const log = [
+        { id: "a-old", vector: { 0: 1 }, metadata: {}, score: 0, timestamp: 1 },
+        { id: "b-new", vector: { 0: 1 }, metadata: {}, score: 0, timestamp: 9 },
+        { id: "c-far", vector: { 1: 1 }, metadata: {}, score: 0, timestamp: 5 },
+      ];
+      await fs.writeFile(
+        testFilePath,
+        log.map((e) => JSON.stringify(e)).join("\n") + "\n",
+      );
+      return new VecFSStorage(testFilePath);
+    }
+
+    it("should rank the newer of two equal entries first", async () => {
+      const storage = await storeAged();
+
+      const results = await storage.search({ 0: 1 }, 1, {
+        recencyTiebreak: true,
+      });
+      expect(results.map((r) => r.id)).toEqual(["b-new"]);
+    });
+
+    it("should fall back to id order without the flag", async () => {
+      const storage = await storeAged();
+
+      const results = await storage.search({ 0: 1 }, 2);
+      expect(results.map((r) => r.id)).toEqual(["a-old", "b-new"]);
+    });
+
+    it("should not reorder results whose ranks differ", async () => {
+      const storage = await storeAged();
+
+      const results = await storage.rank({ 0: 1, 1: 0.5 }, {
+        recencyTiebreak: true,
+      });
+      expect(results.map((r) => r.id)).toEqual(["b-new", "a-old", "c-far"]);
+    });
+  });
+
+  describe("tag boosts and penalties", () => {
+    async function storeTagged() {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.store({
+        id: "close-deprecated",
+        vector: { 0: 1 },
+        metadata: { tags: ["deprecated"] },
+        score: 0,
+      });
+      await storage.store({
+        id: "further-verified",
+        vector: { 0: 1, 1: 0.3 },
+        metadata: { tags: ["verified"] },
+        score: 0,
+      });
+      await storage.store({
+        id: "untagged",
+        vector: { 0: 1, 1: 0.4 },
+        metadata: {},
+        score: 0,
+      });
+      return storage;
+    }
+
+    async function order(options = {}) {
+      const storage = await storeTagged();
+      return (await storage.rank({ 0: 1 }, options)).map((r) => r.id);
+    }
+
+    it("should rank by similarity without tag options", async () => {
+      expect(await order()).toEqual([
+        "close-deprecated",
+        "further-verified",
+        "untagged",
+      ]);
+    });
+
+    it("should drop a penalised entry below a lower-ranked one", async () => {
+      expect(await order({ penaltyTags: ["deprecated"] })).toEqual([
+        "further-verified",
+        "untagged",
+        "close-deprecated",
+      ]);
+    });
+
+    it("should lift a boosted entry", async () => {
+      const ids = await order({ boostTags: ["verified"] });
+      expect(ids[0]).toBe("further-verified");
+    });
+  });
+
+  describe("scoreHalfLifeMs", () => {
+    const day = 24 * 60 * 60 * 1000;
+
+    async function storeOldAndNew(options: StorageOptions) {
+      const clock = fixedClock(0);
+      const storage = new VecFSStorage(testFilePath, { ...options, clock });
+      await storage.store({
+        id: "old",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 10,
+      });
+      clock.ms = 30 * day;
+      await storage.store({
+        id: "new",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 2,
+      });
+      return storage;
+    }
+
+    it("should rank an old high score first without decay", async () => {
+      const storage = await storeOldAndNew({});
+      const results = await storage.search({ 0: 1 });
+      expect(results.map((r) => r.id)).toEqual(["old", "new"]);
+    });
+
+    it("should let a fresh entry overtake a decayed score", async () => {
+      const storage = await storeOldAndNew({ scoreHalfLifeMs: day });
+      const results = await storage.search({ 0: 1 });
+      expect(results.map((r) => r.id)).toEqual(["new", "old"]);
+      expect(results[1].score).toBe(10);
+    });
+  });
+
+  describe("feedbackWeight", () => {
+    async function storeCloseAndPopular(options: StorageOptions) {
+      const storage = new VecFSStorage(testFilePath, options);
+      await storage.store({
+        id: "close",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await storage.store({
+        id: "popular",
+        vector: { 0: 1, 1: 1 },
+        metadata: {},
+        score: 10,
+      });
+      return storage;
+    }
+
+    it("should favour similarity at the default weight", async () => {
+      const storage = await storeCloseAndPopular({});
+      const results = await storage.search({ 0: 1 });
+      expect(results.map((r) => r.id)).toEqual(["close", "popular"]);
+    });
+
+    it("should let a higher weight put feedback first", async () => {
+      const storage = await storeCloseAndPopular({ feedbackWeight: 1 });
+      const results = await storage.search({ 0: 1 });
+      expect(results.map((r) => r.id)).toEqual(["popular", "close"]);
+    });
+  });
+});
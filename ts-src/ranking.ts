@@ -0,0 +1,280 @@
+import {
+  VecFSEntry,
+  SparseVector,
+  SearchResult,
+  SearchOptions,
+  MetadataFilter,
+  SimilarityMetric,
+} from "./types.js";
+import { norm, similarityFunction } from "./sparse-vector.js";
+import { InvertedIndex, overlapCandidates } from "./inverted-index.js";
+import { topK } from "./top-k.js";
+import { isExpired } from "./retention.js";
+
+/** Weight for feedback score in ranking (bounded boost so similarity stays dominant). */
+export const DEFAULT_FEEDBACK_WEIGHT = 0.1;
+
+/** Default multiplier for a metadata boost field when no weight is given. */
+const DEFAULT_BOOST_WEIGHT = 0.1;
+
+/** Rank added or removed per matching boost or penalty tag. */
+const TAG_RANK_ADJUSTMENT = 0.1;
+
+/** Ranks closer than this count as tied when breaking ties by recency. */
+const RECENCY_TIE_EPSILON = 1e-6;
+
+/** Store-wide ranking settings, taken from the store's options. */
+export interface RankingSettings {
+  /** Similarity metric used when a search does not name one. */
+  metric?: SimilarityMetric;
+  /** Most a feedback score can add to or take from a rank. */
+  feedbackWeight?: number;
+  /** Half-life of feedback scores in ms; unset means no decay. */
+  scoreHalfLifeMs?: number;
+  /** The current time in ms, which scores decay towards. */
+  now: number;
+}
+
+/**
+ * Bounded contribution of reinforcement score to ranking.
+ * Maps score to approximately (-weight, +weight) so one very high score cannot overwhelm similarity.
+ */
+function feedbackBoost(score: number, weight: number): number {
+  return weight * (score / (1 + Math.abs(score)));
+}
+
+/**
+ * Combined rank for sorting: cosine similarity plus feedback boost.
+ * Higher is better; used so positively reinforced entries rise in search results.
+ */
+function combinedRank(
+  r: { similarity: number; score: number },
+  feedbackWeight: number,
+): number {
+  return r.similarity + feedbackBoost(r.score, feedbackWeight);
+}
+
+/**
+ * The feedback score an entry counts with after `ageMs`: halved once per
+ * `halfLifeMs`, so old popularity fades. Future timestamps do not decay.
+ */
+function decayedScore(
+  score: number,
+  ageMs: number,
+  halfLifeMs: number,
+): number {
+  return score * Math.pow(0.5, Math.max(0, ageMs) / halfLifeMs);
+}
+
+/** A scored search result paired with its combined rank. */
+export interface RankedResult {
+  result: SearchResult;
+  rank: number;
+}
+
+/**
+ * Orders ranked results best first. Exact ties fall back to the entry id so
+ * the order does not depend on where entries sit in the file.
+ */
+function byRankThenId(a: RankedResult, b: RankedResult): number {
+  return b.rank - a.rank || a.result.id.localeCompare(b.result.id);
+}
+
+/**
+ * Reorders sorted results so that within each run of near-equal ranks the
+ * newest entry comes first. A run starts at its best result and takes in
+ * every following result within {@link RECENCY_TIE_EPSILON} of it, so the
+ * grouping is deterministic.
+ */
+function newestFirstWithinTies<T extends RankedResult>(sorted: T[]): T[] {
+  const reordered: T[] = [];
+  let start = 0;
+  while (start < sorted.length) {
+    let end = start + 1;
+    while (
+      end < sorted.length &&
+      sorted[start].rank - sorted[end].rank <= RECENCY_TIE_EPSILON
+    ) {
+      end++;
+    }
+    const run = sorted.slice(start, end);
+    run.sort(
+      (a, b) =>
+        b.result.timestamp - a.result.timestamp ||
+        a.result.id.localeCompare(b.result.id),
+    );
+    reordered.push(...run);
+    start = end;
+  }
+  return reordered;
+}
+
+/**
+ * The best `limit` of the scored results, in final order. Only those are
+ * sorted unless recency ordering needs the full ranking, since a tie run
+ * can straddle the limit.
+ */
+export function bestOf(
+  scored: RankedResult[],
+  limit: number,
+  recencyTiebreak = false,
+): RankedResult[] {
+  if (recencyTiebreak) {
+    return newestFirstWithinTies(scored.sort(byRankThenId)).slice(0, limit);
+  }
+  return limit < scored.length
+    ? topK(scored, limit, byRankThenId)
+    : scored.sort(byRankThenId);
+}
+
+/**
+ * Merges the {@link VecFSStorage.best} results of several stores into the
+ * overall best `limit`, ordered as a search of one store would order them.
+ */
+export function mergeRanked<T extends RankedResult>(
+  lists: T[][],
+  limit: number,
+  recencyTiebreak = false,
+): T[] {
+  const sorted = lists.flat().sort(byRankThenId);
+  const ordered = recencyTiebreak ? newestFirstWithinTies(sorted) : sorted;
+  return ordered.slice(0, limit);
+}
+
+/**
+ * Weighted contribution of a numeric metadata field to ranking.
+ * Entries without the field receive no boost; a non-numeric value is an error
+ * so that a mistyped field does not silently change the ordering.
+ */
+function metadataBoost(
+  entry: VecFSEntry,
+  field: string,
+  weight: number,
+): number {
+  const value = entry.metadata?.[field];
+  if (value === undefined || value === null) return 0;
+  if (typeof value !== "number" || !Number.isFinite(value)) {
+    throw new Error(
+      `Boost field '${field}' on entry '${entry.id}' is not numeric.`,
+    );
+  }
+  return weight * value;
+}
+
+/**
+ * Rank adjustment from tag membership: plus {@link TAG_RANK_ADJUSTMENT}
+ * for each boost tag in the entry's `tags` metadata and minus it for each
+ * penalty tag. Entries without a `tags` array are not adjusted.
+ */
+function tagAdjustment(
+  entry: VecFSEntry,
+  boostTags: string[] = [],
+  penaltyTags: string[] = [],
+): number {
+  const tags = entry.metadata?.tags;
+  if (!Array.isArray(tags)) return 0;
+  const matches = (wanted: string[]) =>
+    wanted.filter((tag) => tags.includes(tag)).length;
+  return TAG_RANK_ADJUSTMENT * (matches(boostTags) - matches(penaltyTags));
+}
+
+/** Whether an entry's timestamp lies in the `[after, before)` window. */
+function inTimeRange(
+  entry: VecFSEntry,
+  after: number | undefined,
+  before: number | undefined,
+): boolean {
+  return (
+    (after === undefined || entry.timestamp >= after) &&
+    (before === undefined || entry.timestamp < before)
+  );
+}
+
+/**
+ * Whether an entry satisfies every metadata equality constraint.
+ * Array-valued metadata such as tags matches when it contains the value.
+ */
+export function matchesFilter(
+  entry: VecFSEntry,
+  filter: MetadataFilter,
+): boolean {
+  return Object.entries(filter).every(([key, expected]) => {
+    const value = entry.metadata?.[key];
+    return Array.isArray(value) ? value.includes(expected) : value === expected;
+  });
+}
+
+/**
+ * Chooses which entries a search scores: every entry that has not expired
+ * and passes `options.filter` and the `options.after`/`options.before`
+ * window, or with a `cap`, such entries with the most dimensions in common
+ * with the query, found through `index`.
+ */
+export function searchCandidates(
+  entries: VecFSEntry[],
+  queryVector: SparseVector,
+  options: SearchOptions,
+  now: number,
+  cap: number | undefined,
+  index: () => InvertedIndex,
+): VecFSEntry[] {
+  const { filter, after, before } = options;
+  const accept = (e: VecFSEntry) =>
+    !isExpired(e, now) &&
+    (!filter || matchesFilter(e, filter)) &&
+    inTimeRange(e, after, before);
+  if (cap === undefined) return entries.filter(accept);
+  return overlapCandidates(index(), queryVector, cap, accept);
+}
+
+/**
+ * Scores entries against the query vector, in no particular order.
+ * Ranking combines similarity with the reinforcement score so that
+ * positively reinforced context is prioritized (per requirements).
+ * Pre-computes the query norm once to avoid redundant calculations.
+ *
+ * When `options.boostField` is set, `boostWeight * metadata[boostField]`
+ * is added to each entry's combined rank, and `boostTags`/`penaltyTags`
+ * move it up or down for each listed tag. With `options.dropZero`, entries
+ * with zero similarity are left out even if feedback or a boost would have
+ * ranked them. `options.metric` names the registered similarity function
+ * to compare with, and an unknown name is an error.
+ */
+export function scoreEntries(
+  entries: VecFSEntry[],
+  queryVector: SparseVector,
+  options: SearchOptions,
+  settings: RankingSettings,
+): RankedResult[] {
+  const { boostField, boostWeight = DEFAULT_BOOST_WEIGHT } = options;
+  const { boostTags, penaltyTags } = options;
+  const similarity = similarityFunction(
+    options.metric ?? settings.metric ?? "cosine",
+  );
+  const queryNorm = norm(queryVector);
+  const halfLife = settings.scoreHalfLifeMs;
+
+  const ranked = entries.map((entry): RankedResult => {
+    const result: SearchResult = {
+      ...entry,
+      similarity: similarity(queryVector, entry.vector, queryNorm),
+    };
+    const score =
+      halfLife === undefined
+        ? entry.score
+        : decayedScore(entry.score, settings.now - entry.timestamp, halfLife);
+    let rank = combinedRank(
+      { similarity: result.similarity, score },
+      settings.feedbackWeight ?? DEFAULT_FEEDBACK_WEIGHT,
+    );
+    if (boostField) rank += metadataBoost(entry, boostField, boostWeight);
+    if (boostTags || penaltyTags) {
+      rank += tagAdjustment(entry, boostTags, penaltyTags);
+    }
+    return { result, rank };
+  });
+
+  return options.dropZero
+    ? ranked.filter((r) => r.result.similarity !== 0)
+    : ranked;
+}
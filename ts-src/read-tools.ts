@@ -0,0 +1,136 @@
+import { z } from "zod";
+import { renderWithinBytes } from "./response-size.js";
+import { ToolCallContext } from "./tool-timeout.js";
+import { toDense } from "./sparse-vector.js";
+import { RpcError, INVALID_PARAMS_CODE } from "./rpc-errors.js";
+import {
+  ToolContext,
+  ToolHandlerMap,
+  ToolResult,
+  enterStage,
+} from "./tool-context.js";
+import { namespaceSchema, validateArgs } from "./tool-args.js";
+
+const getArgsSchema = z.object({
+  id: z.string(),
+  namespace: namespaceSchema.optional(),
+  dims: z.number().int().positive().optional(),
+});
+
+const versionsArgsSchema = z.object({
+  id: z.string(),
+  namespace: namespaceSchema.optional(),
+});
+
+const listArgsSchema = z.object({
+  offset: z.number().int().optional(),
+  limit: z.number().int().optional(),
+  namespace: namespaceSchema.optional(),
+});
+
+/** Arguments of the tools that only take a namespace. */
+const storeArgsSchema = z.object({
+  namespace: namespaceSchema.optional(),
+});
+
+/**
+ * Builds the tools that read entries without a query: `count`,
+ * `metadata_keys`, `get`, `versions` and `list`.
+ */
+export function readTools(context: ToolContext): ToolHandlerMap {
+  const { options, storageFor } = context;
+  return {
+    async count(args: unknown, ctx?: ToolCallContext): Promise<ToolResult> {
+      const { namespace } = validateArgs(storeArgsSchema, args, "count");
+      enterStage(ctx, "storage");
+      const store = await storageFor(namespace);
+      const count = await store.count();
+      return {
+        content: [{ type: "text", text: JSON.stringify({ count }) }],
+      };
+    },
+
+    async metadata_keys(
+      args: unknown,
+      ctx?: ToolCallContext,
+    ): Promise<ToolResult> {
+      const { namespace } = validateArgs(
+        storeArgsSchema,
+        args,
+        "metadata_keys",
+      );
+      enterStage(ctx, "storage");
+      const store = await storageFor(namespace);
+      const keys = await store.metadataKeys();
+      return {
+        content: [{ type: "text", text: JSON.stringify(keys, null, 2) }],
+      };
+    },
+
+    async get(args: unknown, ctx?: ToolCallContext): Promise<ToolResult> {
+      const { id, namespace, dims } = validateArgs(getArgsSchema, args, "get");
+      enterStage(ctx, "storage");
+      const store = await storageFor(namespace);
+      const entry = await store.get(id);
+      if (!entry) {
+        return {
+          content: [{ type: "text", text: `Entry not found: ${id}` }],
+        };
+      }
+      enterStage(ctx, "render");
+      let exported: object = entry;
+      if (dims !== undefined) {
+        try {
+          exported = { ...entry, vector: toDense(entry.vector, dims) };
+        } catch (error) {
+          throw new RpcError(INVALID_PARAMS_CODE, (error as Error).message);
+        }
+      }
+      return {
+        content: [{ type: "text", text: JSON.stringify(exported, null, 2) }],
+      };
+    },
+
+    async versions(args: unknown, ctx?: ToolCallContext): Promise<ToolResult> {
+      const { id, namespace } = validateArgs(
+        versionsArgsSchema,
+        args,
+        "versions",
+      );
+      enterStage(ctx, "storage");
+      const store = await storageFor(namespace);
+      const versions = await store.versions(id);
+      if (versions.length === 0) {
+        return {
+          content: [{ type: "text", text: `Entry not found: ${id}` }],
+        };
+      }
+      return {
+        content: [{ type: "text", text: JSON.stringify(versions, null, 2) }],
+      };
+    },
+
+    async list(args: unknown, ctx?: ToolCallContext): Promise<ToolResult> {
+      const {
+        offset = 0,
+        limit,
+        namespace,
+      } = validateArgs(listArgsSchema, args, "list");
+      enterStage(ctx, "storage");
+      const store = await storageFor(namespace);
+      const { entries, total } = await store.list(offset, limit);
+      enterStage(ctx, "render");
+      const text = renderWithinBytes(
+        entries,
+        options.maxResponseBytes,
+        (items, truncated) =>
+          JSON.stringify(
+            { entries: items, total, offset, ...(truncated && { truncated }) },
+            null,
+            2,
+          ),
+      );
+      return { content: [{ type: "text", text }] };
+    },
+  };
+}
@@ -0,0 +1,49 @@
+import { describe, it, expect, beforeAll, afterAll } from "vitest";
+import * as fs from "fs/promises";
+import * as os from "os";
+import * as path from "path";
+import { embedWithCommand } from "./reindex-cli.js";
+
+describe("embedWithCommand", () => {
+  let dir: string;
+  let fakeEmbed: string[];
+
+  beforeAll(async () => {
+    dir = await fs.mkdtemp(path.join(os.tmpdir(), "vecfs-reindex-"));
+    // Prints vecfs-embed --batch output with one dimension per line length.
+    const script = path.join(dir, "fake-embed.mjs");
+    await fs.writeFile(
+      script,
+      `let input = "";
+process.stdin.on("data", (chunk) => (input += chunk));
+process.stdin.on("end", () => {
+  const lines = input.split("\\n").filter((l) => l.trim());
+  const results = lines.map((l) => ({ vector: { [l.length]: 1 } }));
+  process.stdout.write(JSON.stringify(results));
+});
+`,
+    );
+    fakeEmbed = [process.execPath, script];
+  });
+
+  afterAll(async () => {
+    await fs.rm(dir, { recursive: true, force: true });
+  });
+
+  it("should return one vector per text in order", async () => {
+    const vectors = await embedWithCommand(["ab", "abcd", "a"], fakeEmbed);
+    expect(vectors).toEqual([{ 2: 1 }, { 4: 1 }, { 1: 1 }]);
+  });
+
+  it("should send a multi-line text as one line", async () => {
+    const vectors = await embedWithCommand(["one\ntwo", "x"], fakeEmbed);
+    expect(vectors).toEqual([{ 7: 1 }, { 1: 1 }]);
+  });
+
+  it("should fail when the command exits with an error", async () => {
+    const failing = [process.execPath, "-e", "process.exit(3)"];
+    await expect(embedWithCommand(["a"], failing)).rejects.toThrow(
+      "exited with code 3",
+    );
+  });
+});
@@ -0,0 +1,59 @@
+/**
+ * Helpers for the `vecfs reindex` subcommand, which re-embeds the text
+ * stored with each entry after a change of embedding model. `vecfs import`
+ * embeds its rows the same way.
+ */
+
+import { spawn } from "child_process";
+import { SparseVector } from "./types.js";
+import { vectorFrom } from "./search-cli.js";
+
+/**
+ * The command run to embed texts. vecfs-embed reads its model and other
+ * settings from the usual `VECFS_EMBED_*` environment variables.
+ */
+export const EMBED_COMMAND = ["vecfs-embed", "--batch", "--mode", "document"];
+
+/**
+ * Embeds texts by piping them through `vecfs-embed --batch`, which reads
+ * one text per line, so line breaks within a text are sent as spaces. The
+ * command's stderr is passed through so its retries and errors are seen.
+ *
+ * @param texts - Texts to embed; none may be blank.
+ * @param command - Program and arguments to run instead of vecfs-embed.
+ * @returns One vector per text, in the same order.
+ * @throws Error if the command cannot be run, exits with an error, or
+ *   does not print a JSON array of results.
+ */
+export function embedWithCommand(
+  texts: string[],
+  command: string[] = EMBED_COMMAND,
+): Promise<SparseVector[]> {
+  const [program, ...args] = command;
+  return new Promise((resolve, reject) => {
+    const child = spawn(program, args, {
+      stdio: ["pipe", "pipe", "inherit"],
+    });
+    const chunks: Buffer[] = [];
+    child.stdout.on("data", (chunk: Buffer) => chunks.push(chunk));
+    child.stdin.on("error", reject);
+    child.on("error", reject);
+    child.on("close", (code) => {
+      if (code !== 0) {
+        reject(new Error(`${program} exited with code ${code}.`));
+        return;
+      }
+      try {
+        const output = JSON.parse(Buffer.concat(chunks).toString("utf-8"));
+        if (!Array.isArray(output)) {
+          throw new Error(`${program} did not print a JSON array.`);
+        }
+        resolve(output.map(vectorFrom));
+      } catch (error) {
+        reject(error);
+      }
+    });
+    const lines = texts.map((t) => t.replace(/\s+/g, " ").trim());
+    child.stdin.end(lines.join("\n") + "\n");
+  });
+}
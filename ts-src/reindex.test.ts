@@ -0,0 +1,94 @@
+import { describe, it, expect, beforeEach, afterEach } from "vitest";
+import { VecFSStorage } from "./storage.js";
+import { SparseVector } from "./types.js";
+import * as fs from "fs/promises";
+
+describe("reindex", () => {
+  const testFilePath = "./test-reindex.jsonl";
+
+  beforeEach(async () => {
+    try {
+      await fs.unlink(testFilePath);
+    } catch {}
+  });
+
+  afterEach(async () => {
+    try {
+      await fs.unlink(testFilePath);
+    } catch {}
+  });
+
+  /** Stands in for a new model: one dimension per word length. */
+  const byWordLength = async (texts: string[]) =>
+    texts.map((text) => {
+      const vector: SparseVector = {};
+      for (const word of text.split(" ")) {
+        vector[word.length] = (vector[word.length] || 0) + 1;
+      }
+      return vector;
+    });
+
+  it("should recompute vectors and keep everything else", async () => {
+    const storage = new VecFSStorage(testFilePath, { keepVersions: 2 });
+    await storage.ensureFile();
+    await storage.store({
+      id: "a",
+      vector: { 90: 1 },
+      metadata: { text: "sparse vectors", tags: ["x"] },
+      score: 2,
+    });
+    await storage.store({ id: "b", vector: { 91: 1 }, score: 0 });
+    const before = await storage.get("a");
+
+    const counts = await storage.reindex(byWordLength);
+
+    expect(counts).toEqual({ reindexed: 1, skipped: 1 });
+    const reloaded = new VecFSStorage(testFilePath, { keepVersions: 2 });
+    const a = await reloaded.get("a");
+    expect(a?.vector).toEqual({ 6: 1, 7: 1 });
+    expect(a?.metadata).toEqual(before?.metadata);
+    expect(a?.score).toBe(2);
+    expect(a?.timestamp).toBe(before?.timestamp);
+    expect((await reloaded.get("b"))?.vector).toEqual({ 91: 1 });
+    expect(await reloaded.versions("a")).toHaveLength(1);
+  });
+
+  it("should embed in batches of the given size", async () => {
+    const storage = new VecFSStorage(testFilePath);
+    await storage.ensureFile();
+    for (const id of ["a", "b", "c", "d", "e"]) {
+      await storage.store({
+        id,
+        vector: { 90: 1 },
+        metadata: { text: `entry ${id}` },
+        score: 0,
+      });
+    }
+    const batches: number[] = [];
+
+    const counts = await storage.reindex(async (texts) => {
+      batches.push(texts.length);
+      return byWordLength(texts);
+    }, 2);
+
+    expect(batches).toEqual([2, 2, 1]);
+    expect(counts).toEqual({ reindexed: 5, skipped: 0 });
+    expect((await storage.get("e"))?.vector).toEqual({ 1: 1, 5: 1 });
+  });
+
+  it("should change nothing when the embedder miscounts", async () => {
+    const storage = new VecFSStorage(testFilePath);
+    await storage.ensureFile();
+    await storage.store({
+      id: "a",
+      vector: { 90: 1 },
+      metadata: { text: "hello" },
+      score: 0,
+    });
+
+    await expect(storage.reindex(async () => [])).rejects.toThrow(
+      "0 vectors for 1 texts",
+    );
+    expect((await storage.get("a"))?.vector).toEqual({ 90: 1 });
+  });
+});
@@ -0,0 +1,59 @@
+import { VecFSEntry, SparseVector, Embedder } from "./types.js";
+import { isExpired } from "./retention.js";
+
+/** Texts sent to the embedder at once by `reindex`. */
+export const REINDEX_BATCH_SIZE = 100;
+
+/** New vectors for the entries a reindex recomputes. */
+export interface Reembedding {
+  /** Index in the entry list of each recomputed entry. */
+  positions: number[];
+  /** The new vector of each entry in `positions`, in the same order. */
+  vectors: SparseVector[];
+  /** Live entries left alone because they have no stored text. */
+  skipped: number;
+}
+
+/**
+ * Embeds the text kept in the `text` metadata of every live entry, a
+ * batch of at most `batchSize` texts at a time, so a large store is never
+ * sent to the embedder in one call. Expired entries are left out. Nothing
+ * is changed; the caller applies the vectors once all have been embedded.
+ *
+ * @throws Error if `embed` fails or returns a different number of
+ *   vectors than texts.
+ */
+export async function reembed(
+  entries: VecFSEntry[],
+  now: number,
+  embed: Embedder,
+  batchSize: number,
+): Promise<Reembedding> {
+  const positions: number[] = [];
+  const texts: string[] = [];
+  let skipped = 0;
+  entries.forEach((entry, i) => {
+    if (isExpired(entry, now)) return;
+    const text = entry.metadata?.text;
+    if (typeof text === "string" && text.trim() !== "") {
+      positions.push(i);
+      texts.push(text);
+    } else {
+      skipped++;
+    }
+  });
+
+  const vectors: SparseVector[] = [];
+  for (let start = 0; start < texts.length; start += batchSize) {
+    const batch = texts.slice(start, start + batchSize);
+    const batchVectors = await embed(batch);
+    if (batchVectors.length !== batch.length) {
+      throw new Error(
+        `Embedder returned ${batchVectors.length} vectors for ` +
+          `${batch.length} texts.`,
+      );
+    }
+    vectors.push(...batchVectors);
+  }
+  return { positions, vectors, skipped };
+}
@@ -0,0 +1,78 @@
+import { describe, it, expect, beforeEach, afterEach } from "vitest";
+import { VecFSStorage } from "./storage.js";
+import { createResourceHandlers, entryUri } from "./resources.js";
+import { RpcError, RESOURCE_NOT_FOUND_CODE } from "./rpc-errors.js";
+import * as fs from "fs/promises";
+
+describe("resource handlers", () => {
+  const testFilePath = "./test-resources.jsonl";
+  let storage: VecFSStorage;
+  let resources: ReturnType<typeof createResourceHandlers>;
+
+  beforeEach(async () => {
+    await fs.unlink(testFilePath).catch(() => {});
+    let now = 0;
+    storage = new VecFSStorage(testFilePath, { clock: () => ++now });
+    resources = createResourceHandlers(storage);
+  });
+
+  afterEach(async () => {
+    await fs.unlink(testFilePath).catch(() => {});
+  });
+
+  async function storeText(id: string, text?: string) {
+    await storage.store({ id, vector: { 0: 1 }, metadata: { text }, score: 0 });
+  }
+
+  it("should list stored entries as resource URIs, newest first", async () => {
+    await storeText("a", "first");
+    await storeText("notes/b c", "second");
+
+    const { resources: listed, nextCursor } = await resources.list();
+    expect(listed).toEqual([
+      {
+        uri: "vecfs://entry/notes%2Fb%20c",
+        name: "notes/b c",
+        mimeType: "text/plain",
+      },
+      { uri: "vecfs://entry/a", name: "a", mimeType: "text/plain" },
+    ]);
+    expect(nextCursor).toBeUndefined();
+  });
+
+  it("should page through entries with a cursor", async () => {
+    for (let i = 0; i < 150; i++) await storeText(`e${i}`);
+
+    const first = await resources.list();
+    expect(first.resources).toHaveLength(100);
+    const second = await resources.list(first.nextCursor);
+    expect(second.resources).toHaveLength(50);
+    expect(second.nextCursor).toBeUndefined();
+    const names = [...first.resources, ...second.resources].map((r) => r.name);
+    expect(new Set(names).size).toBe(150);
+  });
+
+  it("should reject a malformed cursor", async () => {
+    await expect(resources.list("next")).rejects.toThrow("Invalid cursor");
+  });
+
+  it("should read an entry's text", async () => {
+    await storeText("notes/b c", "remember this");
+
+    const { contents } = await resources.read(entryUri("notes/b c"));
+    expect(contents).toEqual([
+      {
+        uri: "vecfs://entry/notes%2Fb%20c",
+        mimeType: "text/plain",
+        text: "remember this",
+      },
+    ]);
+  });
+
+  it("should report an unknown entry as resource not found", async () => {
+    const error = await resources.read(entryUri("missing")).catch((e) => e);
+    expect(error).toBeInstanceOf(RpcError);
+    expect(error.code).toBe(RESOURCE_NOT_FOUND_CODE);
+    expect(error.message).toBe("Resource not found: vecfs://entry/missing");
+  });
+});
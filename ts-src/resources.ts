@@ -0,0 +1,111 @@
+/**
+ * MCP resources backed by stored entries.
+ *
+ * Clients that browse resources see each memory as `vecfs://entry/{id}`,
+ * listed newest first in pages, and reading one returns its stored text.
+ */
+
+import { VecFSStorage, MAX_LIST_LIMIT } from "./storage.js";
+import {
+  RpcError,
+  INVALID_PARAMS_CODE,
+  RESOURCE_NOT_FOUND_CODE,
+} from "./rpc-errors.js";
+
+const ENTRY_URI_PREFIX = "vecfs://entry/";
+
+/** One listed resource, in the shape of an MCP `Resource`. */
+export interface EntryResource {
+  uri: string;
+  name: string;
+  mimeType: string;
+}
+
+/** The result of `resources/list`. */
+export interface ResourceList {
+  resources: EntryResource[];
+  /** Pass back as `cursor` for the next page; absent on the last page. */
+  nextCursor?: string;
+}
+
+/** The result of `resources/read`. */
+export interface ResourceContents {
+  contents: { uri: string; mimeType: string; text: string }[];
+}
+
+/** The resource URI for an entry. IDs are percent-encoded. */
+export function entryUri(id: string): string {
+  return ENTRY_URI_PREFIX + encodeURIComponent(id);
+}
+
+/** The entry ID in a resource URI, or undefined for other URIs. */
+function entryId(uri: string): string | undefined {
+  if (!uri.startsWith(ENTRY_URI_PREFIX)) return undefined;
+  try {
+    return decodeURIComponent(uri.slice(ENTRY_URI_PREFIX.length));
+  } catch {
+    return undefined;
+  }
+}
+
+/**
+ * Reads a list cursor. Cursors are opaque to clients but are simply the
+ * offset of the next page.
+ */
+function parseCursor(cursor: string | undefined): number {
+  if (cursor === undefined) return 0;
+  const offset = Number(cursor);
+  if (!Number.isInteger(offset) || offset < 0) {
+    throw new RpcError(INVALID_PARAMS_CODE, `Invalid cursor: ${cursor}`);
+  }
+  return offset;
+}
+
+/**
+ * Creates the `resources/list` and `resources/read` handlers for a store.
+ */
+export function createResourceHandlers(storage: VecFSStorage) {
+  return {
+    /** Lists one page of entries, starting at the page `cursor` names. */
+    async list(cursor?: string): Promise<ResourceList> {
+      const offset = parseCursor(cursor);
+      const { entries, total } = await storage.list(offset, MAX_LIST_LIMIT);
+      const next = offset + entries.length;
+      return {
+        resources: entries.map((entry) => ({
+          uri: entryUri(entry.id),
+          name: entry.id,
+          mimeType: "text/plain",
+        })),
+        ...(next < total && { nextCursor: String(next) }),
+      };
+    },
+
+    /**
+     * Returns an entry's stored text, or an empty string if it has none.
+     *
+     * @throws RpcError with {@link RESOURCE_NOT_FOUND_CODE} if the URI does
+     *   not name a stored entry.
+     */
+    async read(uri: string): Promise<ResourceContents> {
+      const id = entryId(uri);
+      const entry = id === undefined ? undefined : await storage.get(id);
+      if (!entry) {
+        throw new RpcError(
+          RESOURCE_NOT_FOUND_CODE,
+          `Resource not found: ${uri}`,
+        );
+      }
+      const text = entry.metadata?.text;
+      return {
+        contents: [
+          {
+            uri,
+            mimeType: "text/plain",
+            text: typeof text === "string" ? text : "",
+          },
+        ],
+      };
+    },
+  };
+}
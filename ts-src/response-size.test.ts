@@ -0,0 +1,57 @@
+import { describe, it, expect } from "vitest";
+import { renderWithinBytes, paginateByBytes } from "./response-size.js";
+
+describe("renderWithinBytes", () => {
+  const render = (items: string[], truncated: boolean) =>
+    JSON.stringify({ items, truncated });
+
+  it("should render everything when there is no cap", () => {
+    const text = renderWithinBytes(["a", "b"], undefined, render);
+    expect(JSON.parse(text)).toEqual({ items: ["a", "b"], truncated: false });
+  });
+
+  it("should keep the longest prefix that fits", () => {
+    const items = ["aaaa", "bbbb", "cccc", "dddd"];
+    const budget = Buffer.byteLength(render(items.slice(0, 2), true));
+    const text = renderWithinBytes(items, budget, render);
+    expect(JSON.parse(text)).toEqual({
+      items: ["aaaa", "bbbb"],
+      truncated: true,
+    });
+  });
+
+  it("should fall back to an empty list when nothing fits", () => {
+    const text = renderWithinBytes(["aaaa"], 1, render);
+    expect(JSON.parse(text)).toEqual({ items: [], truncated: true });
+  });
+
+  it("should measure multi-byte characters in bytes", () => {
+    const items = ["ééé", "ééé"];
+    const budget = Buffer.byteLength(render(items, false)) - 1;
+    const text = renderWithinBytes(items, budget, render);
+    expect(JSON.parse(text).truncated).toBe(true);
+  });
+
+describe("paginateByBytes", () => {
+  const render = (items: string[]) => JSON.stringify(items);
+
+  it("should fill each page up to the budget", () => {
+    const items = ["aaaa", "bbbb", "cccc", "dddd", "eeee"];
+    const budget = Buffer.byteLength(render(items.slice(0, 2)));
+    expect(paginateByBytes(items, budget, render)).toEqual([
+      ["aaaa", "bbbb"],
+      ["cccc", "dddd"],
+      ["eeee"],
+    ]);
+  });
+
+  it("should give an oversized result a page of its own", () => {
+    const pages = paginateByBytes(["a", "x".repeat(50), "b"], 10, render);
+    expect(pages).toEqual([["a"], ["x".repeat(50)], ["b"]]);
+  });
+
+  it("should return one empty page for no results", () => {
+    expect(paginateByBytes([], 10, render)).toEqual([[]]);
+  });
+});
+});
@@ -0,0 +1,85 @@
+/**
+ * Renders a list of results as response text, either complete or as a
+ * truncated prefix that should be flagged as such.
+ */
+export type ResultRenderer<T> = (items: T[], truncated: boolean) => string;
+
+/**
+ * Renders as many results as fit within a byte budget.
+ *
+ * MCP clients read tool responses into fixed buffers, so an oversized
+ * response can be cut off mid-JSON. When the full rendering exceeds
+ * `maxBytes`, the longest prefix of `results` whose rendering fits is used
+ * instead and the renderer is told the output is truncated. At least an
+ * empty result list is always returned, even if it exceeds the budget.
+ *
+ * @param results - The ordered results to render.
+ * @param maxBytes - Maximum UTF-8 size of the response; undefined means no
+ *   cap.
+ * @param render - Produces the response text for a slice of results.
+ * @returns The response text.
+ */
+export function renderWithinBytes<T>(
+  results: T[],
+  maxBytes: number | undefined,
+  render: ResultRenderer<T>,
+): string {
+  const { items, truncated } = prefixWithinBytes(results, maxBytes, render);
+  return render(items, truncated);
+}
+
+/**
+ * The results {@link renderWithinBytes} would render, and whether they
+ * are a truncated prefix, for callers that post-process the kept results.
+ */
+export function prefixWithinBytes<T>(
+  results: T[],
+  maxBytes: number | undefined,
+  render: ResultRenderer<T>,
+): { items: T[]; truncated: boolean } {
+  const full = render(results, false);
+  if (maxBytes === undefined || Buffer.byteLength(full) <= maxBytes) {
+    return { items: results, truncated: false };
+  }
+
+  // Binary search for the largest prefix whose truncated rendering fits.
+  let lo = 0;
+  let hi = results.length - 1;
+  while (lo < hi) {
+    const mid = Math.ceil((lo + hi) / 2);
+    const text = render(results.slice(0, mid), true);
+    if (Buffer.byteLength(text) <= maxBytes) lo = mid;
+    else hi = mid - 1;
+  }
+  return { items: results.slice(0, lo), truncated: true };
+}
+
+/**
+ * Splits results into consecutive pages whose renderings each fit within
+ * `pageBytes`, filling every page before starting the next. A result too
+ * large to fit on its own still gets a page of its own, so every result
+ * is kept and order is preserved.
+ *
+ * @param results - The ordered results to split.
+ * @param pageBytes - Maximum UTF-8 size of one rendered page.
+ * @param render - Produces the text for one page of results.
+ * @returns The pages, at least one even for no results.
+ */
+export function paginateByBytes<T>(
+  results: T[],
+  pageBytes: number,
+  render: (items: T[]) => string,
+): T[][] {
+  const pages: T[][] = [];
+  let page: T[] = [];
+  for (const item of results) {
+    page.push(item);
+    if (page.length > 1 && Buffer.byteLength(render(page)) > pageBytes) {
+      page.pop();
+      pages.push(page);
+      page = [item];
+    }
+  }
+  if (page.length > 0 || pages.length === 0) pages.push(page);
+  return pages;
+}
@@ -0,0 +1,346 @@
+import { describe, it, expect, beforeEach, afterEach } from "vitest";
+import { VecFSStorage } from "./storage.js";
+import { StorageOptions } from "./storage-options.js";
+import { fixedClock } from "./fixed-clock.js";
+import * as fs from "fs/promises";
+
+describe("retention", () => {
+  const testFilePath = "./test-retention.jsonl";
+
+  beforeEach(async () => {
+    try {
+      await fs.unlink(testFilePath);
+    } catch {}
+  });
+
+  afterEach(async () => {
+    try {
+      await fs.unlink(testFilePath);
+    } catch {}
+  });
+
+  describe("maxEntries", () => {
+    async function fill(options: StorageOptions) {
+      const log = [
+        { id: "old-high", score: 5, timestamp: 1 },
+        { id: "mid-low", score: -2, timestamp: 2 },
+        { id: "new-mid", score: 0, timestamp: 3 },
+      ].map((e) => JSON.stringify({ ...e, vector: { 0: 1 }, metadata: {} }));
+      await fs.writeFile(testFilePath, log.join("\n") + "\n");
+      return new VecFSStorage(testFilePath, { maxEntries: 3, ...options });
+    }
+
+    async function idsAfterReload() {
+      const reloaded = new VecFSStorage(testFilePath);
+      const page = await reloaded.list();
+      return page.entries.map((e) => e.id).sort();
+    }
+
+    const extra = { id: "extra", vector: { 1: 1 }, metadata: {}, score: 0 };
+
+    it("should reject new entries at the cap by default", async () => {
+      const storage = await fill({});
+
+      await expect(storage.store(extra)).rejects.toThrow("Storage is full");
+      expect(await idsAfterReload()).toEqual([
+        "mid-low",
+        "new-mid",
+        "old-high",
+      ]);
+    });
+
+    it("should still allow updates at the cap", async () => {
+      const storage = await fill({});
+
+      expect(await storage.store({ ...extra, id: "new-mid" })).toBe(false);
+    });
+
+    it("should evict the oldest entry under lru", async () => {
+      const storage = await fill({ eviction: "lru" });
+
+      await storage.store(extra);
+      expect(await idsAfterReload()).toEqual(["extra", "mid-low", "new-mid"]);
+    });
+
+    it("should evict the lowest score under lowest_score", async () => {
+      const storage = await fill({ eviction: "lowest_score" });
+
+      await storage.store(extra);
+      expect(await idsAfterReload()).toEqual(["extra", "new-mid", "old-high"]);
+    });
+
+    it("should evict with a tombstone in append-only mode", async () => {
+      const storage = await fill({ eviction: "lru", appendOnly: true });
+
+      await storage.store(extra);
+      const lines = (await fs.readFile(testFilePath, "utf-8")).trim();
+      expect(lines.split("\n")).toHaveLength(5);
+      expect(await idsAfterReload()).toEqual(["extra", "mid-low", "new-mid"]);
+    });
+  });
+
+  describe("eviction by use", () => {
+    async function fill(options: StorageOptions, scores = [0, 0, 0]) {
+      const log = ["a", "b", "c"].map((id, i) =>
+        JSON.stringify({
+          id,
+          vector: { [i]: 1 },
+          metadata: {},
+          score: scores[i],
+          timestamp: i + 1,
+        }),
+      );
+      await fs.writeFile(testFilePath, log.join("\n") + "\n");
+      return new VecFSStorage(testFilePath, { maxEntries: 3, ...options });
+    }
+
+    async function remainingIds(storage: VecFSStorage) {
+      return (await storage.list()).entries.map((e) => e.id).sort();
+    }
+
+    const extra = { id: "d", vector: { 9: 1 }, metadata: {}, score: 0 };
+
+    it("should evict the least recently searched entry under lru", async () => {
+      const storage = await fill({ eviction: "lru" });
+      // "a" is the oldest entry, but searching for it makes it recent.
+      await storage.search({ 0: 1 }, 1);
+
+      await storage.store(extra);
+      expect(await remainingIds(storage)).toEqual(["a", "c", "d"]);
+    });
+
+    it("should count facet-path hits as accesses", async () => {
+      const storage = await fill({ eviction: "lru" });
+      const ranked = await storage.rank({ 0: 1 });
+      storage.recordAccess(ranked.slice(0, 1));
+
+      await storage.store(extra);
+      expect(await remainingIds(storage)).toEqual(["a", "c", "d"]);
+    });
+
+    it("should evict the minimum score under lowest_score", async () => {
+      const storage = await fill({ eviction: "lowest_score" }, [1, 2, -1]);
+
+      await storage.store(extra);
+      expect(await remainingIds(storage)).toEqual(["a", "b", "d"]);
+    });
+
+    it("should break score ties by least recent use", async () => {
+      const storage = await fill({ eviction: "lowest_score" });
+      await storage.search({ 0: 1 }, 1);
+
+      await storage.store(extra);
+      expect(await remainingIds(storage)).toEqual(["a", "c", "d"]);
+    });
+  });
+
+  describe("expiry", () => {
+    async function storeWithTtl(storage: VecFSStorage, ttlMs: number) {
+      await storage.store({
+        id: "scratch",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+        expiresAt: storage.now() + ttlMs,
+      });
+      await storage.store({
+        id: "lasting",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+    }
+
+    it("should hide an entry once its expiry time has passed", async () => {
+      const clock = fixedClock(1_000_000);
+      const storage = new VecFSStorage(testFilePath, { clock });
+      await storeWithTtl(storage, 1000);
+
+      const before = await storage.search({ 0: 1 }, 10);
+      expect(before.map((r) => r.id).sort()).toEqual(["lasting", "scratch"]);
+
+      clock.ms += 1000;
+
+      const after = await storage.search({ 0: 1 }, 10);
+      expect(after.map((r) => r.id)).toEqual(["lasting"]);
+      const { entries, total } = await storage.list();
+      expect(entries.map((e) => e.id)).toEqual(["lasting"]);
+      expect(total).toBe(1);
+      expect(await storage.get("scratch")).toBeUndefined();
+      expect(await storage.count()).toBe(1);
+    });
+
+    it("should not find an expired entry to change", async () => {
+      const clock = fixedClock(1_000_000);
+      const storage = new VecFSStorage(testFilePath, { clock });
+      await storeWithTtl(storage, 1000);
+      clock.ms += 1000;
+
+      expect(await storage.updateScore("scratch", 1)).toBe(false);
+      expect(await storage.updateMetadata("scratch", { a: 1 })).toBe(false);
+      expect(await storage.rename("scratch", "kept")).toBe(false);
+      expect(await storage.delete("scratch")).toBe(false);
+      expect(await storage.get("kept")).toBeUndefined();
+    });
+
+    it("should rename onto the ID of an expired entry", async () => {
+      const clock = fixedClock(1_000_000);
+      const storage = new VecFSStorage(testFilePath, { clock });
+      await storeWithTtl(storage, 1000);
+      clock.ms += 1000;
+
+      expect(await storage.rename("lasting", "scratch")).toBe(true);
+      expect((await storage.get("scratch"))?.expiresAt).toBeUndefined();
+
+      const reloaded = new VecFSStorage(testFilePath, { clock });
+      const { entries } = await reloaded.list();
+      expect(entries.map((e) => e.id)).toEqual(["scratch"]);
+    });
+
+    it("should skip expired entries under a candidate cap", async () => {
+      const clock = fixedClock(1_000_000);
+      const storage = new VecFSStorage(testFilePath, {
+        clock,
+        candidateCap: 5,
+      });
+      await storeWithTtl(storage, 1000);
+      clock.ms += 5000;
+
+      const results = await storage.search({ 0: 1 }, 10);
+      expect(results.map((r) => r.id)).toEqual(["lasting"]);
+    });
+
+    it("should remove expired entries from the file on sweep", async () => {
+      const clock = fixedClock(1_000_000);
+      const storage = new VecFSStorage(testFilePath, { clock });
+      await storeWithTtl(storage, 1000);
+
+      expect(await storage.sweep()).toBe(0);
+      clock.ms += 1000;
+      expect(await storage.sweep()).toBe(1);
+
+      const content = await fs.readFile(testFilePath, "utf-8");
+      expect(content).not.toContain('"scratch"');
+      expect(content).toContain('"lasting"');
+    });
+
+    it("should sweep with a tombstone in append-only mode", async () => {
+      const clock = fixedClock(1_000_000);
+      const storage = new VecFSStorage(testFilePath, {
+        clock,
+        appendOnly: true,
+      });
+      await storeWithTtl(storage, 1000);
+      clock.ms += 1000;
+
+      expect(await storage.sweep()).toBe(1);
+
+      const lines = (await fs.readFile(testFilePath, "utf-8"))
+        .trim()
+        .split("\n");
+      const tombstone = JSON.stringify({ id: "scratch", deleted: true });
+      expect(lines[lines.length - 1]).toBe(tombstone);
+    });
+  });
+
+  describe("keepVersions", () => {
+    async function storeText(storage: VecFSStorage, id: string, text: string) {
+      await storage.store({
+        id,
+        vector: { 0: 1 },
+        metadata: { text },
+        score: 0,
+      });
+    }
+
+    it("should keep earlier versions and search only the latest", async () => {
+      const clock = fixedClock(1000);
+      const storage = new VecFSStorage(testFilePath, {
+        clock,
+        keepVersions: 5,
+      });
+      for (const text of ["first", "second", "third"]) {
+        await storeText(storage, "a", text);
+        clock.ms += 10;
+      }
+
+      const results = await storage.search({ 0: 1 }, 10);
+      expect(results.map((r) => [r.id, r.metadata.text])).toEqual([
+        ["a", "third"],
+      ]);
+      expect(await storage.count()).toBe(1);
+
+      const versions = await new VecFSStorage(testFilePath).versions("a");
+      expect(
+        versions.map((v) => [v.id, v.version, v.metadata.text, v.timestamp]),
+      ).toEqual([
+        ["a", 3, "third", 1020],
+        ["a", 2, "second", 1010],
+        ["a", 1, "first", 1000],
+      ]);
+    });
+
+    it("should reject IDs in the form of a kept version", async () => {
+      const storage = new VecFSStorage(testFilePath, { keepVersions: 2 });
+      await storeText(storage, "a", "v1");
+      await storeText(storage, "a", "v2");
+      const entry = { id: "a@v1", vector: { 0: 1 }, metadata: {}, score: 0 };
+
+      await expect(storage.store(entry)).rejects.toThrow("reserved");
+      await expect(storage.storeBatch([entry])).rejects.toThrow("reserved");
+      await expect(storage.rename("a", "b@v2")).rejects.toThrow("reserved");
+      const versions = await storage.versions("a");
+      expect(versions.map((v) => v.metadata.text)).toEqual(["v2", "v1"]);
+      expect(await storage.get("a@v1")).toBeUndefined();
+    });
+
+    it("should drop the oldest versions beyond the limit", async () => {
+      const storage = new VecFSStorage(testFilePath, { keepVersions: 2 });
+      for (const text of ["v1", "v2", "v3", "v4"]) {
+        await storeText(storage, "a", text);
+      }
+
+      const versions = await storage.versions("a");
+      expect(versions.map((v) => v.metadata.text)).toEqual(["v4", "v3", "v2"]);
+      const lines = (await fs.readFile(testFilePath, "utf-8"))
+        .trim()
+        .split("\n");
+      expect(lines).toHaveLength(3);
+    });
+
+    it("should append versions and prune with tombstones when append-only", async () => {
+      const storage = new VecFSStorage(testFilePath, {
+        keepVersions: 1,
+        appendOnly: true,
+      });
+      for (const text of ["v1", "v2", "v3"]) {
+        await storeText(storage, "a", text);
+      }
+
+      const reloaded = new VecFSStorage(testFilePath, { keepVersions: 1 });
+      const versions = await reloaded.versions("a");
+      expect(versions.map((v) => v.metadata.text)).toEqual(["v3", "v2"]);
+      expect(await reloaded.count()).toBe(1);
+    });
+
+    it("should keep versions replaced by storeBatch", async () => {
+      const storage = new VecFSStorage(testFilePath, { keepVersions: 3 });
+      await storeText(storage, "a", "old");
+      await storage.storeBatch([
+        { id: "a", vector: { 0: 1 }, metadata: { text: "new" }, score: 0 },
+      ]);
+
+      const versions = await storage.versions("a");
+      expect(versions.map((v) => v.metadata.text)).toEqual(["new", "old"]);
+    });
+
+    it("should keep no versions by default", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      await storeText(storage, "a", "old");
+      await storeText(storage, "a", "new");
+
+      expect(await storage.versions("a")).toHaveLength(1);
+      expect(await storage.versions("missing")).toEqual([]);
+    });
+  });
+});
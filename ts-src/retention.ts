@@ -0,0 +1,137 @@
+import { VecFSEntry, Tombstone } from "./types.js";
+
+/**
+ * What {@link VecFSStorage.store} does with a new entry when the store is
+ * at `maxEntries`: refuse it, or make room by evicting the least recently
+ * searched entry or the one with the lowest feedback score.
+ */
+export type EvictionPolicy = "reject" | "lru" | "lowest_score";
+
+/** Whether an entry has an expiry time at or before `now`. */
+export function isExpired(entry: VecFSEntry, now: number): boolean {
+  return entry.expiresAt !== undefined && entry.expiresAt <= now;
+}
+
+/**
+ * Picks the entry to evict under `policy`. `lru` takes the entry used
+ * longest ago, where `lastUsed` gives the time an entry was last returned
+ * by a search or else stored; `lowest_score` takes the lowest feedback
+ * score, and so the smallest ranking boost, least recently used first
+ * among equals. Remaining ties go to the smallest id.
+ */
+function evictionVictim(
+  entries: VecFSEntry[],
+  policy: Exclude<EvictionPolicy, "reject">,
+  lastUsed: (entry: VecFSEntry) => number,
+): number {
+  const worse = (a: VecFSEntry, b: VecFSEntry) =>
+    (policy === "lowest_score" ? a.score - b.score : 0) ||
+    lastUsed(a) - lastUsed(b) ||
+    a.id.localeCompare(b.id);
+  let victim = 0;
+  for (let i = 1; i < entries.length; i++) {
+    if (worse(entries[i], entries[victim]) < 0) victim = i;
+  }
+  return victim;
+}
+
+/**
+ * Enforces `maxEntries` before a new entry is added to `entries`.
+ *
+ * @returns The index of the entry to evict, or undefined if there is room.
+ * @throws Error if the store is full and the policy is `reject`.
+ */
+export function evictionIndex(
+  entries: VecFSEntry[],
+  limits: { maxEntries?: number; eviction?: EvictionPolicy },
+  lastUsed: (entry: VecFSEntry) => number,
+): number | undefined {
+  const { maxEntries, eviction = "reject" } = limits;
+  if (maxEntries === undefined || entries.length < maxEntries) return;
+  if (eviction === "reject" || entries.length === 0) {
+    throw new Error(
+      `Storage is full: it holds the maximum of ${maxEntries} entries. Delete entries or raise VECFS_MAX_ENTRIES.`,
+    );
+  }
+  return evictionVictim(entries, eviction, lastUsed);
+}
+
+/** The form of the IDs that {@link VersionHistory} gives kept versions. */
+const VERSION_ID = /@v\d+$/;
+
+/**
+ * Rejects an entry ID of the form `<id>@v<version>`, which a kept version
+ * could overwrite or be overwritten by.
+ *
+ * @throws Error if `id` ends in `@v` and a number.
+ */
+export function checkEntryId(id: string): void {
+  if (VERSION_ID.test(id)) {
+    throw new Error(
+      `Invalid ID '${id}': IDs ending in @v and a number are reserved ` +
+        "for earlier versions.",
+    );
+  }
+}
+
+/**
+ * Earlier versions of entries, kept under the `keepVersions` option. Each
+ * is stored as a record of its own, with the ID `<id>@v<version>` and
+ * `versionOf` naming the entry, so it is never searched.
+ */
+export class VersionHistory {
+  /** Earlier versions of each entry, oldest first. */
+  private versions = new Map<string, VecFSEntry[]>();
+
+  /** Adds a version record read from the storage file. */
+  load(record: VecFSEntry & { versionOf: string }): void {
+    const versions = this.versions.get(record.versionOf) ?? [];
+    versions.push(record);
+    versions.sort((a, b) => (a.version ?? 0) - (b.version ?? 0));
+    this.versions.set(record.versionOf, versions);
+  }
+
+  /**
+   * Keeps `previous` as an earlier version of its entry, dropping the
+   * oldest versions beyond `keep`.
+   *
+   * @returns The records that append the change: the kept version and a
+   *          tombstone for each dropped one. Empty when `keep` is unset.
+   */
+  retain(
+    previous: VecFSEntry,
+    keep: number | undefined,
+  ): (VecFSEntry | Tombstone)[] {
+    if (!keep) return [];
+    const versions = this.versions.get(previous.id) ?? [];
+    const version = (versions[versions.length - 1]?.version ?? 0) + 1;
+    const retained: VecFSEntry = {
+      ...previous,
+      id: `${previous.id}@v${version}`,
+      versionOf: previous.id,
+      version,
+    };
+    versions.push(retained);
+    const dropped = versions.splice(0, Math.max(0, versions.length - keep));
+    this.versions.set(previous.id, versions);
+    const tombstones = dropped.map(
+      (v): Tombstone => ({ id: v.id, deleted: true }),
+    );
+    return [retained, ...tombstones];
+  }
+
+  /**
+   * The kept versions of an entry, oldest first, each under the entry's
+   * own ID.
+   */
+  earlier(id: string): VecFSEntry[] {
+    return (this.versions.get(id) ?? []).map(
+      ({ versionOf, ...entry }): VecFSEntry => ({ ...entry, id }),
+    );
+  }
+
+  /** Every kept version record, for writing the whole file. */
+  records(): VecFSEntry[] {
+    return [...this.versions.values()].flat();
+  }
+}
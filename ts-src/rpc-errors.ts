@@ -0,0 +1,34 @@
+/**
+ * JSON-RPC error codes raised by the server itself. They match the MCP
+ * SDK's `ErrorCode`; the SDK answers unknown methods with -32601 and uses
+ * -32603 for any thrown error that carries no code.
+ */
+export const INVALID_REQUEST_CODE = -32600;
+export const INVALID_PARAMS_CODE = -32602;
+
+/** The MCP specification's code for reading a resource that does not exist. */
+export const RESOURCE_NOT_FOUND_CODE = -32002;
+
+/**
+ * An error with a JSON-RPC code. The MCP SDK copies `code` from a thrown
+ * error into the JSON-RPC error response, so handlers throw this to report
+ * something other than an internal error.
+ */
+export class RpcError extends Error {
+  readonly code: number;
+
+  constructor(code: number, message: string) {
+    super(message);
+    this.name = "RpcError";
+    this.code = code;
+  }
+}
+
+/** Builds a JSON-RPC error response for a message the server answers itself. */
+export function errorResponse(
+  id: string | number | null,
+  code: number,
+  message: string,
+) {
+  return { jsonrpc: "2.0", id, error: { code, message } } as const;
+}
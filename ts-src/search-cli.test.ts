@@ -0,0 +1,72 @@
+import { describe, it, expect } from "vitest";
+import { formatSearchTable, parseQueryVector } from "./search-cli.js";
+import { SearchResult } from "./types.js";
+
+function result(
+  id: string,
+  similarity: number,
+  score: number,
+  text?: string,
+): SearchResult {
+  return {
+    id,
+    similarity,
+    score,
+    vector: {},
+    metadata: text === undefined ? {} : { text },
+    timestamp: 0,
+  };
+}
+
+describe("formatSearchTable", () => {
+  const results = [
+    result("short", 0.91234, 2, "Deploy with the blue-green script."),
+    result("a-much-longer-id", 0.5, -1, "x".repeat(200)),
+    result("no-text", 0.1, 0),
+  ];
+
+  it("should align every column under its header", () => {
+    const lines = formatSearchTable(results, { width: 80 }).split("\n");
+    const header = lines[0];
+    const similarityEnd = header.indexOf("SIMILARITY") + "SIMILARITY".length;
+    const textStart = header.indexOf("TEXT");
+
+    expect(lines[1].indexOf("0.9123") + 6).toBe(similarityEnd);
+    expect(lines[2].indexOf("0.5000") + 6).toBe(similarityEnd);
+    expect(lines[1].indexOf("Deploy")).toBe(textStart);
+    expect(lines[2].indexOf("xxx")).toBe(textStart);
+    expect(lines[1].startsWith("short ")).toBe(true);
+  });
+
+  it("should cut long text to the width with an ellipsis", () => {
+    const lines = formatSearchTable(results, { width: 72 }).split("\n");
+
+    expect(lines[2]).toHaveLength(72);
+    expect(lines[2].endsWith("x…")).toBe(true);
+    expect(lines[1]).toContain("Deploy with the blue-green script.");
+  });
+
+  it("should write no escape codes unless color is on", () => {
+    expect(formatSearchTable(results)).not.toContain("\x1b[");
+    expect(formatSearchTable(results, { color: true })).toContain("\x1b[1m");
+  });
+
+  it("should put multi-line text on one line", () => {
+    const table = formatSearchTable([result("a", 1, 0, "one\ntwo")]);
+    expect(table.split("\n")[1]).toContain("one two");
+  });
+});
+
+describe("parseQueryVector", () => {
+  it("should accept sparse, dense and vecfs-embed input", () => {
+    expect(parseQueryVector('{"3": 0.5}')).toEqual({ 3: 0.5 });
+    expect(parseQueryVector("[0, 0.2]")).toEqual({ 1: 0.2 });
+    expect(parseQueryVector('{"vector": {"1": 1}, "model": "m"}')).toEqual({
+      1: 1,
+    });
+  });
+
+  it("should reject other input", () => {
+    expect(() => parseQueryVector('{"a": "b"}')).toThrow("Expected");
+  });
+});
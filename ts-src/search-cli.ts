@@ -0,0 +1,119 @@
+/**
+ * Helpers for the `vecfs search` subcommand, which searches the store from
+ * a shell and prints the results for a person to read.
+ */
+
+import { SearchResult, SparseVector } from "./types.js";
+import { toSparse } from "./sparse-vector.js";
+
+/** Options for {@link formatSearchTable}. */
+export interface SearchTableOptions {
+  /** Terminal width in characters; text snippets are cut to fit. */
+  width?: number;
+  /** Bold the header row with ANSI escapes. Only set for a TTY. */
+  color?: boolean;
+}
+
+/** Width used when the output is not a terminal. */
+export const DEFAULT_TABLE_WIDTH = 80;
+
+/** Longest ID shown before it is truncated. */
+const MAX_ID_WIDTH = 40;
+
+/** Narrowest text column; the row may then exceed a very small width. */
+const MIN_TEXT_WIDTH = 10;
+
+const HEADERS = ["ID", "SIMILARITY", "SCORE", "TEXT"];
+const GAP = "  ";
+
+/** Shortens text to `width` characters, marking the cut with an ellipsis. */
+function truncate(text: string, width: number): string {
+  return text.length <= width ? text : text.slice(0, width - 1) + "…";
+}
+
+/** The entry's text on one line, or an empty string if none was stored. */
+function snippet(result: SearchResult): string {
+  const text = result.metadata?.text;
+  return typeof text === "string" ? text.replace(/\s+/g, " ").trim() : "";
+}
+
+/**
+ * Formats search results as aligned columns for reading in a terminal:
+ * ID, similarity, feedback score and a one-line text snippet cut to fit
+ * the width. Numeric columns are right-aligned. No escape codes are
+ * written unless `options.color` is set.
+ *
+ * @returns The table, one line per result after a header, ending in a newline.
+ */
+export function formatSearchTable(
+  results: SearchResult[],
+  options: SearchTableOptions = {},
+): string {
+  const { width = DEFAULT_TABLE_WIDTH, color = false } = options;
+  const rows = results.map((r) => [
+    truncate(r.id, MAX_ID_WIDTH),
+    r.similarity.toFixed(4),
+    String(r.score),
+    snippet(r),
+  ]);
+  const widths = [0, 1, 2].map((i) =>
+    Math.max(HEADERS[i].length, ...rows.map((row) => row[i].length)),
+  );
+  const fixed = widths.reduce((sum, w) => sum + w + GAP.length, 0);
+  const textWidth = Math.max(MIN_TEXT_WIDTH, width - fixed);
+
+  const line = ([id, similarity, score, text]: string[]) =>
+    [
+      id.padEnd(widths[0]),
+      similarity.padStart(widths[1]),
+      score.padStart(widths[2]),
+      truncate(text, textWidth),
+    ]
+      .join(GAP)
+      .trimEnd();
+
+  const header = line(HEADERS);
+  return [color ? `\x1b[1m${header}\x1b[0m` : header, ...rows.map(line)]
+    .map((l) => l + "\n")
+    .join("");
+}
+
+/**
+ * Reads the query vector given to `vecfs search`: a sparse object, a dense
+ * array, or the JSON printed by `vecfs-embed`, whose `vector` field is used.
+ *
+ * @throws Error if the input is not one of those shapes.
+ */
+export function parseQueryVector(json: string): SparseVector {
+  return vectorFrom(JSON.parse(json));
+}
+
+/**
+ * Reads a vector from parsed JSON in any of the shapes
+ * {@link parseQueryVector} accepts.
+ *
+ * @throws Error if the value is not one of those shapes.
+ */
+export function vectorFrom(parsed: unknown): SparseVector {
+  const vector =
+    parsed && typeof parsed === "object" && "vector" in parsed
+      ? parsed.vector
+      : parsed;
+  if (Array.isArray(vector) && vector.every((v) => typeof v === "number")) {
+    return toSparse(vector);
+  }
+  if (
+    vector &&
+    typeof vector === "object" &&
+    Object.values(vector).every((v) => typeof v === "number")
+  ) {
+    const sparse: SparseVector = {};
+    for (const [key, value] of Object.entries(vector)) {
+      sparse[Number(key)] = value as number;
+    }
+    return sparse;
+  }
+  throw new Error(
+    "Expected a sparse vector object, a dense array or vecfs-embed output.",
+  );
+}
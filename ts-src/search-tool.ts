@@ -0,0 +1,216 @@
+import { z } from "zod";
+import { DEFAULT_SEARCH_LIMIT } from "./storage.js";
+import { countFacets } from "./facets.js";
+import { prefixWithinBytes, paginateByBytes } from "./response-size.js";
+import { ToolCallContext } from "./tool-timeout.js";
+import { topContributions } from "./terms.js";
+import { cosineSimilarity, norm, similarityNames } from "./sparse-vector.js";
+import { RpcError, INVALID_PARAMS_CODE } from "./rpc-errors.js";
+import {
+  ToolContext,
+  ToolHandlerMap,
+  ToolResult,
+  enterStage,
+} from "./tool-context.js";
+import {
+  vectorShapeSchema,
+  ensureVectorIsObjectOrArray,
+  namespaceSchema,
+  filterSchema,
+  validateArgs,
+  normalizeVector,
+} from "./tool-args.js";
+
+const searchArgsSchema = z.object({
+  vector: vectorShapeSchema,
+  limit: z.number().int().min(0).optional(),
+  offset: z.number().int().min(0).optional(),
+  boostField: z.string().optional(),
+  boostWeight: z.number().optional(),
+  facet: z.string().optional(),
+  filter: filterSchema.optional(),
+  after: z.number().optional(),
+  before: z.number().optional(),
+  explainTerms: z.boolean().optional(),
+  dropZero: z.boolean().optional(),
+  metric: z
+    .string()
+    .refine((name) => similarityNames().includes(name), {
+      message: "Unknown similarity metric",
+    })
+    .optional(),
+  recencyTiebreak: z.boolean().optional(),
+  boostTags: z.array(z.string()).optional(),
+  penaltyTags: z.array(z.string()).optional(),
+  referenceVector: z
+    .preprocess(
+      (v) => (typeof v === "string" ? JSON.parse(v) : v),
+      vectorShapeSchema,
+    )
+    .optional(),
+  namespace: namespaceSchema.optional(),
+  namespaces: z
+    .union([z.literal("*"), z.array(namespaceSchema).min(1)])
+    .optional(),
+});
+
+/**
+ * Builds the `search` tool, which ranks entries against a query vector
+ * and can page, facet, explain and fan out over namespaces.
+ */
+export function searchTool(context: ToolContext): ToolHandlerMap {
+  const { options, shards, storageFor } = context;
+  return {
+    async search(args: unknown, ctx?: ToolCallContext): Promise<ToolResult> {
+      const {
+        vector,
+        limit,
+        offset,
+        boostField,
+        boostWeight,
+        facet,
+        filter,
+        after,
+        before,
+        explainTerms,
+        dropZero,
+        metric,
+        recencyTiebreak,
+        boostTags,
+        penaltyTags,
+        referenceVector,
+        namespace,
+        namespaces,
+      } = validateArgs(
+        searchArgsSchema,
+        ensureVectorIsObjectOrArray(args),
+        "search",
+      );
+      if (
+        namespaces &&
+        (namespace !== undefined || facet || offset !== undefined)
+      ) {
+        throw new RpcError(
+          INVALID_PARAMS_CODE,
+          "'namespaces' cannot be combined with 'namespace', 'facet' or " +
+            "'offset'.",
+        );
+      }
+      const sparseVector = normalizeVector(vector);
+      enterStage(ctx, "storage");
+      const store = await storageFor(namespace);
+      const searchOptions = {
+        boostField,
+        boostWeight,
+        filter,
+        after,
+        before,
+        dropZero,
+        metric,
+        recencyTiebreak,
+        boostTags,
+        penaltyTags,
+      };
+      const count = limit ?? DEFAULT_SEARCH_LIMIT;
+      const paged = offset !== undefined;
+      const start = offset ?? 0;
+      // Facets count every scored entry, so only then is the full ranking kept.
+      const ranked = facet
+        ? await store.rank(sparseVector, searchOptions)
+        : undefined;
+      const { results: hits, total } = namespaces
+        ? {
+            results: await shards().search(
+              sparseVector,
+              count,
+              searchOptions,
+              namespaces === "*" ? undefined : namespaces,
+            ),
+            total: undefined,
+          }
+        : ranked
+          ? {
+              results: store.recordAccess(ranked.slice(start, start + count)),
+              total: ranked.length,
+            }
+          : paged
+            ? await store.searchPage(sparseVector, start, count, searchOptions)
+            : {
+                results: await store.search(sparseVector, count, searchOptions),
+                total: undefined,
+              };
+      // A page past the end is still a page, so it keeps its total.
+      if (hits.length === 0 && options.emptyResultMessage && !paged) {
+        return {
+          content: [{ type: "text", text: options.emptyResultMessage }],
+        };
+      }
+      const terms = explainTerms ? await store.termDictionary() : undefined;
+      const reference = referenceVector && normalizeVector(referenceVector);
+      const referenceNorm = reference && norm(reference);
+      // Positions count from 1 across pages, so a page at offset 5 starts at 6.
+      const results = hits.map((hit, i) => ({
+        ...hit,
+        position: start + i + 1,
+        ...(terms && {
+          explanation: topContributions(sparseVector, hit.vector, terms),
+        }),
+        ...(reference && {
+          referenceSimilarity: cosineSimilarity(
+            reference,
+            hit.vector,
+            referenceNorm,
+          ),
+        }),
+      }));
+      enterStage(ctx, "render");
+      const facets = facet && ranked ? countFacets(ranked, facet) : undefined;
+      const render = (items: typeof results, truncated: boolean) => {
+        const body =
+          facets || truncated || paged
+            ? {
+                results: items,
+                ...(paged && { total, offset }),
+                ...(facets && { facets }),
+                ...(truncated && { truncated }),
+              }
+            : items;
+        return JSON.stringify(body, null, 2);
+      };
+      const { items, truncated } = prefixWithinBytes(
+        results,
+        options.maxResponseBytes,
+        render,
+      );
+      const text = render(items, truncated);
+      const { chunkBytes } = options;
+      if (chunkBytes === undefined || Buffer.byteLength(text) <= chunkBytes) {
+        return { content: [{ type: "text", text }] };
+      }
+      // The total, facets and truncation flag describe the whole result
+      // set, so they ride on the first page only.
+      const renderPage = (page: typeof results, n: number, total: number) =>
+        JSON.stringify(
+          {
+            page: n,
+            total_pages: total,
+            results: page,
+            ...(n === 1 && paged && { total, offset }),
+            ...(n === 1 && facets && { facets }),
+            ...(n === 1 && truncated && { truncated }),
+          },
+          null,
+          2,
+        );
+      const pages = paginateByBytes(items, chunkBytes, (page) =>
+        renderPage(page, 1, 1),
+      );
+      return {
+        content: pages.map((page, i) => ({
+          type: "text",
+          text: renderPage(page, i + 1, pages.length),
+        })),
+      };
+    },
+  };
+}
@@ -0,0 +1,26 @@
+import { describe, it, expect } from "vitest";
+import { seededRandom, randomSparseVector } from "./seeded-random.js";
+
+describe("seededRandom", () => {
+  it("should repeat the sequence for the same seed", () => {
+    const a = seededRandom(7);
+    const b = seededRandom(7);
+    const first = Array.from({ length: 5 }, a);
+    expect(Array.from({ length: 5 }, b)).toEqual(first);
+    for (const value of first) {
+      expect(value).toBeGreaterThanOrEqual(0);
+      expect(value).toBeLessThan(1);
+    }
+  });
+
+  it("should differ between seeds", () => {
+    expect(seededRandom(1)()).not.toBe(seededRandom(2)());
+  });
+
+  it("should build reproducible sparse vectors", () => {
+    const a = randomSparseVector(seededRandom(3), 1000, 20);
+    const b = randomSparseVector(seededRandom(3), 1000, 20);
+    expect(a).toEqual(b);
+    expect(Object.keys(a).length).toBeLessThanOrEqual(20);
+  });
+});
@@ -0,0 +1,34 @@
+import { SparseVector } from "./types.js";
+
+/**
+ * Returns a deterministic pseudo-random generator (mulberry32) yielding
+ * numbers in [0, 1). The server itself has no randomised behaviour; this
+ * exists so benchmarks and property tests build the same data every run.
+ *
+ * @param seed - Any 32-bit integer; the same seed gives the same sequence.
+ */
+export function seededRandom(seed: number): () => number {
+  return () => {
+    seed = (seed + 0x6d2b79f5) | 0;
+    let t = Math.imul(seed ^ (seed >>> 15), 1 | seed);
+    t = (t + Math.imul(t ^ (t >>> 7), 61 | t)) ^ t;
+    return ((t ^ (t >>> 14)) >>> 0) / 4294967296;
+  };
+}
+
+/**
+ * Builds a sparse vector with up to `nonZeros` weights in [0, 1) spread
+ * over `dims` dimensions. Repeated indices collapse, so the result may
+ * hold slightly fewer entries than requested.
+ */
+export function randomSparseVector(
+  random: () => number,
+  dims: number,
+  nonZeros: number,
+): SparseVector {
+  const vector: SparseVector = {};
+  for (let i = 0; i < nonZeros; i++) {
+    vector[Math.floor(random() * dims)] = random();
+  }
+  return vector;
+}
@@ -0,0 +1,77 @@
+import { describe, it, expect, beforeEach, afterEach } from "vitest";
+import { ShardedStorage } from "./shards.js";
+import * as fs from "fs/promises";
+import * as os from "os";
+import * as path from "path";
+
+describe("ShardedStorage", () => {
+  let dir: string;
+  let shards: ShardedStorage;
+
+  beforeEach(async () => {
+    dir = await fs.mkdtemp(path.join(os.tmpdir(), "vecfs-shards-"));
+    shards = new ShardedStorage(dir);
+  });
+
+  afterEach(async () => {
+    await shards.close();
+    await fs.rm(dir, { recursive: true, force: true });
+  });
+
+  it("should keep each namespace in its own file", async () => {
+    const alpha = await shards.shard("alpha");
+    const beta = await shards.shard("beta");
+    await alpha.store({ id: "a", vector: { 1: 1 }, metadata: {}, score: 0 });
+    await beta.store({ id: "b", vector: { 1: 1 }, metadata: {}, score: 0 });
+
+    expect(await alpha.get("b")).toBeUndefined();
+    expect(await beta.get("a")).toBeUndefined();
+    const alphaFile = await fs.readFile(path.join(dir, "alpha.jsonl"), "utf8");
+    const betaFile = await fs.readFile(path.join(dir, "beta.jsonl"), "utf8");
+    expect(alphaFile).toContain('"id":"a"');
+    expect(alphaFile).not.toContain('"id":"b"');
+    expect(betaFile).toContain('"id":"b"');
+    expect(await shards.namespaces()).toEqual(["alpha", "beta"]);
+  });
+
+  it("should return the same store for a namespace every time", async () => {
+    expect(await shards.shard("alpha")).toBe(await shards.shard("alpha"));
+  });
+
+  it("should reject namespaces that are not plain file names", async () => {
+    for (const name of ["../escape", "a/b", "", ".hidden"]) {
+      await expect(shards.shard(name)).rejects.toThrow("Invalid namespace");
+    }
+  });
+
+  it("should merge the top results across namespaces", async () => {
+    const alpha = await shards.shard("alpha");
+    const beta = await shards.shard("beta");
+    await alpha.store({ id: "a1", vector: { 1: 1 }, metadata: {}, score: 0 });
+    await alpha.store({ id: "a2", vector: { 2: 1 }, metadata: {}, score: 0 });
+    await beta.store({
+      id: "b1",
+      vector: { 1: 1, 2: 0.2 },
+      metadata: {},
+      score: 0,
+    });
+    await beta.store({ id: "b2", vector: { 3: 1 }, metadata: {}, score: 0 });
+
+    const results = await shards.search({ 1: 1 }, 2);
+    expect(results.map((r) => [r.namespace, r.id])).toEqual([
+      ["alpha", "a1"],
+      ["beta", "b1"],
+    ]);
+  });
+
+  it("should search only the requested namespaces", async () => {
+    const alpha = await shards.shard("alpha");
+    const beta = await shards.shard("beta");
+    await alpha.store({ id: "a", vector: { 1: 1 }, metadata: {}, score: 0 });
+    await beta.store({ id: "b", vector: { 1: 1 }, metadata: {}, score: 0 });
+
+    const results = await shards.search({ 1: 1 }, 5, {}, ["beta", "gamma"]);
+    expect(results.map((r) => r.id)).toEqual(["b"]);
+    expect(await shards.namespaces()).toEqual(["alpha", "beta"]);
+  });
+});
@@ -0,0 +1,118 @@
+/**
+ * Namespace sharding: one JSONL store per namespace under a base directory.
+ *
+ * A single file serialises every write behind one mutex and is read in
+ * full on load, which becomes the bottleneck when many tenants share a
+ * server. A {@link ShardedStorage} gives each namespace its own file,
+ * `<baseDir>/<namespace>.jsonl`, so tenants never contend or see each
+ * other's entries, and a search across namespaces fans out and merges.
+ */
+
+import { VecFSStorage, DEFAULT_SEARCH_LIMIT } from "./storage.js";
+import { StorageOptions } from "./storage-options.js";
+import { mergeRanked } from "./ranking.js";
+import { SearchOptions, SearchResult, SparseVector } from "./types.js";
+import * as fs from "fs/promises";
+import * as path from "path";
+
+/**
+ * Valid namespace names. They become file names, so only letters, digits,
+ * `_` and `-` are allowed and the name may not start with a separator.
+ */
+export const NAMESPACE_PATTERN = /^[A-Za-z0-9][A-Za-z0-9_-]*$/;
+
+const SHARD_EXTENSION = ".jsonl";
+
+/** A search result tagged with the namespace it came from. */
+export interface NamespacedResult extends SearchResult {
+  namespace: string;
+}
+
+/**
+ * Routes storage operations to a per-namespace {@link VecFSStorage}.
+ * Every shard is opened with the same storage options.
+ */
+export class ShardedStorage {
+  private baseDir: string;
+  private options: StorageOptions;
+  private shards = new Map<string, VecFSStorage>();
+
+  constructor(baseDir: string, options: StorageOptions = {}) {
+    this.baseDir = baseDir;
+    this.options = options;
+  }
+
+  /**
+   * The store for a namespace, creating its file on first use.
+   *
+   * @throws Error if the name does not match {@link NAMESPACE_PATTERN}.
+   */
+  async shard(namespace: string): Promise<VecFSStorage> {
+    if (!NAMESPACE_PATTERN.test(namespace)) {
+      throw new Error(
+        `Invalid namespace '${namespace}': use letters, digits, '_' and '-'.`,
+      );
+    }
+    let storage = this.shards.get(namespace);
+    if (!storage) {
+      const file = path.join(this.baseDir, namespace + SHARD_EXTENSION);
+      storage = new VecFSStorage(file, this.options);
+      this.shards.set(namespace, storage);
+    }
+    await storage.ensureFile();
+    return storage;
+  }
+
+  /** Namespaces that have a file in the base directory, sorted by name. */
+  async namespaces(): Promise<string[]> {
+    const files = await fs.readdir(this.baseDir).catch((error) => {
+      if ((error as NodeJS.ErrnoException).code === "ENOENT") return [];
+      throw error;
+    });
+    return files
+      .filter((f) => f.endsWith(SHARD_EXTENSION))
+      .map((f) => f.slice(0, -SHARD_EXTENSION.length))
+      .filter((name) => NAMESPACE_PATTERN.test(name))
+      .sort((a, b) => a.localeCompare(b));
+  }
+
+  /**
+   * Searches several namespaces and merges their results into one ranking,
+   * as if the entries were in a single store. Each shard contributes at
+   * most `limit` results, so the merge never scores more than it keeps.
+   *
+   * @param namespaces - Namespaces to search. Defaults to every namespace;
+   *   names with no file yet are skipped.
+   */
+  async search(
+    queryVector: SparseVector,
+    limit: number = DEFAULT_SEARCH_LIMIT,
+    options: SearchOptions = {},
+    namespaces?: string[],
+  ): Promise<NamespacedResult[]> {
+    const existing = await this.namespaces();
+    const targets = namespaces
+      ? namespaces.filter((name) => existing.includes(name))
+      : existing;
+    const stores = await Promise.all(targets.map((name) => this.shard(name)));
+    const lists = await Promise.all(
+      stores.map(async (storage, i) =>
+        (await storage.best(queryVector, limit, options)).map((r) => ({
+          ...r,
+          result: { ...r.result, namespace: targets[i] },
+        })),
+      ),
+    );
+    const merged = mergeRanked(lists, limit, options.recencyTiebreak);
+    for (const [i, storage] of stores.entries()) {
+      const mine = merged.filter((r) => r.result.namespace === targets[i]);
+      storage.recordAccess(mine.map((r) => r.result));
+    }
+    return merged.map((r) => r.result);
+  }
+
+  /** Flushes and closes every open shard. */
+  async close(): Promise<void> {
+    await Promise.all([...this.shards.values()].map((s) => s.close()));
+  }
+}
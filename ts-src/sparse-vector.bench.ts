@@ -0,0 +1,30 @@
+import { bench, describe } from "vitest";
+import { cosineSimilarity, dotProduct, norm } from "./sparse-vector.js";
+import { seededRandom, randomSparseVector } from "./seeded-random.js";
+
+// A short text embedded with SPLADE has a few hundred non-zero weights; a
+// long document can approach the 30k-term vocabulary.
+for (const nonZeros of [256, 30_000]) {
+  describe(`vectors with ${nonZeros} non-zeros`, () => {
+    const random = seededRandom(nonZeros);
+    const a = randomSparseVector(random, 100_000, nonZeros);
+    const b = randomSparseVector(random, 100_000, nonZeros);
+    const aNorm = norm(a);
+
+    bench("dotProduct", () => {
+      dotProduct(a, b);
+    });
+
+    bench("norm", () => {
+      norm(a);
+    });
+
+    bench("cosineSimilarity", () => {
+      cosineSimilarity(a, b);
+    });
+
+    bench("cosineSimilarity with query norm", () => {
+      cosineSimilarity(a, b, aNorm);
+    });
+  });
+}
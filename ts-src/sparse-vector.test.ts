@@ -3,8 +3,18 @@ import {
   dotProduct,
   norm,
   cosineSimilarity,
+  l1Distance,
+  l2Distance,
+  add,
+  scale,
+  mean,
+  toDense,
   toSparse,
+  registerSimilarity,
+  similarityFunction,
+  similarityNames,
 } from "./sparse-vector.js";
+import { SparseVector } from "./types.js";
 
 describe("sparse-vector", () => {
   describe("dotProduct", () => {
@@ -102,6 +112,101 @@ describe("sparse-vector", () => {
     });
   });
 
+  describe("l1Distance", () => {
+    it("should return 0 for identical vectors", () => {
+      const v = { 0: 1, 10: -2 };
+      expect(l1Distance(v, v)).toBe(0);
+    });
+
+    it("should sum magnitudes for disjoint vectors", () => {
+      const v1 = { 0: 1, 1: -2 };
+      const v2 = { 2: 3 };
+      // |1| + |-2| + |3| = 6
+      expect(l1Distance(v1, v2)).toBe(6);
+    });
+
+    it("should handle partially overlapping vectors", () => {
+      const v1 = { 0: 1, 1: 2 };
+      const v2 = { 1: 5, 2: -4 };
+      // |1 - 0| + |2 - 5| + |0 - (-4)| = 8
+      expect(l1Distance(v1, v2)).toBe(8);
+    });
+
+    it("should be symmetric with negative values", () => {
+      const v1 = { 0: -1, 3: 2 };
+      const v2 = { 0: 1, 5: -1 };
+      expect(l1Distance(v1, v2)).toBe(5);
+      expect(l1Distance(v2, v1)).toBe(5);
+    });
+
+    it("should return 0 for two empty vectors", () => {
+      expect(l1Distance({}, {})).toBe(0);
+    });
+  });
+
+  describe("l2Distance", () => {
+    it("should return 0 for identical vectors", () => {
+      const v = { 0: 1, 10: -2 };
+      expect(l2Distance(v, v)).toBe(0);
+    });
+
+    it("should combine norms for disjoint vectors", () => {
+      const v1 = { 0: 3 };
+      const v2 = { 1: -4 };
+      expect(l2Distance(v1, v2)).toBe(5);
+    });
+
+    it("should handle partially overlapping vectors", () => {
+      const v1 = { 0: 1, 1: 2 };
+      const v2 = { 1: 4, 2: 2 };
+      // sqrt(1^2 + (2 - 4)^2 + 2^2) = 3
+      expect(l2Distance(v1, v2)).toBe(3);
+    });
+
+    it("should be symmetric with negative values", () => {
+      const v1 = { 0: -1, 1: 1 };
+      const v2 = { 0: 2, 1: 5 };
+      expect(l2Distance(v1, v2)).toBe(5);
+      expect(l2Distance(v2, v1)).toBe(5);
+    });
+  });
+
+  describe("similarity registry", () => {
+    it("should ship cosine, dot and euclidean", () => {
+      const a = { 0: 3, 1: 4 };
+      const b = { 0: 3 };
+      expect(similarityNames().slice(0, 3)).toEqual([
+        "cosine",
+        "dot",
+        "euclidean",
+      ]);
+      expect(similarityFunction("cosine")(a, b, 5)).toBeCloseTo(0.6);
+      expect(similarityFunction("dot")(a, b, 5)).toBe(9);
+      expect(similarityFunction("euclidean")(a, b, 5)).toBe(1 / 5);
+      expect(similarityFunction("euclidean")(a, a, 5)).toBe(1);
+    });
+
+    it("should look up a registered metric by name", () => {
+      const overlap = (query: SparseVector, vector: SparseVector) =>
+        Object.keys(query).filter((k) => k in vector).length;
+      registerSimilarity("test-overlap", overlap);
+
+      expect(similarityNames()).toContain("test-overlap");
+      const similarity = similarityFunction("test-overlap");
+      expect(similarity({ 1: 1, 2: 1 }, { 2: 9 }, 0)).toBe(1);
+    });
+
+    it("should reject unknown and built-in names", () => {
+      expect(() => similarityFunction("nope")).toThrow(
+        "Unknown similarity metric 'nope'",
+      );
+      expect(() => registerSimilarity("cosine", () => 0)).toThrow(
+        "built-in",
+      );
+      expect(() => registerSimilarity("", () => 0)).toThrow("name");
+    });
+  });
+
   describe("toSparse", () => {
     it("should convert dense to sparse", () => {
       const dense = [0, 1, 0, 2];
@@ -126,4 +231,69 @@ describe("sparse-vector", () => {
       expect(toSparse(dense)).toEqual({ 0: -1, 2: 2 });
     });
   });
+
+  describe("toDense", () => {
+    it("should zero-fill dimensions the vector lacks", () => {
+      expect(toDense({ 1: 1, 3: 2 }, 5)).toEqual([0, 1, 0, 2, 0]);
+    });
+
+    it("should round-trip with toSparse", () => {
+      const dense = [0, -1.5, 0, 2, 0.25];
+      expect(toDense(toSparse(dense), dense.length)).toEqual(dense);
+    });
+
+    it("should return all zeros for an empty vector", () => {
+      expect(toDense({}, 3)).toEqual([0, 0, 0]);
+    });
+
+    it("should throw when dims is too small for a dimension", () => {
+      expect(() => toDense({ 1: 1, 7: 2 }, 4)).toThrow("Dimension 7");
+    });
+  });
+
+  describe("add", () => {
+    it("should sum shared dimensions", () => {
+      expect(add({ 0: 1, 1: 2 }, { 1: 3 })).toEqual({ 0: 1, 1: 5 });
+    });
+
+    it("should keep dimensions from disjoint vectors", () => {
+      expect(add({ 0: 1 }, { 5: 2 })).toEqual({ 0: 1, 5: 2 });
+    });
+
+    it("should return the other vector when one is empty", () => {
+      expect(add({}, { 2: 1 })).toEqual({ 2: 1 });
+      expect(add({ 2: 1 }, {})).toEqual({ 2: 1 });
+    });
+
+    it("should not modify its arguments", () => {
+      const v1: SparseVector = { 0: 1 };
+      add(v1, { 0: 2 });
+      expect(v1).toEqual({ 0: 1 });
+    });
+  });
+
+  describe("scale", () => {
+    it("should multiply every component", () => {
+      expect(scale({ 0: 1, 3: -2 }, 0.5)).toEqual({ 0: 0.5, 3: -1 });
+    });
+
+    it("should return an empty vector for an empty vector", () => {
+      expect(scale({}, 3)).toEqual({});
+    });
+  });
+
+  describe("mean", () => {
+    it("should count dimensions a vector lacks as zero", () => {
+      const vectors: SparseVector[] = [{ 0: 3, 1: 3 }, { 0: 3 }, { 2: 3 }];
+      expect(mean(vectors)).toEqual({ 0: 2, 1: 1, 2: 1 });
+    });
+
+    it("should return a single vector unchanged", () => {
+      expect(mean([{ 4: 0.5 }])).toEqual({ 4: 0.5 });
+    });
+
+    it("should return an empty vector for no vectors", () => {
+      expect(mean([])).toEqual({});
+    });
+  });
 });
@@ -63,6 +63,158 @@ export function cosineSimilarity(
   return dotProduct(v1, v2) / (n1 * n2);
 }
 
+/**
+ * Applies a per-dimension difference function over the union of the
+ * dimensions of two sparse vectors. A dimension missing from one vector
+ * counts as 0 there, so each dimension is visited exactly once.
+ */
+function sumOverUnion(
+  v1: SparseVector,
+  v2: SparseVector,
+  term: (diff: number) => number,
+): number {
+  let sum = 0;
+  for (const key of Object.keys(v1)) {
+    const index = Number(key);
+    sum += term(v1[index] - (v2[index] ?? 0));
+  }
+  for (const key of Object.keys(v2)) {
+    const index = Number(key);
+    if (v1[index] === undefined) sum += term(v2[index]);
+  }
+  return sum;
+}
+
+/**
+ * Calculates the Manhattan (L1) distance between two sparse vectors.
+ *
+ * @param v1 - The first sparse vector.
+ * @param v2 - The second sparse vector.
+ * @returns The sum of absolute differences over all dimensions.
+ */
+export function l1Distance(v1: SparseVector, v2: SparseVector): number {
+  return sumOverUnion(v1, v2, Math.abs);
+}
+
+/**
+ * Calculates the Euclidean (L2) distance between two sparse vectors.
+ *
+ * @param v1 - The first sparse vector.
+ * @param v2 - The second sparse vector.
+ * @returns The square root of the summed squared differences.
+ */
+export function l2Distance(v1: SparseVector, v2: SparseVector): number {
+  return Math.sqrt(sumOverUnion(v1, v2, (diff) => diff * diff));
+}
+
+/**
+ * Adds two sparse vectors. A dimension present in only one keeps its value.
+ *
+ * @param v1 - The first sparse vector.
+ * @param v2 - The second sparse vector.
+ * @returns A new vector holding the sum over the union of dimensions.
+ */
+export function add(v1: SparseVector, v2: SparseVector): SparseVector {
+  const sum: SparseVector = { ...v1 };
+  for (const key in v2) {
+    sum[key] = (sum[key] || 0) + v2[key];
+  }
+  return sum;
+}
+
+/**
+ * Multiplies every component of a sparse vector by a factor.
+ *
+ * @param v - The sparse vector.
+ * @param factor - The multiplier.
+ * @returns A new vector with the same dimensions.
+ */
+export function scale(v: SparseVector, factor: number): SparseVector {
+  const scaled: SparseVector = {};
+  for (const key in v) {
+    scaled[key] = v[key] * factor;
+  }
+  return scaled;
+}
+
+/**
+ * Averages sparse vectors component-wise. A dimension missing from a
+ * vector counts as zero, so the result is the centroid of the vectors.
+ *
+ * @param vectors - The vectors to average.
+ * @returns Their mean, or an empty vector when there are none.
+ */
+export function mean(vectors: SparseVector[]): SparseVector {
+  if (vectors.length === 0) return {};
+  return scale(vectors.reduce(add, {}), 1 / vectors.length);
+}
+
+/**
+ * Scores how similar a stored vector is to a query; higher ranks first.
+ * `queryNorm` is the query's pre-computed {@link norm}, passed so that
+ * functions which need it do not recompute it for every entry.
+ */
+export type SimilarityFunction = (
+  query: SparseVector,
+  vector: SparseVector,
+  queryNorm: number,
+) => number;
+
+const BUILT_IN_SIMILARITIES: [string, SimilarityFunction][] = [
+  [
+    "cosine",
+    (query, vector, queryNorm) => cosineSimilarity(query, vector, queryNorm),
+  ],
+  ["dot", (query, vector) => dotProduct(query, vector)],
+  // Distance turned into a similarity: 1 for identical vectors, falling
+  // towards 0 as they move apart.
+  ["euclidean", (query, vector) => 1 / (1 + l2Distance(query, vector))],
+];
+
+const similarities = new Map<string, SimilarityFunction>(
+  BUILT_IN_SIMILARITIES,
+);
+
+/**
+ * Registers a named similarity function so searches can select it as
+ * their metric, for example from the module named by
+ * `VECFS_METRICS_MODULE` (see metrics-module.ts).
+ * Registering a name again replaces the earlier function.
+ *
+ * @throws Error if the name is empty or is a built-in metric.
+ */
+export function registerSimilarity(
+  name: string,
+  fn: SimilarityFunction,
+): void {
+  if (!name) throw new Error("A similarity metric needs a name.");
+  if (BUILT_IN_SIMILARITIES.some(([builtIn]) => builtIn === name)) {
+    throw new Error(`Cannot replace the built-in metric '${name}'.`);
+  }
+  similarities.set(name, fn);
+}
+
+/** Names of every registered similarity metric, built-ins first. */
+export function similarityNames(): string[] {
+  return [...similarities.keys()];
+}
+
+/**
+ * The similarity function registered under a name.
+ *
+ * @throws Error if no metric has that name.
+ */
+export function similarityFunction(name: string): SimilarityFunction {
+  const fn = similarities.get(name);
+  if (!fn) {
+    throw new Error(
+      `Unknown similarity metric '${name}'; expected one of ` +
+        `${similarityNames().join(", ")}.`,
+    );
+  }
+  return fn;
+}
+
 /**
  * Converts a dense array representation into a sparse vector.
  * Only values with an absolute magnitude greater than the threshold are stored.
@@ -83,3 +235,27 @@ export function toSparse(
   }
   return sparse;
 }
+
+/**
+ * Expands a sparse vector into a dense array, filling absent dimensions
+ * with zero. The inverse of {@link toSparse} with a zero threshold.
+ *
+ * @param v - The sparse vector to expand.
+ * @param dims - Length of the dense array.
+ * @returns An array of `dims` numbers.
+ * @throws Error if a dimension of `v` is not an index below `dims`.
+ */
+export function toDense(v: SparseVector, dims: number): number[] {
+  const dense: number[] = new Array(dims).fill(0);
+  for (const key in v) {
+    const index = Number(key);
+    if (!Number.isInteger(index) || index < 0 || index >= dims) {
+      throw new Error(
+        `Dimension ${key} does not fit in a dense vector of ${dims} ` +
+          "dimensions.",
+      );
+    }
+    dense[index] = v[key];
+  }
+  return dense;
+}
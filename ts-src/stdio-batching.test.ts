@@ -0,0 +1,117 @@
+import { describe, it, expect, beforeEach } from "vitest";
+import { PassThrough } from "stream";
+import { JsonRpcBatcher } from "./stdio-batching.js";
+
+describe("JsonRpcBatcher", () => {
+  let stdout: PassThrough;
+  let written: string[];
+  let batcher: JsonRpcBatcher;
+
+  /**
+   * Stands in for the MCP server: answers each request line it reads with
+   * a result naming the method, and ignores notifications.
+   */
+  function serve(respond: (send: () => void) => void = (send) => send()) {
+    batcher.input.on("data", (chunk: Buffer) => {
+      for (const line of chunk.toString().split("\n")) {
+        if (!line) continue;
+        const { id, method } = JSON.parse(line);
+        if (id === undefined) continue;
+        const response = { jsonrpc: "2.0", id, result: { method } };
+        respond(() => batcher.output.write(JSON.stringify(response) + "\n"));
+      }
+    });
+  }
+
+  function lines(): any[] {
+    return written
+      .join("")
+      .split("\n")
+      .filter(Boolean)
+      .map((line) => JSON.parse(line));
+  }
+
+  beforeEach(() => {
+    stdout = new PassThrough();
+    written = [];
+    stdout.on("data", (chunk: Buffer) => written.push(chunk.toString()));
+    batcher = new JsonRpcBatcher(stdout);
+  });
+
+  it("should answer a two-request batch with a two-element array", async () => {
+    serve();
+    const batch = [
+      { jsonrpc: "2.0", id: 1, method: "tools/list", params: {} },
+      {
+        jsonrpc: "2.0",
+        id: 2,
+        method: "tools/call",
+        params: { name: "count" },
+      },
+    ];
+    batcher.input.write(JSON.stringify(batch) + "\n");
+    await new Promise((r) => setImmediate(r));
+
+    const [response, ...rest] = lines();
+    expect(rest).toHaveLength(0);
+    expect(response.map((r: any) => r.id)).toEqual([1, 2]);
+    expect(response[0].result.method).toBe("tools/list");
+    expect(response[1].result.method).toBe("tools/call");
+  });
+
+  it("should keep request order when responses arrive out of order", async () => {
+    const queued: (() => void)[] = [];
+    serve((send) => queued.unshift(send));
+    const batch = [
+      { jsonrpc: "2.0", id: "a", method: "first" },
+      { jsonrpc: "2.0", id: "b", method: "second" },
+    ];
+    batcher.input.write(JSON.stringify(batch) + "\n");
+    await new Promise((r) => setImmediate(r));
+    for (const send of queued) send();
+
+    const [response] = lines();
+    expect(response.map((r: any) => r.id)).toEqual(["a", "b"]);
+  });
+
+  it("should not answer notifications in a batch", async () => {
+    serve();
+    const batch = [
+      { jsonrpc: "2.0", method: "notifications/initialized" },
+      { jsonrpc: "2.0", id: 7, method: "tools/list" },
+    ];
+    batcher.input.write(JSON.stringify(batch) + "\n");
+    await new Promise((r) => setImmediate(r));
+
+    const [response] = lines();
+    expect(response).toHaveLength(1);
+    expect(response[0].id).toBe(7);
+  });
+
+  it("should pass single messages through unchanged", async () => {
+    serve();
+    batcher.input.write(
+      JSON.stringify({ jsonrpc: "2.0", id: 3, method: "tools/list" }) + "\n",
+    );
+    await new Promise((r) => setImmediate(r));
+
+    expect(lines()).toEqual([
+      { jsonrpc: "2.0", id: 3, result: { method: "tools/list" } },
+    ]);
+  });
+
+  it("should reject an empty batch and invalid elements", async () => {
+    serve();
+    batcher.input.write("[]\n");
+    batcher.input.write(
+      JSON.stringify([1, { jsonrpc: "2.0", id: 4, method: "m" }]) + "\n",
+    );
+    await new Promise((r) => setImmediate(r));
+
+    const [empty, mixed] = lines();
+    expect(empty.error.code).toBe(-32600);
+    expect(mixed).toHaveLength(2);
+    expect(mixed[0].id).toBe(4);
+    expect(mixed[1].error.code).toBe(-32600);
+  });
+});
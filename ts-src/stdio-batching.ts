@@ -0,0 +1,177 @@
+/**
+ * JSON-RPC batch support for the stdio transport.
+ *
+ * JSON-RPC 2.0 lets a client send several requests as one array, but the
+ * MCP SDK's stdio transport reads exactly one message per line and rejects
+ * arrays. A {@link JsonRpcBatcher} sits between the process streams and
+ * the transport: on the way in it splits each batch into single messages,
+ * and on the way out it gathers the responses to those messages into one
+ * array, in request order.
+ */
+
+import { Transform, Writable } from "stream";
+import { errorResponse, INVALID_REQUEST_CODE } from "./rpc-errors.js";
+
+type Id = string | number;
+
+interface Batch {
+  /** Request IDs in the order the client sent them. */
+  ids: Id[];
+  /** Responses received so far, by request ID key. */
+  responses: Map<string, unknown>;
+  /** Error responses for batch elements that were not valid messages. */
+  invalid: unknown[];
+}
+
+/** Response for an element of a batch that is not a JSON-RPC message. */
+const INVALID_REQUEST = errorResponse(
+  null,
+  INVALID_REQUEST_CODE,
+  "Invalid Request",
+);
+
+/** Distinguishes the numeric ID 1 from the string ID "1". */
+function idKey(id: Id): string {
+  return `${typeof id}:${id}`;
+}
+
+function isObject(value: unknown): value is Record<string, unknown> {
+  return typeof value === "object" && value !== null && !Array.isArray(value);
+}
+
+function isId(value: unknown): value is Id {
+  return typeof value === "string" || typeof value === "number";
+}
+
+/** The ID of a request; notifications and responses have none. */
+function requestId(message: Record<string, unknown>): Id | undefined {
+  return "method" in message && isId(message.id) ? message.id : undefined;
+}
+
+/** The ID key of a response message, or undefined for anything else. */
+function responseKey(message: unknown): string | undefined {
+  if (!isObject(message) || "method" in message) return undefined;
+  return isId(message.id) ? idKey(message.id) : undefined;
+}
+
+/** Parses JSON, returning undefined for malformed text. */
+function parse(text: string): unknown {
+  try {
+    return JSON.parse(text);
+  } catch {
+    return undefined;
+  }
+}
+
+/** Splits a stream of text into lines, keeping a trailing partial line. */
+class LineBuffer {
+  private pending = "";
+
+  push(chunk: Buffer | string): string[] {
+    const lines = (this.pending + chunk.toString()).split("\n");
+    this.pending = lines.pop() ?? "";
+    return lines;
+  }
+}
+
+/**
+ * Adapts process streams so a line-oriented transport can serve JSON-RPC
+ * batches. Pass {@link input} and {@link output} to the transport in place
+ * of stdin and stdout, and pipe stdin into {@link input}.
+ *
+ * Notifications in a batch are delivered but, as the specification
+ * requires, get no response; a batch made only of notifications produces
+ * no output. An empty batch is answered with an Invalid Request error.
+ */
+export class JsonRpcBatcher {
+  /** Messages from the client, one per line. */
+  readonly input: Transform;
+  /** Messages from the server, one per line, to be forwarded or gathered. */
+  readonly output: Writable;
+
+  private readonly out: Writable;
+  private readonly batches = new Map<string, Batch>();
+  private readonly inLines = new LineBuffer();
+  private readonly outLines = new LineBuffer();
+
+  constructor(out: Writable) {
+    this.out = out;
+    this.input = new Transform({
+      transform: (chunk: Buffer, _encoding, callback) => {
+        for (const line of this.inLines.push(chunk)) this.receive(line);
+        callback();
+      },
+    });
+    this.output = new Writable({
+      write: (chunk: Buffer, _encoding, callback) => {
+        for (const line of this.outLines.push(chunk)) this.send(line);
+        callback();
+      },
+    });
+  }
+
+  /** Forwards a client line, splitting it first if it holds a batch. */
+  private receive(line: string): void {
+    const messages = line.trimStart().startsWith("[") ? parse(line) : null;
+    if (!Array.isArray(messages)) {
+      this.input.push(line + "\n");
+      return;
+    }
+    if (messages.length === 0) {
+      this.write(INVALID_REQUEST);
+      return;
+    }
+    const valid = messages.filter(isObject);
+    const batch: Batch = {
+      ids: [],
+      responses: new Map(),
+      invalid: messages.filter((m) => !isObject(m)).map(() => INVALID_REQUEST),
+    };
+    // Register every ID before forwarding anything, so an early response
+    // cannot complete the batch while later requests are still unsent.
+    for (const message of valid) {
+      const id = requestId(message);
+      if (id === undefined || this.batches.has(idKey(id))) continue;
+      batch.ids.push(id);
+      this.batches.set(idKey(id), batch);
+    }
+    for (const message of valid) {
+      this.input.push(JSON.stringify(message) + "\n");
+    }
+    // With requests in it, the batch completes as their responses arrive.
+    if (batch.ids.length === 0) this.complete(batch);
+  }
+
+  /** Forwards a server line, or holds it if it answers a batched request. */
+  private send(line: string): void {
+    if (this.batches.size === 0) {
+      this.out.write(line + "\n");
+      return;
+    }
+    const message = parse(line);
+    const key = responseKey(message);
+    const batch = key === undefined ? undefined : this.batches.get(key);
+    if (key === undefined || !batch) {
+      this.out.write(line + "\n");
+      return;
+    }
+    this.batches.delete(key);
+    batch.responses.set(key, message);
+    this.complete(batch);
+  }
+
+  /** Writes a batch's responses once every request in it has one. */
+  private complete(batch: Batch): void {
+    if (batch.responses.size < batch.ids.length) return;
+    const responses = [
+      ...batch.ids.map((id) => batch.responses.get(idKey(id))),
+      ...batch.invalid,
+    ];
+    if (responses.length > 0) this.write(responses);
+  }
+
+  private write(message: unknown): void {
+    this.out.write(JSON.stringify(message) + "\n");
+  }
+}
+
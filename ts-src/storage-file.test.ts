@@ -0,0 +1,356 @@
+import { describe, it, expect, beforeEach, afterEach } from "vitest";
+import { VecFSStorage } from "./storage.js";
+import { fixedClock } from "./fixed-clock.js";
+import * as fs from "fs/promises";
+import * as os from "os";
+import * as path from "path";
+
+describe("storage file", () => {
+  const testFilePath = "./test-storage-file.jsonl";
+
+  beforeEach(async () => {
+    try {
+      await fs.unlink(testFilePath);
+    } catch {}
+  });
+
+  afterEach(async () => {
+    try {
+      await fs.unlink(testFilePath);
+    } catch {}
+  });
+
+  it("should ensure file exists", async () => {
+    const storage = new VecFSStorage(testFilePath);
+    await storage.ensureFile();
+    await expect(fs.access(testFilePath)).resolves.toBeUndefined();
+  });
+
+  it("should create the file exactly once under concurrent first use", async () => {
+    const writer = new VecFSStorage(testFilePath);
+    const others = Array.from(
+      { length: 20 },
+      () => new VecFSStorage(testFilePath),
+    );
+
+    const [created] = await Promise.all([
+      Promise.all([writer, ...others].map((s) => s.ensureFile())),
+      writer.store({ id: "a", vector: { 0: 1 }, metadata: {}, score: 0 }),
+    ]);
+    await Promise.all(others.map((s) => s.ensureFile()));
+
+    expect(created.filter(Boolean)).toHaveLength(1);
+    const reloaded = new VecFSStorage(testFilePath);
+    expect((await reloaded.get("a"))?.id).toBe("a");
+  });
+
+  it("should leave an existing file untouched", async () => {
+    await fs.writeFile(testFilePath, "");
+    expect(await new VecFSStorage(testFilePath).ensureFile()).toBe(false);
+  });
+
+  it("should read a file with CRLF line endings", async () => {
+    const lines = [
+      { id: "a", vector: { 0: 1 }, metadata: { text: "x" }, score: 1 },
+      { id: "b", vector: { 1: 1 }, metadata: {}, score: 0 },
+    ].map((entry) => JSON.stringify({ ...entry, timestamp: 1 }));
+    await fs.writeFile(testFilePath, lines.join("\r\n") + "\r\n");
+
+    const storage = new VecFSStorage(testFilePath);
+    expect(await storage.count()).toBe(2);
+    expect((await storage.get("a"))?.metadata).toEqual({ text: "x" });
+    expect(await storage.get("b")).toBeDefined();
+  });
+
+  it("should report a clear error when the storage path is a directory", async () => {
+    const dir = await fs.mkdtemp(path.join(os.tmpdir(), "vecfs-dir-"));
+    try {
+      const storage = new VecFSStorage(dir);
+      await expect(storage.ensureFile()).rejects.toThrow("is a directory");
+    } finally {
+      await fs.rm(dir, { recursive: true, force: true });
+    }
+  });
+
+  describe("close and fsync", () => {
+    for (const fsync of [false, true]) {
+      it(`should keep entries across a reopen (fsync ${fsync})`, async () => {
+        const storage = new VecFSStorage(testFilePath, { fsync });
+        await storage.ensureFile();
+        await storage.store({
+          id: "a",
+          vector: { 0: 1 },
+          metadata: {},
+          score: 0,
+        });
+        await storage.updateScore("a", 1);
+        await storage.close();
+
+        const reopened = new VecFSStorage(testFilePath);
+        expect((await reopened.get("a"))?.score).toBe(1);
+      });
+    }
+
+    it("should close an unused storage without creating the file", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.close();
+      await expect(fs.access(testFilePath)).rejects.toThrow();
+    });
+  });
+
+  describe("checksums", () => {
+    async function captureWarnings(run: () => Promise<void>) {
+      const warnings: string[] = [];
+      const warn = console.warn;
+      console.warn = (message: string) => warnings.push(message);
+      try {
+        await run();
+      } finally {
+        console.warn = warn;
+      }
+      return warnings;
+    }
+
+    async function storeTwo() {
+      const storage = new VecFSStorage(testFilePath, { checksums: true });
+      await storage.ensureFile();
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await storage.store({
+        id: "b",
+        vector: { 1: 1 },
+        metadata: {},
+        score: 0,
+      });
+    }
+
+    it("should write a checksum after each record", async () => {
+      await storeTwo();
+      const lines = (await fs.readFile(testFilePath, "utf-8")).trim();
+      for (const line of lines.split("\n")) {
+        expect(line).toMatch(/^\{.*\}\t[0-9a-f]{8}$/);
+      }
+    });
+
+    it("should flag and skip a tampered line", async () => {
+      await storeTwo();
+      const content = await fs.readFile(testFilePath, "utf-8");
+      const tampered = content.replace('"score":0', '"score":9');
+      await fs.writeFile(testFilePath, tampered);
+
+      const reopened = new VecFSStorage(testFilePath, { checksums: true });
+      let ids: string[] = [];
+      const warnings = await captureWarnings(async () => {
+        ids = (await reopened.list()).entries.map((e) => e.id);
+      });
+      expect(ids).toEqual(["b"]);
+      expect(warnings).toHaveLength(1);
+      expect(warnings[0]).toContain("line 1");
+      expect(warnings[0]).toContain("checksum mismatch");
+    });
+
+    it("should verify checksums on CRLF lines", async () => {
+      await storeTwo();
+      const content = await fs.readFile(testFilePath, "utf-8");
+      await fs.writeFile(testFilePath, content.replace(/\n/g, "\r\n"));
+
+      const reopened = new VecFSStorage(testFilePath, { checksums: true });
+      let ids: string[] = [];
+      const warnings = await captureWarnings(async () => {
+        ids = (await reopened.list()).entries.map((e) => e.id).sort();
+      });
+      expect(ids).toEqual(["a", "b"]);
+      expect(warnings).toEqual([]);
+    });
+
+    it("should read checksummed files with checksums off", async () => {
+      await storeTwo();
+
+      const plain = new VecFSStorage(testFilePath);
+      expect((await plain.get("a"))?.id).toBe("a");
+    });
+
+    it("should keep plain JSONL by default", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+
+      const line = (await fs.readFile(testFilePath, "utf-8")).trim();
+      expect(JSON.parse(line).id).toBe("a");
+    });
+  });
+
+  describe("atomic rewrites", () => {
+    /** Fails after the temporary file is written, as a crash would. */
+    class CrashingStorage extends VecFSStorage {
+      protected async moveIntoPlace(): Promise<void> {
+        throw new Error("simulated crash");
+      }
+    }
+
+    it("should leave the original file untouched on failure", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      const before = await fs.readFile(testFilePath, "utf-8");
+
+      const crashing = new CrashingStorage(testFilePath);
+      await expect(crashing.updateScore("a", 1)).rejects.toThrow(
+        "simulated crash",
+      );
+
+      expect(await fs.readFile(testFilePath, "utf-8")).toBe(before);
+      await expect(fs.access(`${testFilePath}.tmp`)).rejects.toThrow();
+    });
+
+    it("should keep the file mode across a rewrite", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await fs.chmod(testFilePath, 0o600);
+
+      await storage.updateScore("a", 1);
+
+      const stats = await fs.stat(testFilePath);
+      expect(stats.mode & 0o777).toBe(0o600);
+    });
+  });
+
+  describe("backups", () => {
+    let dir: string;
+    let file: string;
+
+    beforeEach(async () => {
+      dir = await fs.mkdtemp(path.join(os.tmpdir(), "vecfs-backups-"));
+      file = path.join(dir, "store.jsonl");
+    });
+
+    afterEach(async () => {
+      await fs.rm(dir, { recursive: true, force: true });
+    });
+
+    async function backups(): Promise<string[]> {
+      return (await fs.readdir(dir)).filter((n) => n.endsWith(".bak")).sort();
+    }
+
+    it("should copy the file before a rewrite", async () => {
+      const clock = fixedClock(Date.UTC(2026, 9, 15, 12, 30));
+      const storage = new VecFSStorage(file, { backups: 3, clock });
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      expect(await backups()).toEqual([]);
+      const before = await fs.readFile(file, "utf-8");
+
+      await storage.delete("a");
+
+      expect(await backups()).toEqual([
+        "store.jsonl.2026-10-15T12-30-00.000Z.bak",
+      ]);
+      const [backup] = await backups();
+      expect(await fs.readFile(path.join(dir, backup), "utf-8")).toBe(before);
+      expect(await fs.readFile(file, "utf-8")).not.toBe(before);
+    });
+
+    it("should keep only the newest backups", async () => {
+      const clock = fixedClock(Date.UTC(2026, 0, 1));
+      const storage = new VecFSStorage(file, { backups: 2, clock });
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await fs.writeFile(path.join(dir, "notes.bak"), "unrelated");
+
+      for (let i = 1; i <= 4; i++) {
+        clock.ms += 1000;
+        await storage.updateScore("a", 1);
+      }
+
+      expect(await backups()).toEqual([
+        "notes.bak",
+        "store.jsonl.2026-01-01T00-00-03.000Z.bak",
+        "store.jsonl.2026-01-01T00-00-04.000Z.bak",
+      ]);
+      const newest = path.join(dir, "store.jsonl.2026-01-01T00-00-04.000Z.bak");
+      expect(await fs.readFile(newest, "utf-8")).toContain('"score":3');
+    });
+
+    it("should make no backups by default", async () => {
+      const storage = new VecFSStorage(file);
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+
+      await storage.compact();
+
+      expect(await backups()).toEqual([]);
+    });
+  });
+
+  describe("vectorField", () => {
+    it("should store the vector under the configured field name", async () => {
+      const storage = new VecFSStorage(testFilePath, {
+        vectorField: "embedding",
+      });
+      await storage.store({
+        id: "a",
+        vector: { 3: 0.5 },
+        metadata: {},
+        score: 0,
+      });
+
+      const line = JSON.parse(
+        (await fs.readFile(testFilePath, "utf-8")).trim(),
+      );
+      expect(line.embedding).toEqual({ 3: 0.5 });
+      expect(line.vector).toBeUndefined();
+
+      const reloaded = new VecFSStorage(testFilePath, {
+        vectorField: "embedding",
+      });
+      expect((await reloaded.get("a"))?.vector).toEqual({ 3: 0.5 });
+      const results = await reloaded.search({ 3: 1 }, 1);
+      expect(results[0].similarity).toBeCloseTo(1);
+    });
+
+    it("should still read lines written with the default name", async () => {
+      const seed = new VecFSStorage(testFilePath);
+      await seed.store({ id: "a", vector: { 0: 1 }, metadata: {}, score: 0 });
+
+      const storage = new VecFSStorage(testFilePath, {
+        vectorField: "embedding",
+      });
+      expect((await storage.get("a"))?.vector).toEqual({ 0: 1 });
+    });
+
+    it("should reject a name used by another field", () => {
+      expect(
+        () => new VecFSStorage(testFilePath, { vectorField: "metadata" }),
+      ).toThrow("already used");
+    });
+  });
+});
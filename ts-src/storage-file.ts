@@ -0,0 +1,259 @@
+import * as fs from "fs/promises";
+import { constants as fsConstants } from "fs";
+import * as path from "path";
+import { VecFSEntry, Tombstone } from "./types.js";
+import { StorageOptions } from "./storage-options.js";
+import { withChecksum, splitChecksum } from "./line-checksum.js";
+
+/** File extension of backups made under the `backups` option. */
+const BACKUP_EXTENSION = ".bak";
+
+/** The time in a backup's name, as written by `backUp`. */
+const BACKUP_STAMP = /^\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2}\.\d{3}Z\.bak$/;
+
+/** Record fields that the vector cannot be stored under. */
+const RESERVED_FIELDS = [
+  "id",
+  "metadata",
+  "score",
+  "timestamp",
+  "deletedAt",
+  "expiresAt",
+  "versionOf",
+  "version",
+  "deleted",
+];
+
+/** The options that decide how records are written to the file. */
+export type StorageFileOptions = Pick<
+  StorageOptions,
+  "fsync" | "checksums" | "vectorField" | "backups" | "clock"
+>;
+
+/** Returns true if a parsed log record is a tombstone rather than an entry. */
+function isTombstone(record: VecFSEntry | Tombstone): record is Tombstone {
+  return (record as Tombstone).deleted === true;
+}
+
+/** Copies a record with the key `from` renamed to `to`, keeping key order. */
+function renameKey<T extends object>(record: T, from: string, to: string): T {
+  if (!(from in record)) return record;
+  return Object.fromEntries(
+    Object.entries(record).map(([k, v]) => [k === from ? to : k, v]),
+  ) as T;
+}
+
+/**
+ * The JSONL file behind a {@link VecFSStorage}. Reads the file as a log,
+ * appends records to it and replaces it atomically, applying the
+ * checksum, fsync, vector field and backup options. Callers serialise
+ * access; the file itself keeps no cache.
+ */
+export class StorageFile {
+  private filePath: string;
+  private options: StorageFileOptions;
+  private moveIntoPlace: (tempPath: string) => Promise<void>;
+  private initialized = false;
+
+  /**
+   * @param moveIntoPlace - Renames a fully written temporary file over the
+   *   store, the last step of a rewrite.
+   * @throws Error if `options.vectorField` names another record field.
+   */
+  constructor(
+    filePath: string,
+    options: StorageFileOptions,
+    moveIntoPlace: (tempPath: string) => Promise<void>,
+  ) {
+    const { vectorField } = options;
+    if (vectorField !== undefined && RESERVED_FIELDS.includes(vectorField)) {
+      throw new Error(
+        `Vector field name '${vectorField}' is already used by entries.`,
+      );
+    }
+    this.filePath = filePath;
+    this.options = options;
+    this.moveIntoPlace = moveIntoPlace;
+  }
+
+  /**
+   * Ensures the file and its parent directory exist.
+   * Safe to call multiple times; only performs I/O on the first invocation.
+   * The file is created exclusively, so when several instances start on the
+   * same path at once exactly one creates it and none truncates data that
+   * another has already written.
+   *
+   * @returns true if this call created the file.
+   * @throws Error if the configured path exists but is a directory.
+   */
+  async ensure(): Promise<boolean> {
+    if (this.initialized) return false;
+    const dir = path.dirname(this.filePath);
+    await fs.mkdir(dir, { recursive: true });
+    const stats = await fs.stat(this.filePath).catch((error) => {
+      if ((error as NodeJS.ErrnoException).code === "ENOENT") return null;
+      throw error;
+    });
+    if (stats?.isDirectory()) {
+      throw new Error(
+        `Storage file ${this.filePath} is a directory; set VECFS_FILE to a file path such as ${path.join(this.filePath, "vecfs-data.jsonl")}.`,
+      );
+    }
+    const created =
+      !stats &&
+      (await fs.writeFile(this.filePath, "", { flag: "wx" }).then(
+        () => true,
+        (error) => {
+          if ((error as NodeJS.ErrnoException).code === "EEXIST") return false;
+          throw error;
+        },
+      ));
+    this.initialized = true;
+    return created;
+  }
+
+  /**
+   * Reads the file as a log: a later record for an ID replaces an earlier
+   * one and a tombstone removes it. Lines may end in CRLF, as in files
+   * edited on Windows; the carriage return is dropped so it never reaches
+   * checksum checks. Malformed lines, and with `checksums` lines that fail
+   * their checksum, are skipped with a warning.
+   *
+   * @returns The last record for each ID still present, in file order.
+   */
+  async readLog(): Promise<VecFSEntry[]> {
+    await this.ensure();
+    const content = await fs.readFile(this.filePath, "utf-8");
+    const lines = content.trim().split(/\r?\n/);
+    const byId = new Map<string, VecFSEntry>();
+    for (const [index, line] of lines.entries()) {
+      if (!line) continue;
+      const { json, valid } = splitChecksum(line);
+      if (valid === false && this.options.checksums) {
+        console.warn(
+          `Skipping line ${index + 1} in ${this.filePath}: checksum mismatch`,
+        );
+        continue;
+      }
+      let record: VecFSEntry | Tombstone;
+      try {
+        record = JSON.parse(json);
+      } catch {
+        console.warn(`Skipping malformed line in ${this.filePath}`);
+        continue;
+      }
+      const field = this.options.vectorField;
+      if (field) record = renameKey(record, field, "vector");
+      if (isTombstone(record)) byId.delete(record.id);
+      else byId.set(record.id, record);
+    }
+    return [...byId.values()];
+  }
+
+  /**
+   * Appends records to the end of the file in a single write, syncing it
+   * to disk before returning when the `fsync` option is set.
+   */
+  async append(records: (VecFSEntry | Tombstone)[]): Promise<void> {
+    const content = records.map((r) => this.toLine(r) + "\n").join("");
+    if (!this.options.fsync) {
+      await fs.appendFile(this.filePath, content);
+      return;
+    }
+    const handle = await fs.open(this.filePath, "a");
+    try {
+      await handle.writeFile(content);
+      await handle.sync();
+    } finally {
+      await handle.close();
+    }
+  }
+
+  /**
+   * Replaces the file's content with `records` by writing a sibling
+   * temporary file and renaming it over the original, which is atomic on
+   * POSIX filesystems: a crash leaves either the old file or the new one,
+   * never a mix.
+   */
+  async rewrite(records: (VecFSEntry | Tombstone)[]): Promise<void> {
+    const content =
+      records.length > 0
+        ? records.map((r) => this.toLine(r)).join("\n") + "\n"
+        : "";
+    if (this.options.backups) await this.backUp(this.options.backups);
+    const tempPath = `${this.filePath}.tmp`;
+    const stats = await fs.stat(this.filePath).catch(() => null);
+    const mode = stats ? stats.mode & 0o777 : 0o644;
+    const handle = await fs.open(tempPath, "w", mode);
+    try {
+      await handle.writeFile(content);
+      await handle.chmod(mode);
+      if (this.options.fsync) await handle.sync();
+    } finally {
+      await handle.close();
+    }
+    try {
+      await this.moveIntoPlace(tempPath);
+    } catch (error) {
+      await fs.unlink(tempPath).catch(() => {});
+      throw error;
+    }
+  }
+
+  /** Fsyncs the file, if it has been created, so its content is on disk. */
+  async sync(): Promise<void> {
+    if (!this.initialized) return;
+    const handle = await fs.open(this.filePath, "r+");
+    try {
+      await handle.sync();
+    } finally {
+      await handle.close();
+    }
+  }
+
+  /**
+   * Serialises a record as one JSONL line, storing the vector under
+   * `vectorField` and adding a checksum if enabled.
+   */
+  private toLine(record: VecFSEntry | Tombstone): string {
+    const field = this.options.vectorField;
+    const json = JSON.stringify(
+      field ? renameKey(record, "vector", field) : record,
+    );
+    return this.options.checksums ? withChecksum(json) : json;
+  }
+
+  /**
+   * Copies the file to a backup named for the current time, then deletes
+   * all but the newest `keep` backups. The times are ISO 8601 in UTC, so
+   * backups sort by name in the order they were made. Nothing is copied
+   * while the file does not exist.
+   */
+  private async backUp(keep: number): Promise<void> {
+    const now = this.options.clock ? this.options.clock() : Date.now();
+    const stamp = new Date(now).toISOString().replace(/:/g, "-");
+    const backupPath = `${this.filePath}.${stamp}${BACKUP_EXTENSION}`;
+    try {
+      // Two rewrites in the same millisecond keep the older copy.
+      await fs.copyFile(this.filePath, backupPath, fsConstants.COPYFILE_EXCL);
+    } catch (error) {
+      const code = (error as NodeJS.ErrnoException).code;
+      if (code === "ENOENT") return;
+      if (code !== "EEXIST") throw error;
+    }
+    const backups = await this.listBackups();
+    for (const name of backups.slice(0, Math.max(0, backups.length - keep))) {
+      await fs.unlink(path.join(path.dirname(this.filePath), name));
+    }
+  }
+
+  /** Names of this file's backups, oldest first. */
+  private async listBackups(): Promise<string[]> {
+    const prefix = path.basename(this.filePath) + ".";
+    const names = await fs.readdir(path.dirname(this.filePath));
+    return names
+      .filter((n) => n.startsWith(prefix) && n.endsWith(BACKUP_EXTENSION))
+      .filter((n) => BACKUP_STAMP.test(n.slice(prefix.length)))
+      .sort();
+  }
+}
@@ -0,0 +1,125 @@
+import { SimilarityMetric } from "./types.js";
+import { EvictionPolicy } from "./retention.js";
+
+/**
+ * Options controlling how a {@link VecFSStorage} persists mutations.
+ */
+export interface StorageOptions {
+  /**
+   * Treat the file as a log: updates, score changes and deletes append a
+   * new record (or a tombstone) instead of rewriting the file. Loading
+   * reduces the log so the last record per ID wins. Use
+   * {@link VecFSStorage.compact} to reclaim space.
+   */
+  appendOnly?: boolean;
+  /**
+   * Delete by appending a tombstone record rather than rewriting the file,
+   * making deletes a constant-cost write. Implied by `appendOnly`.
+   */
+  tombstoneDeletes?: boolean;
+  /**
+   * Path to a JSON side file mapping dimension indices to terms, used to
+   * explain search hits in human terms. A missing file means no terms.
+   */
+  termsFile?: string;
+  /**
+   * Maximum number of entries scored per search. When set, an inverted
+   * index picks the entries sharing the most dimensions with the query and
+   * only those are scored, trading recall for speed on large stores.
+   */
+  candidateCap?: number;
+  /**
+   * Default similarity metric for searches: `cosine`, `dot`, `euclidean`
+   * or a name given to `registerSimilarity`. Use `dot` only when every
+   * stored and query vector is L2-normalised, as `vecfs-embed` produces;
+   * it then ranks identically to `cosine` without computing norms.
+   */
+  metric?: SimilarityMetric;
+  /**
+   * Coalesce score updates: hold changed scores in memory and write them
+   * at most once per this many milliseconds instead of on every call.
+   * Suits slow or network filesystems where each rewrite is expensive.
+   */
+  scoreFlushMs?: number;
+  /**
+   * Coalesce score updates and write them once this many entries have
+   * unsaved scores. Can be combined with `scoreFlushMs`.
+   */
+  scoreFlushThreshold?: number;
+  /**
+   * Apply every mutation to memory only and persist the whole store on
+   * {@link VecFSStorage.flush}, on {@link VecFSStorage.close} and, when
+   * `flushIntervalMs` is set, in the background. Much faster for large
+   * stores, but changes since the last flush are lost if the process dies.
+   */
+  batchWrites?: boolean;
+  /** How often batched writes are flushed in the background, in ms. */
+  flushIntervalMs?: number;
+  /**
+   * Call fsync after every write so a completed mutation survives a crash
+   * or power loss. Each write then waits for the disk, which is much
+   * slower; without it the OS may hold the last writes in its cache.
+   */
+  fsync?: boolean;
+  /**
+   * Write a short checksum after each JSONL record and verify it on load,
+   * skipping and reporting records that fail. Lines without a checksum
+   * are still accepted, and checksummed files load with this option off,
+   * but other JSONL tools will see the trailing checksum on each line.
+   */
+  checksums?: boolean;
+  /**
+   * Most entries the store may hold. Updates to existing entries are always
+   * allowed; what happens to a new entry at the cap depends on `eviction`.
+   */
+  maxEntries?: number;
+  /** Policy applied at `maxEntries`. Defaults to `reject`. */
+  eviction?: EvictionPolicy;
+  /**
+   * Make {@link VecFSStorage.delete} mark entries with `deletedAt` instead
+   * of removing them, keeping a record for auditing. Soft-deleted entries
+   * are hidden from search, listing and lookups until
+   * {@link VecFSStorage.purge} removes them.
+   */
+  softDeletes?: boolean;
+  /**
+   * JSON field name for the vector in the storage file, for interop with
+   * tools that expect e.g. `embedding`. Defaults to `vector`. Lines that
+   * still use `vector` are read either way, and are rewritten under the
+   * configured name the next time the file is rewritten.
+   */
+  vectorField?: string;
+  /**
+   * Source of the current time in milliseconds, used for timestamps,
+   * deletion times, access times and expiry. Defaults to `Date.now`; tests
+   * pass a fixed clock to make these deterministic.
+   */
+  clock?: () => number;
+  /**
+   * Most a feedback score can add to or take from an entry's rank, which
+   * is otherwise its similarity of at most 1. Defaults to
+   * {@link DEFAULT_FEEDBACK_WEIGHT}; raise it to let feedback outweigh
+   * larger differences in similarity.
+   */
+  feedbackWeight?: number;
+  /**
+   * Half-life in ms for feedback scores in ranking. An entry's score then
+   * counts half as much for every half-life since its timestamp, so
+   * entries that were popular long ago stop outranking fresher ones. The
+   * stored score is unchanged. Unset means scores never decay.
+   */
+  scoreHalfLifeMs?: number;
+  /**
+   * When an entry is replaced by `store`, keep up to this many earlier
+   * versions of it for auditing, listed by {@link VecFSStorage.versions}.
+   * Earlier versions are never searched. Unset keeps none.
+   */
+  keepVersions?: number;
+  /**
+   * Before each whole-file rewrite, copy the current file to a timestamped
+   * `<file>.<time>.bak` beside it, keeping only this many of the newest
+   * copies, so a bad compaction or delete can be undone by hand. Appends
+   * are not backed up. Unset keeps none.
+   */
+  backups?: number;
+}
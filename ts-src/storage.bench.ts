@@ -0,0 +1,67 @@
+import { bench, describe, beforeAll, afterAll } from "vitest";
+import { VecFSStorage } from "./storage.js";
+import { VecFSEntry } from "./types.js";
+import { seededRandom, randomSparseVector } from "./seeded-random.js";
+import * as fs from "fs/promises";
+import * as os from "os";
+import * as path from "path";
+
+for (const size of [10_000, 100_000]) {
+  describe(`search over ${size / 1000}k entries`, () => {
+    const filePath = path.join(
+      os.tmpdir(),
+      `vecfs-bench-${process.pid}-${size}.jsonl`,
+    );
+    const random = seededRandom(42);
+    const query = randomSparseVector(random, 30_000, 40);
+    let storage: VecFSStorage;
+
+    beforeAll(async () => {
+      const lines: string[] = [];
+      for (let i = 0; i < size; i++) {
+        const entry: VecFSEntry = {
+          id: `entry-${i}`,
+          vector: randomSparseVector(random, 30_000, 40),
+          metadata: {},
+          score: Math.floor(random() * 5),
+          timestamp: i,
+        };
+        lines.push(JSON.stringify(entry));
+      }
+      await fs.writeFile(filePath, lines.join("\n") + "\n");
+      storage = new VecFSStorage(filePath);
+      await storage.search(query);
+    });
+
+    afterAll(async () => {
+      await fs.unlink(filePath).catch(() => {});
+    });
+
+    bench("search limit 5", async () => {
+      await storage.search(query, 5);
+    });
+  });
+}
+
+describe("store with and without fsync", () => {
+  const base = path.join(os.tmpdir(), `vecfs-fsync-${process.pid}`);
+  const plain = new VecFSStorage(`${base}-plain.jsonl`);
+  const synced = new VecFSStorage(`${base}-synced.jsonl`, { fsync: true });
+  let next = 0;
+
+  afterAll(async () => {
+    for (const suffix of ["plain", "synced"]) {
+      await fs.unlink(`${base}-${suffix}.jsonl`).catch(() => {});
+    }
+  });
+
+  for (const [name, storage] of [
+    ["store", plain],
+    ["store with fsync", synced],
+  ] as const) {
+    bench(name, async () => {
+      const id = `entry-${next++}`;
+      await storage.store({ id, vector: { 0: 1 }, metadata: {}, score: 0 });
+    });
+  }
+});
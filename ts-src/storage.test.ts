@@ -1,5 +1,6 @@
 import { describe, it, expect, beforeEach, afterEach } from "vitest";
-import { VecFSStorage } from "./storage.js";
+import { VecFSStorage, MAX_LIST_LIMIT } from "./storage.js";
+import { fixedClock } from "./fixed-clock.js";
 import * as fs from "fs/promises";
 
 describe("VecFSStorage", () => {
@@ -17,12 +18,6 @@ describe("VecFSStorage", () => {
     } catch {}
   });
 
-  it("should ensure file exists", async () => {
-    const storage = new VecFSStorage(testFilePath);
-    await storage.ensureFile();
-    await expect(fs.access(testFilePath)).resolves.toBeUndefined();
-  });
-
   it("should store and search entries", async () => {
     const storage = new VecFSStorage(testFilePath);
     await storage.ensureFile();
@@ -60,45 +55,49 @@ describe("VecFSStorage", () => {
     expect(results[0].score).toBe(15);
   });
 
-  it("should sort search results by similarity", async () => {
+  it("should merge metadata updates over existing metadata", async () => {
     const storage = new VecFSStorage(testFilePath);
-    await storage.ensureFile();
-
-    await storage.store({ id: "1", vector: { 0: 1 }, metadata: {}, score: 0 });
+    const vector = { 0: 1, 3: 0.5 };
     await storage.store({
-      id: "2",
-      vector: { 0: 0.5 },
-      metadata: {},
-      score: 0,
+      id: "1",
+      vector,
+      metadata: { text: "note", tags: ["a"] },
+      score: 2,
     });
 
-    const results = await storage.search({ 0: 1 });
-    expect(results[0].id).toBe("1");
-    expect(results[1].id).toBe("2");
-  });
+    expect(await storage.updateMetadata("1", { tags: ["a", "b"], x: 1 })).toBe(
+      true,
+    );
 
-  it("should boost ranking by feedback score when similarity is equal", async () => {
-    const storage = new VecFSStorage(testFilePath);
-    await storage.ensureFile();
+    const reloaded = await new VecFSStorage(testFilePath).get("1");
+    expect(reloaded?.metadata).toEqual({
+      text: "note",
+      tags: ["a", "b"],
+      x: 1,
+    });
+    expect(reloaded?.vector).toEqual(vector);
+    expect(reloaded?.score).toBe(2);
+  });
 
-    const sameVector = { 0: 1, 1: 0 };
+  it("should replace metadata outright when asked", async () => {
+    const storage = new VecFSStorage(testFilePath, { appendOnly: true });
     await storage.store({
-      id: "low-feedback",
-      vector: sameVector,
-      metadata: {},
+      id: "1",
+      vector: { 0: 1 },
+      metadata: { text: "note", tags: ["a"] },
       score: 0,
     });
-    await storage.store({
-      id: "high-feedback",
-      vector: sameVector,
-      metadata: {},
-      score: 10,
-    });
 
-    const results = await storage.search({ 0: 1, 1: 0 });
-    expect(results).toHaveLength(2);
-    expect(results[0].id).toBe("high-feedback");
-    expect(results[1].id).toBe("low-feedback");
+    await storage.updateMetadata("1", { tags: ["b"] }, true);
+
+    const reloaded = await new VecFSStorage(testFilePath).get("1");
+    expect(reloaded?.metadata).toEqual({ tags: ["b"] });
+    expect(reloaded?.vector).toEqual({ 0: 1 });
+  });
+
+  it("should report a metadata update for a missing entry", async () => {
+    const storage = new VecFSStorage(testFilePath);
+    expect(await storage.updateMetadata("missing", { x: 1 })).toBe(false);
   });
 
   it("should return empty results for empty store", async () => {
@@ -144,32 +143,6 @@ describe("VecFSStorage", () => {
     expect(found).toBe(false);
   });
 
-  it("should delete an existing entry", async () => {
-    const storage = new VecFSStorage(testFilePath);
-    await storage.ensureFile();
-
-    await storage.store({
-      id: "to-delete",
-      vector: { 0: 1 },
-      metadata: {},
-      score: 0,
-    });
-
-    const deleted = await storage.delete("to-delete");
-    expect(deleted).toBe(true);
-
-    const results = await storage.search({ 0: 1 });
-    expect(results.find((r) => r.id === "to-delete")).toBeUndefined();
-  });
-
-  it("should return false when deleting nonexistent entry", async () => {
-    const storage = new VecFSStorage(testFilePath);
-    await storage.ensureFile();
-
-    const deleted = await storage.delete("nonexistent");
-    expect(deleted).toBe(false);
-  });
-
   it("should handle concurrent updateScore calls safely", async () => {
     const storage = new VecFSStorage(testFilePath);
     await storage.ensureFile();
@@ -192,29 +165,540 @@ describe("VecFSStorage", () => {
     expect(entry!.score).toBe(10);
   });
 
-  it("should persist data correctly after delete", async () => {
-    const storage = new VecFSStorage(testFilePath);
-    await storage.ensureFile();
+  describe("append-only mode", () => {
+    it("should append updates and tombstones instead of rewriting", async () => {
+      const storage = new VecFSStorage(testFilePath, { appendOnly: true });
+      await storage.ensureFile();
+
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await storage.store({
+        id: "b",
+        vector: { 1: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await storage.updateScore("a", 3);
+      await storage.delete("b");
+
+      const lines = (await fs.readFile(testFilePath, "utf-8"))
+        .trim()
+        .split("\n")
+        .map((l) => JSON.parse(l));
+      expect(lines).toHaveLength(4);
+      expect(lines[3]).toEqual({ id: "b", deleted: true });
+    });
 
-    await storage.store({
-      id: "keep",
+    it("should reduce a log with superseded records and tombstones on load", async () => {
+      const vector = { 0: 1 };
+      const log = [
+        { id: "a", vector, metadata: { v: 1 }, score: 0, timestamp: 1 },
+        { id: "b", vector, metadata: {}, score: 0, timestamp: 2 },
+        { id: "a", vector, metadata: { v: 2 }, score: 4, timestamp: 3 },
+        { id: "b", deleted: true },
+        { id: "c", vector, metadata: {}, score: 0, timestamp: 4 },
+      ];
+      await fs.writeFile(
+        testFilePath,
+        log.map((r) => JSON.stringify(r)).join("\n") + "\n",
+      );
+
+      const storage = new VecFSStorage(testFilePath, { appendOnly: true });
+      const results = await storage.search({ 0: 1 }, 10);
+
+      expect(results.map((r) => r.id).sort()).toEqual(["a", "c"]);
+      const a = results.find((r) => r.id === "a")!;
+      expect(a.metadata.v).toBe(2);
+      expect(a.score).toBe(4);
+    });
+
+    it("should reclaim space on compact", async () => {
+      const storage = new VecFSStorage(testFilePath, { appendOnly: true });
+      await storage.ensureFile();
+
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await storage.updateScore("a", 1);
+      await storage.updateScore("a", 1);
+      await storage.store({
+        id: "b",
+        vector: { 1: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await storage.delete("b");
+      await storage.compact();
+
+      const lines = (await fs.readFile(testFilePath, "utf-8"))
+        .trim()
+        .split("\n");
+      expect(lines).toHaveLength(1);
+      expect(JSON.parse(lines[0]).score).toBe(2);
+    });
+  });
+
+  describe("list", () => {
+    async function writeEntries(count: number) {
+      const lines = [];
+      for (let i = 0; i < count; i++) {
+        lines.push(
+          JSON.stringify({
+            id: `e${i}`,
+            vector: { 0: 1 },
+            metadata: {},
+            score: 0,
+            timestamp: 1000 + i,
+          }),
+        );
+      }
+      await fs.writeFile(testFilePath, lines.join("\n") + "\n");
+      return new VecFSStorage(testFilePath);
+    }
+
+    it("should return an empty page for an empty store", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.ensureFile();
+
+      expect(await storage.list()).toEqual({ entries: [], total: 0 });
+    });
+
+    it("should order entries by timestamp descending", async () => {
+      const storage = await writeEntries(5);
+
+      const page = await storage.list(1, 2);
+      expect(page.entries.map((e) => e.id)).toEqual(["e3", "e2"]);
+      expect(page.total).toBe(5);
+    });
+
+    it("should return an empty page for an offset beyond the end", async () => {
+      const storage = await writeEntries(3);
+
+      const page = await storage.list(10, 5);
+      expect(page.entries).toEqual([]);
+      expect(page.total).toBe(3);
+    });
+
+    it("should clamp the limit to the maximum page size", async () => {
+      const storage = await writeEntries(MAX_LIST_LIMIT + 5);
+
+      const page = await storage.list(0, 1000);
+      expect(page.entries).toHaveLength(MAX_LIST_LIMIT);
+      expect((await storage.list(0, -1)).entries).toEqual([]);
+    });
+
+    it("should keep file order when listing", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.ensureFile();
+      await storage.store({
+        id: "first",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await new Promise((resolve) => setTimeout(resolve, 5));
+      await storage.store({
+        id: "second",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+
+      await storage.list();
+      await storage.updateScore("first", 1);
+
+      const content = await fs.readFile(testFilePath, "utf-8");
+      const ids = content.trim().split("\n").map((l) => JSON.parse(l).id);
+      expect(ids).toEqual(["first", "second"]);
+    });
+  });
+
+  describe("matching", () => {
+    it("should return the live entries whose metadata matches", async () => {
+      let now = 1000;
+      const storage = new VecFSStorage(testFilePath, { clock: () => now });
+      await storage.ensureFile();
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: { tags: ["work", "go"] },
+        score: 0,
+      });
+      await storage.store({
+        id: "b",
+        vector: { 1: 1 },
+        metadata: { tags: ["home"] },
+        score: 0,
+      });
+      await storage.store({
+        id: "c",
+        vector: { 2: 1 },
+        metadata: { tags: ["work"] },
+        score: 0,
+        expiresAt: 2000,
+      });
+
+      const work = await storage.matching({ tags: "work" });
+      expect(work.map((e) => e.id).sort()).toEqual(["a", "c"]);
+
+      now = 2000;
+      const later = await storage.matching({ tags: "work" });
+      expect(later.map((e) => e.id)).toEqual(["a"]);
+    });
+  });
+
+  describe("get", () => {
+    it("should return the full entry when found", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.ensureFile();
+      await storage.store({
+        id: "g",
+        vector: { 2: 0.5 },
+        metadata: { text: "hello" },
+        score: 3,
+      });
+
+      const entry = await storage.get("g");
+      expect(entry).toMatchObject({
+        id: "g",
+        vector: { 2: 0.5 },
+        metadata: { text: "hello" },
+        score: 3,
+      });
+      expect(typeof entry?.timestamp).toBe("number");
+    });
+
+    it("should return undefined when not found", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.ensureFile();
+
+      expect(await storage.get("missing")).toBeUndefined();
+    });
+
+    it("should see complete entries during concurrent stores", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.ensureFile();
+
+      const ops = [];
+      for (let i = 0; i < 10; i++) {
+        ops.push(
+          storage.store({
+            id: `c${i}`,
+            vector: { 0: 1 },
+            metadata: {},
+            score: 0,
+          }),
+        );
+        ops.push(storage.get(`c${i}`));
+      }
+      const results = await Promise.all(ops);
+
+      // Each get is queued behind the store issued just before it.
+      for (let i = 1; i < results.length; i += 2) {
+        expect((results[i] as { id: string }).id).toBe(`c${(i - 1) / 2}`);
+      }
+    });
+  });
+
+  describe("term dictionary", () => {
+    const termsFile = "./test-storage.terms.json";
+
+    afterEach(async () => {
+      try {
+        await fs.unlink(termsFile);
+      } catch {}
+    });
+
+    it("should read terms from the side file", async () => {
+      await fs.writeFile(termsFile, JSON.stringify({ "12": "vector" }));
+      const storage = new VecFSStorage(testFilePath, { termsFile });
+
+      expect(await storage.termDictionary()).toEqual({ "12": "vector" });
+    });
+
+    it("should return no terms when the file is missing", async () => {
+      const storage = new VecFSStorage(testFilePath, { termsFile });
+
+      expect(await storage.termDictionary()).toEqual({});
+    });
+
+    it("should name the file when it is not valid JSON", async () => {
+      await fs.writeFile(termsFile, '{"12": "vector",}');
+      const storage = new VecFSStorage(testFilePath, { termsFile });
+
+      await expect(storage.termDictionary()).rejects.toThrow(
+        `Terms file ${termsFile} is not valid JSON`,
+      );
+    });
+
+    it("should reject a file that is not a term map", async () => {
+      await fs.writeFile(termsFile, JSON.stringify(["vector"]));
+      const storage = new VecFSStorage(testFilePath, { termsFile });
+
+      await expect(storage.termDictionary()).rejects.toThrow(
+        "must be a JSON object",
+      );
+    });
+  });
+
+  describe("concurrent access", () => {
+    it("should load the file once for concurrent first reads", async () => {
+      const writer = new VecFSStorage(testFilePath, { keepVersions: 5 });
+      await writer.store({ id: "a", vector: { 1: 1 }, metadata: {}, score: 0 });
+      await writer.store({ id: "a", vector: { 1: 2 }, metadata: {}, score: 0 });
+      await writer.close();
+
+      const storage = new VecFSStorage(testFilePath, { keepVersions: 5 });
+      await Promise.all([
+        storage.search({ 1: 1 }),
+        storage.search({ 1: 1 }),
+        storage.count(),
+      ]);
+      expect((await storage.versions("a")).map((v) => v.version)).toEqual([
+        2, 1,
+      ]);
+    });
+
+    it("should see whole feedback updates while searching", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.store({
+        id: "a",
+        vector: { 1: 1 },
+        metadata: {},
+        score: 0,
+      });
+
+      const seen: number[] = [];
+      const calls: Promise<unknown>[] = [];
+      for (let i = 0; i < 200; i++) {
+        calls.push(storage.updateScore("a", 1));
+        calls.push(
+          storage.search({ 1: 1 }).then(([hit]) => seen.push(hit.score)),
+        );
+      }
+      await Promise.all(calls);
+
+      expect(seen.every((score) => Number.isInteger(score))).toBe(true);
+      expect(seen).toEqual([...seen].sort((x, y) => x - y));
+      expect((await storage.get("a"))?.score).toBe(200);
+      const reloaded = new VecFSStorage(testFilePath);
+      expect((await reloaded.get("a"))?.score).toBe(200);
+    });
+
+    it("should not let callers change the stored entry", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      await storage.store({
+        id: "a",
+        vector: { 1: 1 },
+        metadata: {},
+        score: 0,
+      });
+
+      const entry = await storage.get("a");
+      entry!.score = 99;
+      const { entries } = await storage.list();
+      entries[0].score = 99;
+      expect((await storage.get("a"))?.score).toBe(0);
+    });
+  });
+
+  describe("compact", () => {
+    it("should keep one line per ID from a messy file", async () => {
+      const line = (id: string, v: number, timestamp: number) =>
+        JSON.stringify({
+          id,
+          vector: { 0: 1 },
+          metadata: { v },
+          score: 0,
+          timestamp,
+        });
+      const lines = [
+        line("a", 1, 1),
+        "{not json",
+        line("b", 1, 2),
+        line("a", 2, 3),
+        line("c", 1, 4),
+        JSON.stringify({ id: "c", deleted: true }),
+        line("b", 3, 5),
+      ];
+      await fs.writeFile(testFilePath, lines.join("\n") + "\n");
+
+      const storage = new VecFSStorage(testFilePath);
+      await storage.compact();
+
+      const records = (await fs.readFile(testFilePath, "utf-8"))
+        .trim()
+        .split("\n")
+        .map((l) => JSON.parse(l));
+      expect(records.map((r) => r.id).sort()).toEqual(["a", "b"]);
+      expect(records.find((r) => r.id === "a").metadata.v).toBe(2);
+      expect(records.find((r) => r.id === "b").metadata.v).toBe(3);
+      await expect(fs.access(`${testFilePath}.tmp`)).rejects.toThrow();
+    });
+  });
+
+  describe("storeBatch", () => {
+    /** Counts whole-file rewrites so tests can assert a single write. */
+    class CountingStorage extends VecFSStorage {
+      rewrites = 0;
+      protected async moveIntoPlace(tempPath: string): Promise<void> {
+        this.rewrites++;
+        await super.moveIntoPlace(tempPath);
+      }
+    }
+
+    async function readIds() {
+      return (await fs.readFile(testFilePath, "utf-8"))
+        .trim()
+        .split("\n")
+        .map((l) => JSON.parse(l).id);
+    }
+
+    const entry = (id: string, score = 0) => ({
+      id,
       vector: { 0: 1 },
       metadata: {},
-      score: 0,
+      score,
     });
-    await storage.store({
-      id: "remove",
-      vector: { 1: 1 },
-      metadata: {},
-      score: 0,
+
+    it("should append new entries in one write", async () => {
+      const storage = new CountingStorage(testFilePath);
+      await storage.store(entry("a"));
+
+      expect(await storage.storeBatch([entry("b"), entry("c")])).toBe(2);
+      expect(storage.rewrites).toBe(0);
+      expect(await readIds()).toEqual(["a", "b", "c"]);
     });
-    await storage.delete("remove");
 
-    // Read from a fresh instance to verify file persistence
-    const storage2 = new VecFSStorage(testFilePath);
-    const results = await storage2.search({ 0: 1 }, 10);
+    it("should upsert new and existing entries in one rewrite", async () => {
+      const storage = new CountingStorage(testFilePath);
+      await storage.store(entry("a"));
+      await storage.store(entry("b"));
+
+      const created = await storage.storeBatch([
+        entry("b", 5),
+        entry("c"),
+        entry("c", 7),
+      ]);
+      expect(created).toBe(1);
+      expect(storage.rewrites).toBe(1);
+      expect(await readIds()).toEqual(["a", "b", "c"]);
+
+      const reopened = new VecFSStorage(testFilePath);
+      expect((await reopened.get("b"))?.score).toBe(5);
+      expect((await reopened.get("c"))?.score).toBe(7);
+    });
+
+    it("should store nothing when the batch overflows the cap", async () => {
+      const storage = new VecFSStorage(testFilePath, { maxEntries: 2 });
+      await storage.store(entry("a"));
 
-    expect(results.find((r) => r.id === "keep")).toBeDefined();
-    expect(results.find((r) => r.id === "remove")).toBeUndefined();
+      await expect(
+        storage.storeBatch([entry("b"), entry("c")]),
+      ).rejects.toThrow("Storage is full");
+      expect(await storage.get("b")).toBeUndefined();
+      expect(await readIds()).toEqual(["a"]);
+    });
+  });
+
+  describe("count", () => {
+    it("should count stored entries after a delete", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      for (const id of ["a", "b", "c"]) {
+        await storage.store({ id, vector: { 0: 1 }, metadata: {}, score: 0 });
+      }
+      await storage.delete("b");
+
+      expect(await storage.count()).toBe(2);
+    });
+
+    it("should load the file on first use", async () => {
+      const seed = new VecFSStorage(testFilePath);
+      await seed.store({ id: "a", vector: { 0: 1 }, metadata: {}, score: 0 });
+
+      expect(await new VecFSStorage(testFilePath).count()).toBe(1);
+    });
+
+    it("should return 0 for an empty store", async () => {
+      expect(await new VecFSStorage(testFilePath).count()).toBe(0);
+    });
+  });
+
+  describe("metadataKeys", () => {
+    it("should skip the keys of expired entries", async () => {
+      const clock = fixedClock(1_000_000);
+      const storage = new VecFSStorage(testFilePath, { clock });
+      await storage.store({
+        id: "scratch",
+        vector: { 0: 1 },
+        metadata: { temp: 1, kept: "y" },
+        score: 0,
+        expiresAt: clock.ms + 1000,
+      });
+      await storage.store({
+        id: "lasting",
+        vector: { 0: 1 },
+        metadata: { kept: "x" },
+        score: 0,
+      });
+
+      const before = await storage.metadataKeys();
+      expect(before.temp).toEqual({ count: 1, types: ["number"] });
+      expect(before.kept.count).toBe(2);
+
+      clock.ms += 1000;
+
+      const after = await storage.metadataKeys();
+      expect(after).toEqual({ kept: { count: 1, types: ["string"] } });
+    });
+  });
+
+  describe("clock", () => {
+    it("should stamp entries with the configured clock", async () => {
+      const clock = fixedClock(1_700_000_000_000);
+      const storage = new VecFSStorage(testFilePath, { clock });
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      clock.ms += 250;
+      await storage.store({
+        id: "b",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+
+      expect((await storage.get("a"))?.timestamp).toBe(1_700_000_000_000);
+      expect((await storage.get("b"))?.timestamp).toBe(1_700_000_000_250);
+    });
+
+    it("should stamp soft deletes with the configured clock", async () => {
+      const clock = fixedClock(5000);
+      const storage = new VecFSStorage(testFilePath, {
+        clock,
+        softDeletes: true,
+      });
+      await storage.store({
+        id: "a",
+        vector: { 0: 1 },
+        metadata: {},
+        score: 0,
+      });
+      await storage.delete("a");
+      clock.ms += 1000;
+
+      expect(await storage.purge(1001)).toBe(0);
+      expect(await storage.purge(1000)).toBe(1);
+    });
   });
 });
@@ -1,95 +1,238 @@
 import * as fs from "fs/promises";
-import * as path from "path";
-import { VecFSEntry, SparseVector, SearchResult } from "./types.js";
-import { cosineSimilarity, norm } from "./sparse-vector.js";
+import {
+  VecFSEntry,
+  SparseVector,
+  SearchResult,
+  SearchOptions,
+  Tombstone,
+  EntryPage,
+  SearchPage,
+  MetadataFilter,
+  Embedder,
+  ReindexCounts,
+} from "./types.js";
 import { Mutex } from "./file-mutex.js";
+import { summarizeMetadataKeys, MetadataKeySummary } from "./facets.js";
+import { TermDictionary, readTermDictionary } from "./terms.js";
+import { InvertedIndex, buildInvertedIndex } from "./inverted-index.js";
+import { StorageOptions } from "./storage-options.js";
+import { StorageFile } from "./storage-file.js";
+import { PendingWrites } from "./pending-writes.js";
+import {
+  RankedResult,
+  bestOf,
+  matchesFilter,
+  scoreEntries,
+  searchCandidates,
+} from "./ranking.js";
+import {
+  VersionHistory,
+  checkEntryId,
+  evictionIndex,
+  isExpired,
+} from "./retention.js";
+import {
+  EntryState,
+  deleteEntry,
+  purgeSoftDeleted,
+  renameEntry,
+  sweepExpired,
+} from "./lifecycle.js";
+import { REINDEX_BATCH_SIZE, reembed } from "./reindex.js";
 
-/** Weight for feedback score in ranking (bounded boost so similarity stays dominant). */
-const FEEDBACK_RANK_WEIGHT = 0.1;
+/** Number of results returned by a search when no limit is given. */
+export const DEFAULT_SEARCH_LIMIT = 5;
 
-/**
- * Bounded contribution of reinforcement score to ranking.
- * Maps score to approximately (-WEIGHT, +WEIGHT) so one very high score cannot overwhelm similarity.
- */
-function feedbackBoost(score: number): number {
-  return FEEDBACK_RANK_WEIGHT * (score / (1 + Math.abs(score)));
-}
+/** Number of entries returned by a listing when no limit is given. */
+export const DEFAULT_LIST_LIMIT = 20;
 
-/**
- * Combined rank for sorting: cosine similarity plus feedback boost.
- * Higher is better; used so positively reinforced entries rise in search results.
- */
-function combinedRank(r: { similarity: number; score: number }): number {
-  return r.similarity + feedbackBoost(r.score);
-}
+/** Largest page a listing will return; larger limits are clamped to this. */
+export const MAX_LIST_LIMIT = 100;
 
 /**
  * Manages the storage and retrieval of vector entries from a local JSONL file.
  *
  * Entries are cached in memory after the first read. All mutations update
  * the cache synchronously and then persist to disk under a mutex so that
- * concurrent operations cannot interleave file writes.
+ * concurrent operations cannot interleave file writes. With `batchWrites`
+ * the disk write is deferred to the next flush instead.
  */
 export class VecFSStorage {
   private filePath: string;
+  private options: StorageOptions;
+  private file: StorageFile;
   private entries: VecFSEntry[] | null = null;
-  private initialized = false;
+  /** The first load of the file, while it is in progress. */
+  private loading: Promise<VecFSEntry[]> | null = null;
+  private softDeleted = new Map<string, VecFSEntry>();
+  private history = new VersionHistory();
+  private index: InvertedIndex | null = null;
   private mutex = new Mutex();
+  private pending: PendingWrites;
+  private lastAccess = new Map<string, number>();
 
-  constructor(filePath: string) {
+  /**
+   * Creates a store backed by `filePath`; the file is read on first use.
+   *
+   * @throws Error if `options.vectorField` names another record field.
+   */
+  constructor(filePath: string, options: StorageOptions = {}) {
+    this.file = new StorageFile(filePath, options, (tempPath) =>
+      this.moveIntoPlace(tempPath),
+    );
+    this.pending = new PendingWrites(options, () => {
+      this.flush().catch((error) => {
+        console.error(`Failed to flush ${this.filePath}:`, error);
+      });
+    });
     this.filePath = filePath;
+    this.options = options;
   }
 
   /**
-   * Ensures the storage file and its parent directory exist.
-   * Safe to call multiple times; only performs I/O on the first invocation.
+   * The current time in milliseconds from the configured clock, so callers
+   * can compute times such as expiries consistently with the store.
    */
-  async ensureFile(): Promise<void> {
-    if (this.initialized) return;
-    const dir = path.dirname(this.filePath);
-    await fs.mkdir(dir, { recursive: true });
-    try {
-      await fs.access(this.filePath);
-    } catch {
-      await fs.writeFile(this.filePath, "");
-    }
-    this.initialized = true;
+  now(): number {
+    return this.options.clock ? this.options.clock() : Date.now();
+  }
+
+  /** The cached state that deletes and renames change, over `entries`. */
+  private state(entries: VecFSEntry[]): EntryState {
+    return {
+      entries,
+      softDeleted: this.softDeleted,
+      lastAccess: this.lastAccess,
+    };
+  }
+
+  /** The loaded entries that have not expired. */
+  private async liveEntries(): Promise<VecFSEntry[]> {
+    const now = this.now();
+    return (await this.loadEntries()).filter((e) => !isExpired(e, now));
+  }
+
+  /**
+   * Ensures the storage file and its parent directory exist, as described
+   * for {@link StorageFile.ensure}.
+   *
+   * @returns true if this call created the file.
+   * @throws Error if the configured path exists but is a directory.
+   */
+  async ensureFile(): Promise<boolean> {
+    return this.file.ensure();
   }
 
   /**
    * Lazily loads all entries from the file into the in-memory cache.
    * Subsequent calls return the cached array without re-reading the file.
+   *
+   * The file is read as a log (see {@link StorageFile.readLog}).
+   * Soft-deleted entries and earlier versions are held apart so that every
+   * read of the cache sees live entries only.
+   *
+   * Searches read without the write lock, so the first load can be asked
+   * for by several calls at once. They share one read of the file; two
+   * reads could otherwise each fill the version history, or a slow read
+   * could replace a cache that a write has already updated.
    */
-  private async loadEntries(): Promise<VecFSEntry[]> {
-    if (this.entries !== null) return this.entries;
-    await this.ensureFile();
-    const content = await fs.readFile(this.filePath, "utf-8");
-    const lines = content.trim().split("\n");
+  private loadEntries(): Promise<VecFSEntry[]> {
+    if (this.entries !== null) return Promise.resolve(this.entries);
+    if (!this.loading) {
+      this.loading = this.readEntries().finally(() => {
+        this.loading = null;
+      });
+    }
+    return this.loading;
+  }
+
+  /** Reads the file into the cache for {@link loadEntries}. */
+  private async readEntries(): Promise<VecFSEntry[]> {
+    const records = await this.file.readLog();
     this.entries = [];
-    for (const line of lines) {
-      if (!line) continue;
-      try {
-        this.entries.push(JSON.parse(line));
-      } catch {
-        console.warn(`Skipping malformed line in ${this.filePath}`);
+    for (const entry of records) {
+      if (entry.versionOf !== undefined) {
+        this.history.load({ ...entry, versionOf: entry.versionOf });
+      } else if (entry.deletedAt === undefined) {
+        this.entries.push(entry);
+      } else {
+        this.softDeleted.set(entry.id, entry);
       }
     }
     return this.entries;
   }
 
-  /** Rewrites the entire file from the in-memory cache. */
+  /**
+   * Rewrites the entire file from the in-memory cache, atomically, so a
+   * crash part-way through never leaves a truncated store.
+   */
   private async persistAll(): Promise<void> {
+    this.index = null;
+    this.pending.clear();
     if (!this.entries) return;
-    const content =
-      this.entries.length > 0
-        ? this.entries.map((e) => JSON.stringify(e)).join("\n") + "\n"
-        : "";
-    await fs.writeFile(this.filePath, content);
+    await this.file.rewrite([
+      ...this.entries,
+      ...this.softDeleted.values(),
+      ...this.history.records(),
+    ]);
   }
 
-  /** Appends a single entry to the end of the file. */
-  private async persistAppend(entry: VecFSEntry): Promise<void> {
-    await fs.appendFile(this.filePath, JSON.stringify(entry) + "\n");
+  /**
+   * Renames the fully written temporary file over the store. Separate so
+   * tests can simulate a crash between writing and renaming.
+   */
+  protected async moveIntoPlace(tempPath: string): Promise<void> {
+    await fs.rename(tempPath, this.filePath);
+  }
+
+  /** Appends records to the end of the file in a single write. */
+  private async persistAppend(
+    ...records: (VecFSEntry | Tombstone)[]
+  ): Promise<void> {
+    this.index = null;
+    await this.file.append(records);
+  }
+
+  /**
+   * Persists a change to an existing entry: appends the record in
+   * append-only mode, otherwise rewrites the file.
+   */
+  private async persistChange(record: VecFSEntry | Tombstone): Promise<void> {
+    if (this.options.appendOnly) await this.persistAppend(record);
+    else await this.persistAll();
+  }
+
+  /**
+   * Enforces `maxEntries` before a new entry is added, evicting one entry
+   * from the cache if the policy allows.
+   *
+   * @returns The evicted entry's ID, or undefined if there was room.
+   * @throws Error if the store is full and the policy is `reject`.
+   */
+  private makeRoom(entries: VecFSEntry[]): string | undefined {
+    const lastUsed = (e: VecFSEntry) =>
+      this.lastAccess.get(e.id) ?? e.timestamp;
+    const index = evictionIndex(entries, this.options, lastUsed);
+    if (index === undefined) return;
+    const [victim] = entries.splice(index, 1);
+    this.lastAccess.delete(victim.id);
+    this.index = null;
+    return victim.id;
+  }
+
+  /** Whether deletes are recorded by appending a tombstone. */
+  private appendsDeletes(): boolean {
+    return !!(this.options.appendOnly || this.options.tombstoneDeletes);
+  }
+
+  /**
+   * Runs `write` now, or with `batchWrites` set, records that the file is
+   * out of date and leaves the write to the next flush.
+   */
+  private async persistOrDefer(write: () => Promise<void>): Promise<void> {
+    if (!this.options.batchWrites) return write();
+    this.index = null;
+    this.pending.deferRewrite();
   }
 
   /**
@@ -97,66 +240,370 @@ export class VecFSStorage {
    * replaced (upsert semantics), otherwise the entry is appended.
    *
    * @returns true if a new entry was created, false if an existing entry was updated.
+   * @throws Error if the ID has the form reserved for earlier versions.
    */
   async store(entry: Omit<VecFSEntry, "timestamp">): Promise<boolean> {
+    checkEntryId(entry.id);
     const release = await this.mutex.acquire();
     try {
       const entries = await this.loadEntries();
-      const fullEntry: VecFSEntry = { ...entry, timestamp: Date.now() };
+      const fullEntry: VecFSEntry = { ...entry, timestamp: this.now() };
+      const revived = this.softDeleted.has(entry.id);
       const existingIndex = entries.findIndex((e) => e.id === entry.id);
       if (existingIndex >= 0) {
+        this.softDeleted.delete(entry.id);
+        const retained = this.history.retain(
+          entries[existingIndex],
+          this.options.keepVersions,
+        );
         entries[existingIndex] = fullEntry;
-        await this.persistAll();
+        await this.persistOrDefer(() =>
+          retained.length > 0 && this.options.appendOnly
+            ? this.persistAppend(...retained, fullEntry)
+            : this.persistChange(fullEntry),
+        );
         return false;
       }
+      // Only forget a soft-deleted record once there is room to revive it.
+      const evicted = this.makeRoom(entries);
+      this.softDeleted.delete(entry.id);
       entries.push(fullEntry);
-      await this.persistAppend(fullEntry);
+      await this.persistOrDefer(async () => {
+        // A revived soft-deleted entry replaces its old line, like an update.
+        if (revived && !this.options.appendOnly) return this.persistAll();
+        if (evicted && !this.appendsDeletes()) return this.persistAll();
+        if (evicted) await this.persistAppend({ id: evicted, deleted: true });
+        await this.persistAppend(fullEntry);
+      });
       return true;
     } finally {
       release();
     }
   }
 
+  /**
+   * Stores several entries with a single file write: one append when every
+   * entry is new (or in append-only mode), otherwise one rewrite. Each
+   * entry is an upsert as in {@link store}; a later entry in the batch
+   * with the same ID replaces an earlier one.
+   *
+   * @returns The number of entries that were newly created.
+   * @throws Error if `maxEntries` is reached under the `reject` policy, or
+   *         an ID has the form reserved for earlier versions, in which
+   *         case nothing from the batch is stored.
+   */
+  async storeBatch(batch: Omit<VecFSEntry, "timestamp">[]): Promise<number> {
+    for (const entry of batch) checkEntryId(entry.id);
+    const release = await this.mutex.acquire();
+    try {
+      const entries = await this.loadEntries();
+      const snapshot = [...entries];
+      const softDeleted = new Map(this.softDeleted);
+      const lastAccess = new Map(this.lastAccess);
+      const records: (VecFSEntry | Tombstone)[] = [];
+      const previous: VecFSEntry[] = [];
+      let created = 0;
+      let replaced = false;
+      let evicted = false;
+      try {
+        const timestamp = this.now();
+        for (const entry of batch) {
+          const fullEntry: VecFSEntry = { ...entry, timestamp };
+          if (this.softDeleted.delete(entry.id)) replaced = true;
+          const existingIndex = entries.findIndex((e) => e.id === entry.id);
+          if (existingIndex >= 0) {
+            previous.push(entries[existingIndex]);
+            entries[existingIndex] = fullEntry;
+            replaced = true;
+          } else {
+            const evictedId = this.makeRoom(entries);
+            if (evictedId) {
+              records.push({ id: evictedId, deleted: true });
+              evicted = true;
+            }
+            entries.push(fullEntry);
+            created++;
+          }
+          records.push(fullEntry);
+        }
+      } catch (error) {
+        entries.splice(0, entries.length, ...snapshot);
+        this.softDeleted = softDeleted;
+        this.lastAccess = lastAccess;
+        throw error;
+      }
+      const keep = this.options.keepVersions;
+      records.unshift(...previous.flatMap((e) => this.history.retain(e, keep)));
+      const rewrite =
+        !this.options.appendOnly &&
+        (replaced || (evicted && !this.appendsDeletes()));
+      await this.persistOrDefer(() =>
+        rewrite ? this.persistAll() : this.persistAppend(...records),
+      );
+      return created;
+    } finally {
+      release();
+    }
+  }
+
+  /**
+   * Scores every entry against the query vector and sorts the full set,
+   * as described for {@link scoreEntries}. When `options.filter` is set,
+   * entries whose metadata does not match are dropped before scoring, as
+   * are entries stored outside `options.after`/`options.before`. With
+   * a `candidateCap`, only the capped candidate set is scored and returned.
+   * Equal ranks are ordered by id, or with `options.recencyTiebreak`, ranks
+   * within a small epsilon of each other are ordered newest first.
+   *
+   * @param queryVector - The sparse vector to search for.
+   * @param options - Optional ranking controls.
+   * @returns All scored entries sorted by descending combined rank.
+   */
+  async rank(
+    queryVector: SparseVector,
+    options: SearchOptions = {},
+  ): Promise<SearchResult[]> {
+    const scored = await this.score(queryVector, options);
+    return bestOf(scored, scored.length, options.recencyTiebreak).map(
+      (r) => r.result,
+    );
+  }
+
+  /** Scores the candidate entries for {@link rank}, in no particular order. */
+  private async score(
+    queryVector: SparseVector,
+    options: SearchOptions,
+  ): Promise<RankedResult[]> {
+    const entries = await this.loadEntries();
+    const now = this.now();
+    const index = () => {
+      if (!this.index) this.index = buildInvertedIndex(entries);
+      return this.index;
+    };
+    const { candidateCap, metric, feedbackWeight, scoreHalfLifeMs } =
+      this.options;
+    const candidates = searchCandidates(
+      entries,
+      queryVector,
+      options,
+      now,
+      candidateCap,
+      index,
+    );
+    return scoreEntries(candidates, queryVector, options, {
+      metric,
+      feedbackWeight,
+      scoreHalfLifeMs,
+      now,
+    });
+  }
+
   /**
    * Searches the store for entries most similar to the query vector.
-   * Ranking combines cosine similarity with the reinforcement score so that
-   * positively reinforced context is prioritized (per requirements).
-   * Pre-computes the query norm once to avoid redundant calculations.
+   * Only the best `limit` results are kept while scoring, so a small limit
+   * avoids sorting the whole store.
    *
    * @param queryVector - The sparse vector to search for.
    * @param limit - Maximum number of results. Defaults to 5.
-   * @returns Search results sorted by descending combined score (similarity + feedback boost).
+   * @param options - Optional ranking controls (see {@link rank}).
+   * @returns Search results sorted by descending combined rank.
    */
   async search(
     queryVector: SparseVector,
-    limit: number = 5,
+    limit: number = DEFAULT_SEARCH_LIMIT,
+    options: SearchOptions = {},
   ): Promise<SearchResult[]> {
-    const entries = await this.loadEntries();
-    const queryNorm = norm(queryVector);
+    const best = await this.best(queryVector, limit, options);
+    return this.recordAccess(best.map((r) => r.result));
+  }
+
+  /**
+   * The best `limit` results of a search together with their ranks, in
+   * final order, so results from several stores can be merged with
+   * {@link mergeRanked}. Access times are not recorded.
+   */
+  async best(
+    queryVector: SparseVector,
+    limit: number,
+    options: SearchOptions = {},
+  ): Promise<RankedResult[]> {
+    const scored = await this.score(queryVector, options);
+    return bestOf(scored, limit, options.recencyTiebreak);
+  }
+
+  /**
+   * One page of a search: the results ranked `offset + 1` to
+   * `offset + limit`, and how many entries the search ranked in all.
+   * Pages of the same search never overlap or leave gaps, and an offset
+   * past the last result gives an empty page.
+   *
+   * @param offset - Number of ranked results to skip. Negative values
+   *   count as 0.
+   */
+  async searchPage(
+    queryVector: SparseVector,
+    offset: number,
+    limit: number = DEFAULT_SEARCH_LIMIT,
+    options: SearchOptions = {},
+  ): Promise<SearchPage> {
+    const scored = await this.score(queryVector, options);
+    const total = scored.length;
+    const start = Math.max(offset, 0);
+    const best = bestOf(scored, start + limit, options.recencyTiebreak);
+    const results = best.slice(start).map((r) => r.result);
+    return { results: this.recordAccess(results), total };
+  }
+
+  /**
+   * Notes that results were returned to a client, for `lru` eviction.
+   * Access times live only in memory, so after a restart entries fall back
+   * to their stored timestamp.
+   *
+   * @returns The results, for chaining.
+   */
+  recordAccess<T extends { id: string }>(results: T[]): T[] {
+    const now = this.now();
+    for (const { id } of results) this.lastAccess.set(id, now);
+    return results;
+  }
+
+  /**
+   * Fetches a single entry by its exact ID.
+   * Holds the write lock so a concurrent store is never half-read.
+   *
+   * @returns A snapshot of the entry, or undefined if no live entry has
+   *   that ID. Writes replace an entry's fields rather than changing them
+   *   in place, so the snapshot does not change under the caller.
+   */
+  async get(id: string): Promise<VecFSEntry | undefined> {
+    const release = await this.mutex.acquire();
+    try {
+      const entries = await this.liveEntries();
+      const entry = entries.find((e) => e.id === id);
+      return entry && { ...entry };
+    } finally {
+      release();
+    }
+  }
+
+  /**
+   * Fetches every live entry whose metadata matches the filter, as
+   * snapshots in the same way as {@link get}. Array-valued metadata such
+   * as tags matches when it contains the filter's value.
+   */
+  async matching(filter: MetadataFilter): Promise<VecFSEntry[]> {
+    const release = await this.mutex.acquire();
+    try {
+      const entries = await this.liveEntries();
+      return entries
+        .filter((e) => matchesFilter(e, filter))
+        .map((e) => ({ ...e }));
+    } finally {
+      release();
+    }
+  }
 
-    const results: SearchResult[] = entries.map((entry) => ({
-      ...entry,
-      similarity: cosineSimilarity(queryVector, entry.vector, queryNorm),
-    }));
+  /**
+   * Counts the unexpired entries, loading the file first if needed. Holds
+   * the write lock so the count never reflects a half-applied mutation.
+   */
+  async count(): Promise<number> {
+    const release = await this.mutex.acquire();
+    try {
+      return (await this.liveEntries()).length;
+    } finally {
+      release();
+    }
+  }
 
-    return results
-      .sort((a, b) => combinedRank(b) - combinedRank(a))
-      .slice(0, limit);
+  /**
+   * Lists stored entries without a query, most recently modified first.
+   * Entries with equal timestamps are ordered by id so pages are stable.
+   * Expired entries are skipped, and each listed entry is a snapshot, as
+   * from {@link get}.
+   *
+   * @param offset - Number of entries to skip. Negative values count as 0.
+   * @param limit - Page size, clamped to between 0 and {@link MAX_LIST_LIMIT}.
+   * @returns The requested page and the total number of entries.
+   */
+  async list(
+    offset: number = 0,
+    limit: number = DEFAULT_LIST_LIMIT,
+  ): Promise<EntryPage> {
+    const entries = (await this.liveEntries()).sort(
+      (a, b) => b.timestamp - a.timestamp || a.id.localeCompare(b.id),
+    );
+    const start = Math.max(offset, 0);
+    const size = Math.min(Math.max(limit, 0), MAX_LIST_LIMIT);
+    return {
+      entries: entries.slice(start, start + size).map((e) => ({ ...e })),
+      total: entries.length,
+    };
+  }
+
+  /**
+   * Lists the versions of an entry, newest first: the current entry, if
+   * it exists, then the earlier versions kept under `keepVersions`. Each
+   * carries the entry's ID and a `version` number, counting from 1 for the
+   * oldest kept version; the current entry has the highest.
+   *
+   * @returns The versions, or an empty array if the ID has none.
+   */
+  async versions(id: string): Promise<VecFSEntry[]> {
+    const release = await this.mutex.acquire();
+    try {
+      const current = (await this.liveEntries()).find((e) => e.id === id);
+      const earlier = this.history.earlier(id);
+      const latest = (earlier[earlier.length - 1]?.version ?? 0) + 1;
+      if (current) earlier.push({ ...current, version: latest });
+      return earlier.reverse();
+    } finally {
+      release();
+    }
   }
 
   /**
    * Adjusts the reinforcement score of an entry.
    *
    * @returns true if the entry was found and updated, false otherwise.
+   *          An expired entry counts as not found.
    */
   async updateScore(id: string, scoreAdjustment: number): Promise<boolean> {
     const release = await this.mutex.acquire();
     try {
-      const entries = await this.loadEntries();
-      const entry = entries.find((e) => e.id === id);
+      const entry = (await this.liveEntries()).find((e) => e.id === id);
       if (!entry) return false;
       entry.score += scoreAdjustment;
-      await this.persistAll();
+      if (this.options.batchWrites || !this.pending.coalescesScores()) {
+        await this.persistOrDefer(() => this.persistChange(entry));
+      } else if (this.pending.deferScore(entry)) {
+        await this.persistScores();
+      }
+      return true;
+    } finally {
+      release();
+    }
+  }
+
+  /**
+   * Changes an entry's metadata without touching its vector, score or
+   * timestamp. By default `patch` is merged over the existing metadata,
+   * overwriting keys it shares; with `replace` it becomes the metadata.
+   *
+   * @returns true if the entry was found and updated, false otherwise.
+   *          An expired entry counts as not found.
+   */
+  async updateMetadata(
+    id: string,
+    patch: Record<string, unknown>,
+    replace = false,
+  ): Promise<boolean> {
+    const release = await this.mutex.acquire();
+    try {
+      const entry = (await this.liveEntries()).find((e) => e.id === id);
+      if (!entry) return false;
+      entry.metadata = replace ? { ...patch } : { ...entry.metadata, ...patch };
+      await this.persistOrDefer(() => this.persistChange(entry));
       return true;
     } finally {
       release();
@@ -164,21 +611,244 @@ export class VecFSStorage {
   }
 
   /**
-   * Removes an entry by ID.
+   * Writes buffered scores in one operation: a single append of the
+   * changed entries in append-only mode, otherwise one file rewrite.
+   * Entries deleted or replaced since their score changed are skipped.
+   */
+  private async persistScores(): Promise<void> {
+    const scores = this.pending.takeScores();
+    if (scores.length === 0 || !this.entries) return;
+    if (!this.options.appendOnly) return this.persistAll();
+    const live = new Set(this.entries);
+    const records = scores.filter((e) => live.has(e));
+    if (records.length > 0) await this.persistAppend(...records);
+  }
+
+  /**
+   * Writes any changes still held in memory by `batchWrites` or score
+   * coalescing. A no-op when nothing is pending.
+   */
+  async flush(): Promise<void> {
+    const release = await this.mutex.acquire();
+    try {
+      if (this.pending.rewritePending()) await this.persistAll();
+      await this.persistScores();
+    } finally {
+      release();
+    }
+  }
+
+  /**
+   * Flushes pending writes, then fsyncs the storage file so everything
+   * written so far is on disk. Call before the process exits.
+   *
+   * Without the `fsync` option, individual writes return once the OS has
+   * the data, so a crash or power loss can drop the most recent ones;
+   * `close` narrows that window to writes made after it. With `fsync`,
+   * every write is durable when it returns, at a large cost per write.
+   */
+  async close(): Promise<void> {
+    await this.flush();
+    await this.file.sync();
+  }
+
+  /**
+   * Removes an entry by ID. The file is rewritten unless tombstone deletes
+   * or append-only mode are enabled, in which case a tombstone is appended.
+   *
+   * With `softDeletes`, the entry is instead kept with `deletedAt` set and
+   * hidden from reads, unless `options.hard` is true. A hard delete also
+   * removes an entry that was already soft-deleted.
    *
    * @returns true if the entry was found and deleted, false otherwise.
+   *          An expired entry counts as not found; `sweep` removes it.
    */
-  async delete(id: string): Promise<boolean> {
+  async delete(
+    id: string,
+    options: { hard?: boolean } = {},
+  ): Promise<boolean> {
     const release = await this.mutex.acquire();
     try {
       const entries = await this.loadEntries();
-      const index = entries.findIndex((e) => e.id === id);
-      if (index < 0) return false;
-      entries.splice(index, 1);
-      await this.persistAll();
+      const soft = !!this.options.softDeletes && !options.hard;
+      const deletion = deleteEntry(this.state(entries), id, soft, this.now());
+      if (!deletion) return false;
+      await this.persistOrDefer(() =>
+        "marked" in deletion
+          ? this.persistChange(deletion.marked)
+          : this.removeRecords([deletion.removed]),
+      );
+      return true;
+    } finally {
+      release();
+    }
+  }
+
+  /**
+   * Persists the removal of entries already dropped from the cache: by
+   * appending tombstones when deletes are appended, otherwise by a rewrite.
+   */
+  private async removeRecords(ids: string[]): Promise<void> {
+    if (!this.appendsDeletes()) return this.persistAll();
+    const tombstones = ids.map((id): Tombstone => ({ id, deleted: true }));
+    await this.persistAppend(...tombstones);
+  }
+
+  /**
+   * Removes expired entries from the store and persists the change, by
+   * appending tombstones when deletes are appended or otherwise a rewrite.
+   *
+   * @returns The number of entries removed.
+   */
+  async sweep(): Promise<number> {
+    const release = await this.mutex.acquire();
+    try {
+      const entries = await this.loadEntries();
+      const expired = sweepExpired(this.state(entries), this.now());
+      if (expired.length === 0) return 0;
+      await this.persistOrDefer(() => this.removeRecords(expired));
+      return expired.length;
+    } finally {
+      release();
+    }
+  }
+
+  /**
+   * Permanently removes soft-deleted entries whose `deletedAt` is at least
+   * `olderThanMs` milliseconds ago. Use 0 to remove all of them.
+   *
+   * @returns The number of entries purged.
+   */
+  async purge(olderThanMs: number): Promise<number> {
+    const release = await this.mutex.acquire();
+    try {
+      await this.loadEntries();
+      const cutoff = this.now() - olderThanMs;
+      const purged = purgeSoftDeleted(this.softDeleted, cutoff);
+      if (purged.length === 0) return 0;
+      await this.persistOrDefer(() => this.removeRecords(purged));
+      return purged.length;
+    } finally {
+      release();
+    }
+  }
+
+  /**
+   * Changes the ID of an entry without re-embedding it. The vector, score,
+   * metadata and timestamp are preserved. An expired entry under `newId`
+   * is replaced, as `store` would replace it.
+   *
+   * @returns true if the entry was renamed, false if `oldId` was not found
+   *          or has expired.
+   * @throws Error if an entry with `newId` already exists, including a
+   *   soft-deleted one, which would otherwise be lost, or if `newId` has
+   *   the form reserved for earlier versions.
+   */
+  async rename(oldId: string, newId: string): Promise<boolean> {
+    checkEntryId(newId);
+    const release = await this.mutex.acquire();
+    try {
+      const entries = await this.loadEntries();
+      const entry = renameEntry(
+        this.state(entries),
+        oldId,
+        newId,
+        this.now(),
+      );
+      if (!entry) return false;
+      if (oldId === newId) return true;
+      await this.persistOrDefer(async () => {
+        if (this.options.appendOnly) {
+          await this.persistAppend(entry);
+          await this.persistAppend({ id: oldId, deleted: true });
+        } else {
+          await this.persistAll();
+        }
+      });
       return true;
     } finally {
       release();
     }
   }
+
+  /**
+   * Rewrites the file so it holds exactly one line per live entry. Records
+   * superseded by a later line for the same ID, tombstones and malformed
+   * lines are dropped. Like every rewrite, the new file replaces the old
+   * one atomically, so an interrupted compaction leaves the original intact.
+   */
+  async compact(): Promise<void> {
+    const release = await this.mutex.acquire();
+    try {
+      await this.loadEntries();
+      await this.persistAll();
+    } finally {
+      release();
+    }
+  }
+
+  /**
+   * Recomputes the vector of every live entry from the text kept in its
+   * `text` metadata, for when the embedding model changes. IDs, metadata,
+   * scores and timestamps are kept, no earlier versions are recorded, and
+   * the store is written once at the end. Entries with no stored text keep
+   * their old vector. Texts are embedded in batches, as described for
+   * {@link reembed}. Holds the write lock while embedding, so other calls
+   * wait until the reindex is done.
+   *
+   * @param embed - Embeds a batch of texts, returning vectors in order.
+   * @param batchSize - Most texts passed to `embed` in one call.
+   * @throws Error if `embed` fails or returns a different number of
+   *   vectors than texts, in which case nothing is changed.
+   */
+  async reindex(
+    embed: Embedder,
+    batchSize: number = REINDEX_BATCH_SIZE,
+  ): Promise<ReindexCounts> {
+    const release = await this.mutex.acquire();
+    try {
+      const entries = await this.loadEntries();
+      const { positions, vectors, skipped } = await reembed(
+        entries,
+        this.now(),
+        embed,
+        batchSize,
+      );
+      if (positions.length === 0) return { reindexed: 0, skipped };
+      positions.forEach((position, i) => {
+        entries[position] = { ...entries[position], vector: vectors[i] };
+      });
+      await this.persistOrDefer(() => this.persistAll());
+      return { reindexed: positions.length, skipped };
+    } finally {
+      release();
+    }
+  }
+
+  /**
+   * Reads the dimension-to-term dictionary from the configured side file.
+   *
+   * @returns The dictionary, or an empty one if no file is configured or
+   *          it does not exist.
+   * @throws Error naming the file if it is not valid JSON or not a JSON
+   *   object of strings.
+   */
+  async termDictionary(): Promise<TermDictionary> {
+    const termsFile = this.options.termsFile;
+    return termsFile ? readTermDictionary(termsFile) : {};
+  }
+
+  /**
+   * Lists the distinct metadata keys across all entries, with occurrence
+   * counts and value types, so clients can discover what to filter on.
+   * Expired entries are skipped.
+   */
+  async metadataKeys(): Promise<Record<string, MetadataKeySummary>> {
+    const release = await this.mutex.acquire();
+    try {
+      return summarizeMetadataKeys(await this.liveEntries());
+    } finally {
+      release();
+    }
+  }
 }
@@ -0,0 +1,39 @@
+import { describe, it, expect } from "vitest";
+import { topContributions } from "./terms.js";
+
+describe("topContributions", () => {
+  const terms = { "1": "sparse", "2": "vector", "3": "storage" };
+
+  it("should rank shared dimensions by contribution and name them", () => {
+    const query = { 1: 1, 2: 2, 3: 1 };
+    const entry = { 1: 1, 2: 1, 3: 3 };
+
+    const result = topContributions(query, entry, terms);
+    expect(result.map((c) => c.term)).toEqual(["storage", "vector", "sparse"]);
+  });
+
+  it("should sum to the cosine similarity", () => {
+    const query = { 1: 1, 2: 1 };
+    const entry = { 1: 1, 2: 1, 9: 1 };
+
+    const total = topContributions(query, entry, terms).reduce(
+      (sum, c) => sum + c.contribution,
+      0,
+    );
+    expect(total).toBeCloseTo(2 / (Math.sqrt(2) * Math.sqrt(3)));
+  });
+
+  it("should leave the term out for unknown dimensions", () => {
+    const [only] = topContributions({ 7: 1 }, { 7: 1 }, terms);
+    expect(only).toEqual({ dimension: 7, contribution: 1 });
+  });
+
+  it("should skip negative contributions and respect the count", () => {
+    const query = { 1: 1, 2: 1, 3: 1 };
+    const entry = { 1: 1, 2: -1, 3: 0.5 };
+
+    const result = topContributions(query, entry, terms, 1);
+    expect(result).toHaveLength(1);
+    expect(result[0].term).toBe("sparse");
+  });
+});
@@ -0,0 +1,99 @@
+import * as fs from "fs/promises";
+import { SparseVector } from "./types.js";
+import { norm } from "./sparse-vector.js";
+
+/**
+ * Maps vector dimensions to the human-readable terms they represent, as
+ * produced by learned-sparse models such as SPLADE. Keys are dimension
+ * indices in string form.
+ */
+export type TermDictionary = Record<string, string>;
+
+/** How much one dimension contributed to a similarity score. */
+export interface TermContribution {
+  /** The dimension index. */
+  dimension: number;
+  /** The term for the dimension, when the dictionary has one. */
+  term?: string;
+  /** This dimension's share of the cosine similarity. */
+  contribution: number;
+}
+
+/** Number of dimensions reported per hit when explaining a search. */
+export const DEFAULT_EXPLAIN_TERMS = 5;
+
+/**
+ * Lists the dimensions that contributed most to the cosine similarity of
+ * two vectors, largest first. Contributions are `q[d] * v[d] / (|q| |v|)`,
+ * so across all shared dimensions they sum to the similarity. Dimensions
+ * that lowered the similarity are left out.
+ *
+ * @param query - The query vector.
+ * @param vector - The matched entry's vector.
+ * @param terms - Dictionary used to name dimensions.
+ * @param count - Maximum number of dimensions to return.
+ */
+export function topContributions(
+  query: SparseVector,
+  vector: SparseVector,
+  terms: TermDictionary = {},
+  count: number = DEFAULT_EXPLAIN_TERMS,
+): TermContribution[] {
+  const scale = norm(query) * norm(vector);
+  if (scale === 0) return [];
+
+  const contributions: TermContribution[] = [];
+  for (const key of Object.keys(query)) {
+    const dimension = Number(key);
+    const weight = vector[dimension];
+    if (weight === undefined) continue;
+    const contribution = (query[dimension] * weight) / scale;
+    if (contribution <= 0) continue;
+    const term = terms[key];
+    contributions.push(
+      term === undefined
+        ? { dimension, contribution }
+        : { dimension, term, contribution },
+    );
+  }
+  return contributions
+    .sort((a, b) => b.contribution - a.contribution)
+    .slice(0, count);
+}
+
+/**
+ * Reads a dimension-to-term dictionary from a JSON side file.
+ *
+ * @returns The dictionary, or an empty one if the file does not exist.
+ * @throws Error naming the file if it is not valid JSON or not a JSON
+ *   object of strings.
+ */
+export async function readTermDictionary(
+  termsFile: string,
+): Promise<TermDictionary> {
+  const content = await fs.readFile(termsFile, "utf-8").catch((error) => {
+    if ((error as NodeJS.ErrnoException).code === "ENOENT") return null;
+    throw error;
+  });
+  if (content === null) return {};
+  let terms: TermDictionary;
+  try {
+    terms = JSON.parse(content);
+  } catch (error) {
+    throw new Error(
+      `Terms file ${termsFile} is not valid JSON: ` +
+        (error as Error).message,
+    );
+  }
+  if (
+    terms === null ||
+    typeof terms !== "object" ||
+    Array.isArray(terms) ||
+    !Object.values(terms).every((t) => typeof t === "string")
+  ) {
+    throw new Error(
+      `Terms file ${termsFile} must be a JSON object of dimension to term.`,
+    );
+  }
+  return terms;
+}
@@ -0,0 +1,79 @@
+import { z } from "zod";
+import { toSparse } from "./sparse-vector.js";
+import { SparseVector } from "./types.js";
+import { RpcError, INVALID_PARAMS_CODE } from "./rpc-errors.js";
+import { NAMESPACE_PATTERN } from "./shards.js";
+
+/** A vector argument: a sparse object or a dense array of numbers. */
+export const vectorShapeSchema = z.union([
+  z.record(z.string(), z.number()),
+  z.array(z.number()),
+]);
+
+/**
+ * If args is an object with a string `vector` property, parses it as JSON
+ * so the vector is sent as object/array. MCP clients may send vector as a
+ * JSON string; this ensures we accept both.
+ */
+export function ensureVectorIsObjectOrArray(
+  args: unknown,
+): unknown {
+  if (args === null || typeof args !== "object" || !("vector" in args))
+    return args;
+  const v = (args as Record<string, unknown>).vector;
+  if (typeof v !== "string") return args;
+  try {
+    return { ...(args as Record<string, unknown>), vector: JSON.parse(v) };
+  } catch {
+    throw new Error(
+      "Vector must be a JSON object (sparse) or array of numbers (dense).",
+    );
+  }
+}
+
+export const namespaceSchema = z.string().regex(NAMESPACE_PATTERN);
+
+/**
+ * Metadata filter: an object of scalar values, also accepted as a JSON
+ * string for clients that stringify nested arguments (as with `vector`).
+ */
+export const filterSchema = z.preprocess(
+  (v) => (typeof v === "string" ? JSON.parse(v) : v),
+  z.record(z.string(), z.union([z.string(), z.number(), z.boolean()])),
+);
+
+/**
+ * Parses and validates tool arguments with a zod schema.
+ * Throws a descriptive invalid-params {@link RpcError} on failure.
+ */
+export function validateArgs<T>(
+  schema: { parse: (data: unknown) => T },
+  args: unknown,
+  toolName: string,
+): T {
+  try {
+    return schema.parse(args ?? {});
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    throw new RpcError(
+      INVALID_PARAMS_CODE,
+      `Invalid arguments for '${toolName}': ${message}`,
+    );
+  }
+}
+
+/**
+ * Normalises a vector input (dense array or sparse object) into a SparseVector.
+ */
+export function normalizeVector(
+  input: Record<string, number> | number[],
+): SparseVector {
+  if (Array.isArray(input)) {
+    return toSparse(input);
+  }
+  const sparse: SparseVector = {};
+  for (const [key, value] of Object.entries(input)) {
+    sparse[Number(key)] = value;
+  }
+  return sparse;
+}
@@ -0,0 +1,126 @@
+import { VecFSStorage } from "./storage.js";
+import { ToolCallContext } from "./tool-timeout.js";
+import { RpcError, INVALID_PARAMS_CODE } from "./rpc-errors.js";
+import { ShardedStorage } from "./shards.js";
+import { IdempotencyCache } from "./idempotency.js";
+
+/**
+ * The shape returned by every tool handler, compatible with the MCP SDK's
+ * expected CallToolResult format.
+ */
+export interface ToolResult {
+  [key: string]: unknown;
+  content: { type: string; text: string }[];
+}
+
+/**
+ * How `memorize` keeps the original text in an entry's metadata.
+ * The vector is what gets searched, so the text is only for display.
+ */
+export type StoreTextMode = "full" | "truncated" | "none";
+
+/** Default character limit for {@link StoreTextMode} `truncated`. */
+export const DEFAULT_STORE_TEXT_MAX_CHARS = 200;
+
+/**
+ * Options that shape tool behaviour and responses.
+ */
+export interface ToolHandlerOptions {
+  /**
+   * Text returned by `search` when nothing matches. When unset an empty
+   * JSON array is returned.
+   */
+  emptyResultMessage?: string;
+  /** How memorized text is kept in metadata. Defaults to `full`. */
+  storeText?: StoreTextMode;
+  /** Character limit applied when `storeText` is `truncated`. */
+  storeTextMaxChars?: number;
+  /**
+   * Maximum size in bytes of a result-listing response. Results that do
+   * not fit are dropped and the response is flagged `truncated: true`.
+   */
+  maxResponseBytes?: number;
+  /**
+   * Size in bytes above which `search` splits its results over several
+   * content items, each a page marked with `page` and `total_pages`, for
+   * clients that cut off a single large content block.
+   */
+  chunkBytes?: number;
+  /**
+   * Per-namespace stores. When set, tools that take a `namespace` act on
+   * that namespace's store instead of the default one, and `search` can
+   * fan out over several namespaces.
+   */
+  shards?: ShardedStorage;
+  /**
+   * How long `memorize` remembers an `idempotencyKey`, in milliseconds.
+   * Defaults to ten minutes.
+   */
+  idempotencyTtlMs?: number;
+}
+
+/**
+ * A map of tool-name to handler function. The optional context is updated
+ * with the handler's current stage so that timeouts can report it.
+ */
+export type ToolHandlerMap = Record<
+  string,
+  (args: unknown, ctx?: ToolCallContext) => Promise<ToolResult>
+>;
+
+/** What every group of tool handlers is built from. */
+export interface ToolContext {
+  /** The default store. */
+  storage: VecFSStorage;
+  options: ToolHandlerOptions;
+  /**
+   * The per-namespace stores, for calls that name a namespace.
+   *
+   * @throws RpcError if namespaces are not enabled.
+   */
+  shards(): ShardedStorage;
+  /** The store a tool call acts on: its namespace's, or the default. */
+  storageFor(namespace?: string): Promise<VecFSStorage>;
+  /** Results of recent keyed `memorize` calls, replayed on retry. */
+  memorized: IdempotencyCache<ToolResult>;
+}
+
+/** Builds the {@link ToolContext} shared by one set of tool handlers. */
+export function createToolContext(
+  storage: VecFSStorage,
+  options: ToolHandlerOptions,
+): ToolContext {
+  function shards(): ShardedStorage {
+    if (!options.shards) {
+      throw new RpcError(
+        INVALID_PARAMS_CODE,
+        "Namespaces are not enabled; set VECFS_SHARD_DIR to use them.",
+      );
+    }
+    return options.shards;
+  }
+
+  async function storageFor(namespace?: string): Promise<VecFSStorage> {
+    return namespace === undefined ? storage : shards().shard(namespace);
+  }
+
+  const memorized = new IdempotencyCache<ToolResult>(
+    options.idempotencyTtlMs,
+    () => storage.now(),
+  );
+
+  return { storage, options, shards, storageFor, memorized };
+}
+
+/**
+ * Records the stage a handler has reached, when a context is provided.
+ * Throws the abort reason instead if the call has already timed out.
+ */
+export function enterStage(
+  ctx: ToolCallContext | undefined,
+  stage: string,
+): void {
+  if (!ctx) return;
+  ctx.signal?.throwIfAborted();
+  ctx.stage = stage;
+}
@@ -0,0 +1,923 @@
+import { describe, it, expect, beforeEach, afterEach } from "vitest";
+import { VecFSStorage } from "./storage.js";
+import { createToolHandlers } from "./tool-handlers.js";
+import { ToolHandlerMap, ToolHandlerOptions } from "./tool-context.js";
+import { RpcError, INVALID_PARAMS_CODE } from "./rpc-errors.js";
+import { ShardedStorage } from "./shards.js";
+import { cosineSimilarity } from "./sparse-vector.js";
+import * as fs from "fs/promises";
+import * as os from "os";
+import * as path from "path";
+
+describe("tool handlers", () => {
+  const testFilePath = "./test-tool-handlers.jsonl";
+  let handlers: ToolHandlerMap;
+
+  beforeEach(async () => {
+    try {
+      await fs.unlink(testFilePath);
+    } catch {}
+    const storage = new VecFSStorage(testFilePath);
+    await storage.ensureFile();
+    handlers = createToolHandlers(storage);
+  });
+
+  afterEach(async () => {
+    try {
+      await fs.unlink(testFilePath);
+    } catch {}
+  });
+
+  /** Parses the JSON body of the first content item. */
+  function parseText(result: { content: { text: string }[] }): any {
+    return JSON.parse(result.content[0].text);
+  }
+
+  describe("search", () => {
+    it("should count facet values across all scored entries", async () => {
+      const sources = ["slack", "slack", "email", "wiki", "wiki", "wiki"];
+      for (let i = 0; i < sources.length; i++) {
+        await handlers.memorize({
+          id: `entry-${i}`,
+          vector: { "0": 1, [i + 1]: 0.1 },
+          metadata: { source: sources[i] },
+        });
+      }
+
+      const result = await handlers.search({
+        vector: { "0": 1 },
+        limit: 2,
+        facet: "source",
+      });
+      const body = parseText(result);
+
+      expect(body.results).toHaveLength(2);
+      expect(body.facets).toEqual({ slack: 2, email: 1, wiki: 3 });
+    });
+
+    it("should return a plain array when no facet is requested", async () => {
+      await handlers.memorize({ id: "a", vector: { "0": 1 } });
+
+      const body = parseText(await handlers.search({ vector: { "0": 1 } }));
+      expect(Array.isArray(body)).toBe(true);
+      expect(body[0].id).toBe("a");
+    });
+  });
+
+  describe("search offset", () => {
+    beforeEach(async () => {
+      for (let i = 0; i < 12; i++) {
+        await handlers.memorize({
+          id: `entry-${i}`,
+          vector: { "0": 1, [i + 1]: i / 10 },
+        });
+      }
+    });
+
+    it("should page through results with the total", async () => {
+      const ids: string[] = [];
+      for (const offset of [0, 5, 10]) {
+        const body = parseText(
+          await handlers.search({ vector: { "0": 1 }, limit: 5, offset }),
+        );
+        expect(body.total).toBe(12);
+        expect(body.offset).toBe(offset);
+        ids.push(...body.results.map((r: { id: string }) => r.id));
+      }
+      expect(ids).toHaveLength(12);
+      expect(new Set(ids).size).toBe(12);
+    });
+
+    it("should number hits from offset + 1", async () => {
+      for (const offset of [0, 5, 10]) {
+        const body = parseText(
+          await handlers.search({ vector: { "0": 1 }, limit: 5, offset }),
+        );
+        const positions = body.results.map(
+          (r: { position: number }) => r.position,
+        );
+        const expected = Array.from(
+          { length: Math.min(5, 12 - offset) },
+          (_, i) => offset + i + 1,
+        );
+        expect(positions).toEqual(expected);
+      }
+      const plain = parseText(
+        await handlers.search({ vector: { "0": 1 }, limit: 3 }),
+      );
+      expect(plain.map((r: { position: number }) => r.position)).toEqual([
+        1, 2, 3,
+      ]);
+    });
+
+    it("should return an empty page past the end", async () => {
+      const body = parseText(
+        await handlers.search({ vector: { "0": 1 }, offset: 50 }),
+      );
+      expect(body).toEqual({ results: [], total: 12, offset: 50 });
+    });
+
+    it("should page faceted results", async () => {
+      const body = parseText(
+        await handlers.search({
+          vector: { "0": 1 },
+          limit: 5,
+          offset: 10,
+          facet: "missing",
+        }),
+      );
+      expect(body.results).toHaveLength(2);
+      expect(body.total).toBe(12);
+    });
+  });
+
+  describe("empty search results", () => {
+    it("should return an empty array by default", async () => {
+      const result = await handlers.search({ vector: { "0": 1 } });
+      expect(parseText(result)).toEqual([]);
+    });
+
+    it("should return the configured message when set", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      const withMessage = createToolHandlers(storage, {
+        emptyResultMessage: "No matching entries found.",
+      });
+
+      const result = await withMessage.search({ vector: { "0": 1 } });
+      expect(result.content[0].text).toBe("No matching entries found.");
+    });
+  });
+
+  describe("metadata_keys", () => {
+    it("should list keys with counts and value types", async () => {
+      await handlers.memorize({
+        id: "a",
+        text: "first",
+        vector: { "0": 1 },
+        metadata: { source: "slack", priority: 1 },
+      });
+      await handlers.memorize({
+        id: "b",
+        vector: { "1": 1 },
+        metadata: { source: "wiki", tags: ["x"] },
+      });
+      await handlers.memorize({
+        id: "c",
+        vector: { "2": 1 },
+        metadata: { priority: "high" },
+      });
+
+      const keys = parseText(await handlers.metadata_keys({}));
+      expect(keys).toEqual({
+        priority: { count: 2, types: ["number", "string"] },
+        source: { count: 2, types: ["string"] },
+        tags: { count: 1, types: ["array"] },
+        text: { count: 1, types: ["string"] },
+      });
+    });
+
+    it("should return an empty object for an empty store", async () => {
+      expect(parseText(await handlers.metadata_keys({}))).toEqual({});
+    });
+  });
+
+  describe("memorize text storage", () => {
+    const longText = "a".repeat(30);
+
+    async function storedText(options: ToolHandlerOptions) {
+      const storage = new VecFSStorage(testFilePath);
+      const configured = createToolHandlers(storage, options);
+      await configured.memorize({
+        id: "t",
+        text: longText,
+        vector: { "0": 1 },
+        metadata: { source: "test" },
+      });
+      const [hit] = await storage.search({ 0: 1 }, 1);
+      return hit.metadata;
+    }
+
+    it("should keep the full text by default", async () => {
+      const metadata = await storedText({});
+      expect(metadata.text).toBe(longText);
+      expect(metadata.source).toBe("test");
+    });
+
+    it("should truncate the text with an ellipsis", async () => {
+      const metadata = await storedText({
+        storeText: "truncated",
+        storeTextMaxChars: 10,
+      });
+      expect(metadata.text).toBe("a".repeat(10) + "…");
+    });
+
+    it("should leave short text untouched when truncating", async () => {
+      const metadata = await storedText({
+        storeText: "truncated",
+        storeTextMaxChars: 100,
+      });
+      expect(metadata.text).toBe(longText);
+    });
+
+    it("should omit the text entirely", async () => {
+      const metadata = await storedText({ storeText: "none" });
+      expect(metadata.text).toBeUndefined();
+      expect(metadata.source).toBe("test");
+    });
+  });
+
+  describe("similarity_histogram", () => {
+    it("should bucket every entry so counts sum to the store size", async () => {
+      const vectors = [
+        { "0": 1 },
+        { "0": 1, "1": 1 },
+        { "0": 1, "1": 3 },
+        { "1": 1 },
+        { "2": 1 },
+      ];
+      for (let i = 0; i < vectors.length; i++) {
+        await handlers.memorize({ id: `h-${i}`, vector: vectors[i] });
+      }
+
+      const histogram = parseText(
+        await handlers.similarity_histogram({
+          vector: { "0": 1 },
+          buckets: 4,
+        }),
+      );
+
+      expect(histogram).toHaveLength(4);
+      const total = histogram.reduce((sum: number, b: any) => sum + b.count, 0);
+      expect(total).toBe(vectors.length);
+      // similarities: 1, 0.707, 0.316, 0, 0
+      expect(histogram.map((b: any) => b.count)).toEqual([2, 1, 1, 1]);
+    });
+  });
+
+  describe("centroid", () => {
+    beforeEach(async () => {
+      const group = { topic: "storage" };
+      await handlers.memorize({
+        id: "left",
+        vector: { "0": 1 },
+        metadata: group,
+      });
+      await handlers.memorize({
+        id: "middle",
+        vector: { "0": 1, "1": 1 },
+        metadata: group,
+      });
+      await handlers.memorize({
+        id: "right",
+        vector: { "1": 1 },
+        metadata: group,
+      });
+      await handlers.memorize({ id: "other", vector: { "5": 1 } });
+    });
+
+    it("should rank the most central entry nearest", async () => {
+      const body = parseText(
+        await handlers.centroid({ ids: ["left", "middle", "right"] }),
+      );
+
+      expect(body.count).toBe(3);
+      expect(body.centroid["0"]).toBeCloseTo(2 / 3);
+      expect(body.centroid["1"]).toBeCloseTo(2 / 3);
+      expect(body.nearest[0].id).toBe("middle");
+    });
+
+    it("should count a repeated ID once", async () => {
+      const body = parseText(
+        await handlers.centroid({ ids: ["left", "left", "left", "right"] }),
+      );
+
+      expect(body.count).toBe(2);
+      expect(body.centroid["0"]).toBeCloseTo(1 / 2);
+      expect(body.centroid["1"]).toBeCloseTo(1 / 2);
+    });
+
+    it("should average the entries matching a filter", async () => {
+      const body = parseText(
+        await handlers.centroid({ filter: { topic: "storage" }, limit: 2 }),
+      );
+
+      expect(body.count).toBe(3);
+      expect(body.centroid).not.toHaveProperty("5");
+      expect(body.nearest.map((r: any) => r.id)).not.toContain("other");
+      expect(body.nearest).toHaveLength(2);
+    });
+
+    it("should report a missing entry", async () => {
+      const result = await handlers.centroid({ ids: ["left", "nope"] });
+      expect(result.content[0].text).toBe("Entry not found: nope");
+    });
+
+    it("should report a filter that matches nothing", async () => {
+      const result = await handlers.centroid({ filter: { topic: "none" } });
+      expect(result.content[0].text).toBe("No entries match the filter.");
+    });
+
+    it("should need exactly one of ids and filter", async () => {
+      await expect(handlers.centroid({})).rejects.toThrow("exactly one");
+      await expect(
+        handlers.centroid({ ids: ["left"], filter: { topic: "storage" } }),
+      ).rejects.toThrow("exactly one");
+    });
+  });
+
+  describe("response size cap", () => {
+    async function storeLargeEntries(count: number) {
+      for (let i = 0; i < count; i++) {
+        await handlers.memorize({
+          id: `big-${i}`,
+          text: "x".repeat(1000),
+          vector: { "0": 1, [i + 1]: 0.01 },
+        });
+      }
+    }
+
+    it("should truncate search results to fit and flag it", async () => {
+      await storeLargeEntries(10);
+      const storage = new VecFSStorage(testFilePath);
+      const capped = createToolHandlers(storage, { maxResponseBytes: 4000 });
+
+      const result = await capped.search({ vector: { "0": 1 }, limit: 10 });
+      const text = result.content[0].text;
+      const body = JSON.parse(text);
+
+      expect(Buffer.byteLength(text)).toBeLessThanOrEqual(4000);
+      expect(body.truncated).toBe(true);
+      expect(body.results.length).toBeGreaterThan(0);
+      expect(body.results.length).toBeLessThan(10);
+    });
+
+    it("should leave responses under the cap unchanged", async () => {
+      await storeLargeEntries(2);
+      const storage = new VecFSStorage(testFilePath);
+      const capped = createToolHandlers(storage, {
+        maxResponseBytes: 1_000_000,
+      });
+
+      const body = parseText(
+        await capped.search({ vector: { "0": 1 }, limit: 10 }),
+      );
+      expect(Array.isArray(body)).toBe(true);
+      expect(body).toHaveLength(2);
+    });
+
+    it("should split large search results into pages", async () => {
+      await storeLargeEntries(20);
+      const storage = new VecFSStorage(testFilePath);
+      const chunked = createToolHandlers(storage, { chunkBytes: 5000 });
+
+      const result = await chunked.search({ vector: { "0": 1 }, limit: 20 });
+      const pages = result.content.map((c) => JSON.parse(c.text));
+
+      expect(pages.length).toBeGreaterThan(1);
+      for (const [i, page] of pages.entries()) {
+        expect(page.page).toBe(i + 1);
+        expect(page.total_pages).toBe(pages.length);
+      }
+      for (const c of result.content) {
+        expect(Buffer.byteLength(c.text)).toBeLessThanOrEqual(5000);
+      }
+      const ids = pages.flatMap((p) => p.results.map((r: any) => r.id));
+      const whole = parseText(
+        await handlers.search({ vector: { "0": 1 }, limit: 20 }),
+      );
+      expect(ids).toEqual(whole.map((r: any) => r.id));
+    });
+
+    it("should keep small results in one block when chunking", async () => {
+      await storeLargeEntries(2);
+      const storage = new VecFSStorage(testFilePath);
+      const chunked = createToolHandlers(storage, { chunkBytes: 1_000_000 });
+
+      const result = await chunked.search({ vector: { "0": 1 } });
+      expect(result.content).toHaveLength(1);
+      expect(parseText(result)).toHaveLength(2);
+    });
+  });
+
+  describe("list", () => {
+    it("should return a page with the total count", async () => {
+      for (const id of ["a", "b", "c"]) {
+        await handlers.memorize({ id, vector: { "0": 1 } });
+      }
+
+      const body = parseText(await handlers.list({ offset: 1, limit: 1 }));
+      expect(body.entries).toHaveLength(1);
+      expect(body.total).toBe(3);
+      expect(body.offset).toBe(1);
+    });
+
+    it("should accept missing arguments", async () => {
+      const body = parseText(await handlers.list(undefined));
+      expect(body).toEqual({ entries: [], total: 0, offset: 0 });
+    });
+  });
+
+  describe("get", () => {
+    it("should return the entry as JSON", async () => {
+      await handlers.memorize({
+        id: "a",
+        text: "hello",
+        vector: { "3": 0.5 },
+      });
+
+      const entry = parseText(await handlers.get({ id: "a" }));
+      expect(entry.id).toBe("a");
+      expect(entry.metadata.text).toBe("hello");
+      expect(entry).toHaveProperty("timestamp");
+    });
+
+    it("should report a missing entry", async () => {
+      const result = await handlers.get({ id: "nope" });
+      expect(result.content[0].text).toBe("Entry not found: nope");
+    });
+
+    it("should export the vector in dense form when dims is set", async () => {
+      await handlers.memorize({ id: "a", vector: { "1": 0.5, "3": 2 } });
+
+      const entry = parseText(await handlers.get({ id: "a", dims: 5 }));
+      expect(entry.vector).toEqual([0, 0.5, 0, 2, 0]);
+    });
+
+    it("should reject dims too small for the vector", async () => {
+      await handlers.memorize({ id: "a", vector: { "7": 1 } });
+
+      await expect(handlers.get({ id: "a", dims: 4 })).rejects.toThrow(
+        "Dimension 7",
+      );
+    });
+  });
+
+  describe("search filter", () => {
+    beforeEach(async () => {
+      await handlers.memorize({
+        id: "s",
+        vector: { "0": 1 },
+        metadata: { source: "slack" },
+      });
+      await handlers.memorize({
+        id: "e",
+        vector: { "0": 1 },
+        metadata: { source: "email" },
+      });
+    });
+
+    it("should restrict results to matching metadata", async () => {
+      const body = parseText(
+        await handlers.search({
+          vector: { "0": 1 },
+          filter: { source: "slack" },
+        }),
+      );
+      expect(body.map((r: any) => r.id)).toEqual(["s"]);
+    });
+
+    it("should accept the filter as a JSON string", async () => {
+      const body = parseText(
+        await handlers.search({
+          vector: { "0": 1 },
+          filter: '{"source":"email"}',
+        }),
+      );
+      expect(body.map((r: any) => r.id)).toEqual(["e"]);
+    });
+
+    it("should restrict results to a time window", async () => {
+      const { timestamp } = parseText(await handlers.get({ id: "e" }));
+      const body = parseText(
+        await handlers.search({
+          vector: { "0": 1 },
+          before: timestamp + 1,
+          after: timestamp,
+          filter: { source: "email" },
+        }),
+      );
+      expect(body.map((r: any) => r.id)).toEqual(["e"]);
+      const later = parseText(
+        await handlers.search({ vector: { "0": 1 }, after: timestamp + 1 }),
+      );
+      expect(later).toEqual([]);
+    });
+
+    it("should reject non-scalar filter values", async () => {
+      await expect(
+        handlers.search({ vector: { "0": 1 }, filter: { source: ["slack"] } }),
+      ).rejects.toThrow("Invalid arguments for 'search'");
+    });
+  });
+
+  describe("explainTerms", () => {
+    const termsFile = "./test-tool-handlers.terms.json";
+
+    afterEach(async () => {
+      try {
+        await fs.unlink(termsFile);
+      } catch {}
+    });
+
+    it("should annotate hits with their top terms", async () => {
+      await fs.writeFile(termsFile, JSON.stringify({ "4": "sparse" }));
+      const storage = new VecFSStorage(testFilePath, { termsFile });
+      const explaining = createToolHandlers(storage);
+      await explaining.memorize({ id: "a", vector: { "4": 2, "5": 1 } });
+
+      const [hit] = parseText(
+        await explaining.search({
+          vector: { "4": 1, "5": 1 },
+          explainTerms: true,
+        }),
+      );
+      expect(hit.explanation.map((c: any) => c.dimension)).toEqual([4, 5]);
+      expect(hit.explanation[0].term).toBe("sparse");
+      expect(hit.explanation[1].term).toBeUndefined();
+    });
+
+    it("should not annotate hits unless asked", async () => {
+      await handlers.memorize({ id: "a", vector: { "4": 1 } });
+
+      const [hit] = parseText(await handlers.search({ vector: { "4": 1 } }));
+      expect(hit.explanation).toBeUndefined();
+    });
+  });
+
+  describe("referenceVector", () => {
+    it("should add each hit's cosine similarity to the reference", async () => {
+      await handlers.memorize({ id: "a", vector: { "0": 1, "1": 1 } });
+      await handlers.memorize({ id: "b", vector: { "0": 1, "2": 3 } });
+      const reference = { 2: 1 };
+
+      const hits = parseText(
+        await handlers.search({
+          vector: { "0": 1 },
+          referenceVector: JSON.stringify(reference),
+        }),
+      );
+      for (const hit of hits) {
+        expect(hit.referenceSimilarity).toBeCloseTo(
+          cosineSimilarity(hit.vector, reference),
+        );
+      }
+      expect(hits.map((h: any) => h.referenceSimilarity > 0)).toEqual([
+        false,
+        true,
+      ]);
+    });
+  });
+
+  describe("dropZero", () => {
+    it("should omit entries with no shared dimensions", async () => {
+      await handlers.memorize({ id: "near", vector: { "1": 1 } });
+      await handlers.memorize({ id: "far", vector: { "9": 1 } });
+
+      const body = parseText(
+        await handlers.search({ vector: { "1": 1 }, dropZero: true }),
+      );
+      expect(body.map((r: any) => r.id)).toEqual(["near"]);
+    });
+  });
+
+  describe("metric", () => {
+    it("should rank by raw dot product when asked", async () => {
+      await handlers.memorize({ id: "long", vector: { "1": 10 } });
+      await handlers.memorize({ id: "aligned", vector: { "1": 1, "2": 1 } });
+
+      const ids = async (metric?: string) =>
+        parseText(
+          await handlers.search({ vector: { "1": 1, "2": 1 }, metric }),
+        ).map((r: any) => r.id);
+      expect(await ids()).toEqual(["aligned", "long"]);
+      expect(await ids("dot")).toEqual(["long", "aligned"]);
+    });
+
+    it("should reject an unknown metric", async () => {
+      await expect(
+        handlers.search({ vector: { "0": 1 }, metric: "euclid" }),
+      ).rejects.toThrow("Invalid arguments for 'search'");
+    });
+
+    it("should reject a fractional or negative limit", async () => {
+      for (const limit of [2.5, -1]) {
+        await expect(
+          handlers.search({ vector: { "0": 1 }, limit }),
+        ).rejects.toThrow("Invalid arguments for 'search'");
+      }
+    });
+  });
+
+  describe("recencyTiebreak", () => {
+    it("should be passed through to the ranking", async () => {
+      await handlers.memorize({ id: "a", vector: { "0": 1 } });
+      await new Promise((resolve) => setTimeout(resolve, 5));
+      await handlers.memorize({ id: "b", vector: { "0": 1 } });
+
+      const ids = async (recencyTiebreak: boolean) =>
+        parseText(
+          await handlers.search({ vector: { "0": 1 }, recencyTiebreak }),
+        ).map((r: any) => r.id);
+      expect(await ids(false)).toEqual(["a", "b"]);
+      expect(await ids(true)).toEqual(["b", "a"]);
+    });
+  });
+
+  describe("memorize_batch", () => {
+    it("should store new and updated entries and report counts", async () => {
+      await handlers.memorize({ id: "a", text: "old", vector: { "0": 1 } });
+
+      const result = await handlers.memorize_batch({
+        entries: [
+          { id: "a", text: "new", vector: { "0": 1 } },
+          { id: "b", text: "second", vector: "[0, 1]" },
+        ],
+      });
+      expect(result.content[0].text).toBe(
+        "Stored 2 entries: 1 new, 1 updated.",
+      );
+
+      const a = parseText(await handlers.get({ id: "a" }));
+      const b = parseText(await handlers.get({ id: "b" }));
+      expect(a.metadata.text).toBe("new");
+      expect(b.vector).toEqual({ "1": 1 });
+    });
+
+    it("should reject an empty batch", async () => {
+      await expect(handlers.memorize_batch({ entries: [] })).rejects.toThrow(
+        "Invalid arguments for 'memorize_batch'",
+      );
+    });
+  });
+
+  describe("boostTags and penaltyTags", () => {
+    it("should pass tag adjustments to the ranking", async () => {
+      await handlers.memorize({
+        id: "old",
+        vector: { "0": 1 },
+        metadata: { tags: ["deprecated"] },
+      });
+      await handlers.memorize({ id: "other", vector: { "0": 1, "1": 0.3 } });
+
+      const body = parseText(
+        await handlers.search({
+          vector: { "0": 1 },
+          penaltyTags: ["deprecated"],
+        }),
+      );
+      expect(body.map((r: any) => r.id)).toEqual(["other", "old"]);
+    });
+  });
+
+  describe("count", () => {
+    it("should return the entry count as JSON", async () => {
+      expect(parseText(await handlers.count({}))).toEqual({ count: 0 });
+
+      await handlers.memorize({ id: "a", vector: { "0": 1 } });
+      await handlers.memorize({ id: "b", vector: { "1": 1 } });
+      expect(parseText(await handlers.count(undefined))).toEqual({ count: 2 });
+    });
+  });
+
+  describe("update_metadata", () => {
+    it("should merge metadata and keep the vector", async () => {
+      await handlers.memorize({
+        id: "a",
+        text: "note",
+        vector: { "0": 1 },
+        metadata: { tags: ["x"] },
+      });
+
+      const result = await handlers.update_metadata({
+        id: "a",
+        metadata: { tags: ["x", "y"] },
+      });
+      expect(result.content[0].text).toBe("Updated metadata for entry: a");
+      const entry = parseText(await handlers.get({ id: "a" }));
+      expect(entry.metadata).toEqual({ text: "note", tags: ["x", "y"] });
+      expect(entry.vector).toEqual({ "0": 1 });
+    });
+
+    it("should replace metadata when asked", async () => {
+      await handlers.memorize({ id: "a", text: "note", vector: { "0": 1 } });
+
+      await handlers.update_metadata({
+        id: "a",
+        metadata: '{"tags": ["y"]}',
+        replace: true,
+      });
+      const entry = parseText(await handlers.get({ id: "a" }));
+      expect(entry.metadata).toEqual({ tags: ["y"] });
+    });
+
+    it("should report an unknown ID", async () => {
+      const result = await handlers.update_metadata({
+        id: "missing",
+        metadata: {},
+      });
+      expect(result.content[0].text).toBe("Entry not found: missing");
+    });
+  });
+
+  describe("delete", () => {
+    it("should soft-delete by default and hard-delete on request", async () => {
+      const storage = new VecFSStorage(testFilePath, { softDeletes: true });
+      const soft = createToolHandlers(storage);
+      await soft.memorize({ id: "a", vector: { "0": 1 } });
+      await soft.memorize({ id: "b", vector: { "0": 1 } });
+
+      await soft.delete({ id: "a" });
+      await soft.delete({ id: "b", hard: true });
+
+      expect(parseText(await soft.count({}))).toEqual({ count: 0 });
+      expect(await fs.readFile(testFilePath, "utf-8")).toContain('"deletedAt"');
+      expect(await storage.purge(0)).toBe(1);
+    });
+
+    it("should reject a non-boolean hard flag", async () => {
+      await expect(handlers.delete({ id: "a", hard: "yes" })).rejects.toThrow();
+    });
+  });
+
+  describe("idempotencyKey", () => {
+    it("should not store again when a key is repeated", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      const keyed = createToolHandlers(storage);
+
+      const first = await keyed.memorize({
+        id: "first",
+        vector: { "0": 1 },
+        idempotencyKey: "retry-1",
+      });
+      const retry = await keyed.memorize({
+        id: "second",
+        vector: { "0": 1 },
+        idempotencyKey: "retry-1",
+      });
+
+      expect(retry).toEqual(first);
+      expect(await storage.count()).toBe(1);
+      expect(await storage.get("second")).toBeUndefined();
+    });
+
+    it("should store again once the key has expired", async () => {
+      let now = 0;
+      const storage = new VecFSStorage(testFilePath, { clock: () => now });
+      const keyed = createToolHandlers(storage, { idempotencyTtlMs: 1000 });
+
+      const args = { vector: { "0": 1 }, idempotencyKey: "k" };
+      await keyed.memorize({ id: "a", ...args });
+      now = 1000;
+      await keyed.memorize({ id: "b", ...args });
+
+      expect(await storage.count()).toBe(2);
+    });
+  });
+
+  describe("ttlSeconds", () => {
+    it("should set expiresAt from the TTL on memorize", async () => {
+      let now = 1_000_000;
+      const storage = new VecFSStorage(testFilePath, { clock: () => now });
+      const ttl = createToolHandlers(storage);
+      await ttl.memorize({ id: "a", vector: { "0": 1 }, ttlSeconds: 1 });
+      await ttl.memorize({ id: "b", vector: { "0": 1 } });
+
+      expect((await storage.get("a"))?.expiresAt).toBe(1_001_000);
+      expect((await storage.get("b"))?.expiresAt).toBeUndefined();
+      now += 1000;
+      expect(parseText(await ttl.count({}))).toEqual({ count: 1 });
+    });
+
+    it("should apply a TTL per entry in memorize_batch", async () => {
+      const storage = new VecFSStorage(testFilePath);
+      const ttl = createToolHandlers(storage);
+      await ttl.memorize_batch({
+        entries: [
+          { id: "a", vector: { "0": 1 }, ttlSeconds: 1 },
+          { id: "b", vector: { "0": 1 } },
+        ],
+      });
+
+      expect((await storage.get("a"))?.expiresAt).toBeDefined();
+      expect((await storage.get("b"))?.expiresAt).toBeUndefined();
+    });
+
+    it("should reject a non-positive TTL", async () => {
+      await expect(
+        handlers.memorize({ id: "a", vector: { "0": 1 }, ttlSeconds: 0 }),
+      ).rejects.toThrow();
+    });
+  });
+
+  describe("versions", () => {
+    it("should list an entry's versions newest first", async () => {
+      const storage = new VecFSStorage(testFilePath, { keepVersions: 2 });
+      const versioned = createToolHandlers(storage);
+      await versioned.memorize({ id: "a", text: "one", vector: { "0": 1 } });
+      await versioned.memorize({ id: "a", text: "two", vector: { "0": 1 } });
+
+      const versions = parseText(await versioned.versions({ id: "a" }));
+      expect(versions.map((v: any) => v.metadata.text)).toEqual([
+        "two",
+        "one",
+      ]);
+    });
+
+    it("should report an unknown ID", async () => {
+      const result = await handlers.versions({ id: "missing" });
+      expect(result.content[0].text).toBe("Entry not found: missing");
+    });
+  });
+
+  describe("argument errors", () => {
+    it("should carry the JSON-RPC invalid params code", async () => {
+      const error = await handlers.get({}).catch((e) => e);
+      expect(error).toBeInstanceOf(RpcError);
+      expect(error.code).toBe(INVALID_PARAMS_CODE);
+      expect(error.message).toContain("Invalid arguments for 'get'");
+    });
+  });
+
+  describe("namespaces", () => {
+    let dir: string;
+    let sharded: ToolHandlerMap;
+
+    beforeEach(async () => {
+      dir = await fs.mkdtemp(path.join(os.tmpdir(), "vecfs-ns-"));
+      const storage = new VecFSStorage(testFilePath);
+      const shards = new ShardedStorage(dir);
+      sharded = createToolHandlers(storage, { shards });
+    });
+
+    afterEach(async () => {
+      await fs.rm(dir, { recursive: true, force: true });
+    });
+
+    it("should keep entries in their namespace", async () => {
+      await sharded.memorize({ id: "a", vector: { "0": 1 }, namespace: "t1" });
+
+      const hits = parseText(
+        await sharded.search({ vector: { "0": 1 }, namespace: "t1" }),
+      );
+      expect(hits.map((h: any) => h.id)).toEqual(["a"]);
+      expect(parseText(await sharded.search({ vector: { "0": 1 } }))).toEqual(
+        [],
+      );
+      const missing = await sharded.get({ id: "a", namespace: "t2" });
+      expect(missing.content[0].text).toBe("Entry not found: a");
+    });
+
+    it("should search across namespaces", async () => {
+      await sharded.memorize_batch({
+        namespace: "t1",
+        entries: [{ id: "a", vector: { "0": 1 } }],
+      });
+      await sharded.memorize({ id: "b", vector: { "0": 1 }, namespace: "t2" });
+
+      const hits = parseText(
+        await sharded.search({ vector: { "0": 1 }, namespaces: "*" }),
+      );
+      expect(hits.map((h: any) => [h.namespace, h.id])).toEqual([
+        ["t1", "a"],
+        ["t2", "b"],
+      ]);
+    });
+
+    it("should rename and list within a namespace", async () => {
+      await sharded.memorize({ id: "a", vector: { "0": 1 }, namespace: "t1" });
+
+      const renamed = await sharded.rename({
+        id: "a",
+        newId: "b",
+        namespace: "t1",
+      });
+      expect(renamed.content[0].text).toBe("Renamed entry: a -> b");
+      const listed = parseText(await sharded.list({ namespace: "t1" }));
+      expect(listed.entries.map((e: any) => e.id)).toEqual(["b"]);
+      expect(parseText(await sharded.count({ namespace: "t1" }))).toEqual({
+        count: 1,
+      });
+      expect(parseText(await sharded.count({}))).toEqual({ count: 0 });
+    });
+
+    it("should keep idempotency keys apart per namespace", async () => {
+      const args = { id: "a", vector: { "0": 1 }, idempotencyKey: "k" };
+      await sharded.memorize({ ...args, namespace: "t1" });
+      await sharded.memorize({ ...args, namespace: "t2" });
+      await sharded.memorize(args);
+
+      for (const namespace of ["t1", "t2", undefined]) {
+        const found = await sharded.get({ id: "a", namespace });
+        expect(found.content[0].text).not.toBe("Entry not found: a");
+      }
+    });
+
+    it("should reject a namespace when sharding is off", async () => {
+      const error = await handlers
+        .memorize({ id: "a", vector: { "0": 1 }, namespace: "t1" })
+        .catch((e) => e);
+      expect(error).toBeInstanceOf(RpcError);
+      expect(error.message).toContain("VECFS_SHARD_DIR");
+    });
+  });
+});
@@ -20,6 +20,13 @@ const vectorSchema = {
   ],
 };
 
+const namespaceSchema = {
+  type: "string",
+  pattern: "^[A-Za-z0-9][A-Za-z0-9_-]*$",
+  description:
+    "Namespace whose store to use, when the server has VECFS_SHARD_DIR set. Omit for the default store.",
+};
+
 /**
  * The complete list of tools exposed by the VecFS MCP server.
  * Each entry follows the MCP tool definition format.
@@ -37,6 +44,94 @@ export const toolDefinitions = [
           description: "Maximum number of results to return.",
           default: 5,
         },
+        offset: {
+          type: "number",
+          description:
+            "Number of ranked results to skip, for paging. When set, the response also reports the total number of matches.",
+        },
+        boostField: {
+          type: "string",
+          description:
+            "Numeric metadata field whose weighted value is added to the rank.",
+        },
+        boostWeight: {
+          type: "number",
+          description: "Multiplier for the boost field value.",
+          default: 0.1,
+        },
+        facet: {
+          type: "string",
+          description:
+            "Metadata field to count values of across all scored entries.",
+        },
+        filter: {
+          type: "object",
+          description:
+            "Metadata key/value pairs an entry must match to be searched.",
+          additionalProperties: {
+            type: ["string", "number", "boolean"],
+          },
+        },
+        after: {
+          type: "number",
+          description:
+            "Only search entries stored at or after this time (epoch ms).",
+        },
+        before: {
+          type: "number",
+          description:
+            "Only search entries stored before this time (epoch ms).",
+        },
+        explainTerms: {
+          type: "boolean",
+          description:
+            "Annotate each hit with the dimensions (and terms) that contributed most to its similarity.",
+          default: false,
+        },
+        dropZero: {
+          type: "boolean",
+          description:
+            "Never return entries that share no dimensions with the query (zero similarity).",
+          default: false,
+        },
+        metric: {
+          type: "string",
+          description:
+            "Similarity metric: 'cosine', 'dot', 'euclidean' or a custom metric the server registers. 'dot' skips norm computations and ranks like 'cosine' only when all vectors are normalised, as vecfs-embed produces.",
+          default: "cosine",
+        },
+        recencyTiebreak: {
+          type: "boolean",
+          description:
+            "When results have near-equal rank, list the most recently memorized first.",
+          default: false,
+        },
+        boostTags: {
+          type: "array",
+          items: { type: "string" },
+          description:
+            "Raise entries with any of these tags, by 0.1 per tag.",
+        },
+        penaltyTags: {
+          type: "array",
+          items: { type: "string" },
+          description:
+            "Lower entries with any of these tags, by 0.1 per tag.",
+        },
+        referenceVector: {
+          ...vectorSchema,
+          description:
+            "Also report each hit's cosine similarity to this vector as referenceSimilarity, without changing the ranking.",
+        },
+        namespace: namespaceSchema,
+        namespaces: {
+          oneOf: [
+            { type: "array", items: { type: "string" }, minItems: 1 },
+            { type: "string", enum: ["*"] },
+          ],
+          description:
+            'Search these namespaces together and merge the results, each tagged with its namespace. "*" searches every namespace. Cannot be combined with namespace, facet or offset.',
+        },
       },
       required: ["vector"],
     },
@@ -52,10 +147,48 @@ export const toolDefinitions = [
         text: { type: "string" },
         vector: vectorSchema,
         metadata: { type: "object" },
+        ttlSeconds: {
+          type: "number",
+          description:
+            "Seconds until the entry expires and stops appearing in results.",
+        },
+        namespace: namespaceSchema,
+        idempotencyKey: {
+          type: "string",
+          description:
+            "Unique key for this call. A retry with the same key within ten minutes returns the first call's result without storing again.",
+        },
       },
       required: ["id", "vector"],
     },
   },
+  {
+    name: "memorize_batch",
+    description:
+      "Store many entries in one call with a single write. Each entry is stored as by memorize, updating any existing ID.",
+    inputSchema: {
+      type: "object",
+      properties: {
+        entries: {
+          type: "array",
+          minItems: 1,
+          items: {
+            type: "object",
+            properties: {
+              id: { type: "string" },
+              text: { type: "string" },
+              vector: vectorSchema,
+              metadata: { type: "object" },
+              ttlSeconds: { type: "number" },
+            },
+            required: ["id", "vector"],
+          },
+        },
+        namespace: namespaceSchema,
+      },
+      required: ["entries"],
+    },
+  },
   {
     name: "feedback",
     description: "Record feedback for a specific memory entry.",
@@ -64,10 +197,38 @@ export const toolDefinitions = [
       properties: {
         id: { type: "string" },
         scoreAdjustment: { type: "number" },
+        namespace: namespaceSchema,
       },
       required: ["id", "scoreAdjustment"],
     },
   },
+  {
+    name: "update_metadata",
+    description:
+      "Change an entry's metadata without re-embedding. The vector and feedback score are kept.",
+    inputSchema: {
+      type: "object",
+      properties: {
+        id: {
+          type: "string",
+          description: "The ID of the entry to update.",
+        },
+        metadata: {
+          type: "object",
+          description:
+            "Keys to set. They are merged over the existing metadata unless replace is true.",
+        },
+        replace: {
+          type: "boolean",
+          description:
+            "Use metadata as the entry's whole metadata, dropping keys it does not list.",
+          default: false,
+        },
+        namespace: namespaceSchema,
+      },
+      required: ["id", "metadata"],
+    },
+  },
   {
     name: "delete",
     description: "Delete an entry from the vector space by its unique ID.",
@@ -78,8 +239,163 @@ export const toolDefinitions = [
           type: "string",
           description: "The unique identifier of the entry to delete.",
         },
+        hard: {
+          type: "boolean",
+          description:
+            "Remove the entry permanently, even when the server is configured to soft-delete.",
+          default: false,
+        },
+        namespace: namespaceSchema,
+      },
+      required: ["id"],
+    },
+  },
+  {
+    name: "rename",
+    description:
+      "Change the ID of an entry without re-embedding. Fails if the new ID is already in use.",
+    inputSchema: {
+      type: "object",
+      properties: {
+        id: {
+          type: "string",
+          description: "The current ID of the entry.",
+        },
+        newId: {
+          type: "string",
+          description: "The ID to move the entry to.",
+        },
+        namespace: namespaceSchema,
+      },
+      required: ["id", "newId"],
+    },
+  },
+  {
+    name: "metadata_keys",
+    description:
+      "List the metadata keys present across all entries with their value types and occurrence counts.",
+    inputSchema: {
+      type: "object",
+      properties: {
+        namespace: namespaceSchema,
+      },
+    },
+  },
+  {
+    name: "count",
+    description: "Return the number of stored entries as {\"count\": n}.",
+    inputSchema: {
+      type: "object",
+      properties: {
+        namespace: namespaceSchema,
+      },
+    },
+  },
+  {
+    name: "get",
+    description:
+      "Fetch a single entry by its exact ID, including its vector, metadata, score and timestamp.",
+    inputSchema: {
+      type: "object",
+      properties: {
+        id: {
+          type: "string",
+          description: "The ID of the entry to fetch.",
+        },
+        dims: {
+          type: "number",
+          description:
+            "Return the vector as a dense array of this many dimensions, with zeros where the sparse vector has none.",
+        },
+        namespace: namespaceSchema,
+      },
+      required: ["id"],
+    },
+  },
+  {
+    name: "versions",
+    description:
+      "List the versions of an entry, newest first: the current entry and the earlier versions the server keeps when configured to.",
+    inputSchema: {
+      type: "object",
+      properties: {
+        id: {
+          type: "string",
+          description: "The ID of the entry.",
+        },
+        namespace: namespaceSchema,
       },
       required: ["id"],
     },
   },
+  {
+    name: "list",
+    description:
+      "List stored entries without a query, most recently modified first. Returns one page of entries and the total count for pagination.",
+    inputSchema: {
+      type: "object",
+      properties: {
+        offset: {
+          type: "number",
+          description: "Number of entries to skip.",
+          default: 0,
+        },
+        limit: {
+          type: "number",
+          description: "Maximum entries to return (at most 100).",
+          default: 20,
+        },
+        namespace: namespaceSchema,
+      },
+    },
+  },
+  {
+    name: "similarity_histogram",
+    description:
+      "Count how the similarities of every stored entry to a query vector are distributed, to help choose a similarity threshold.",
+    inputSchema: {
+      type: "object",
+      properties: {
+        vector: vectorSchema,
+        buckets: {
+          type: "number",
+          description: "Number of equal-width buckets between 0 and 1.",
+          default: 10,
+        },
+        namespace: namespaceSchema,
+      },
+      required: ["vector"],
+    },
+  },
+  {
+    name: "centroid",
+    description:
+      "Average the vectors of a group of entries into one representative vector and return it with the stored entries nearest to it.",
+    inputSchema: {
+      type: "object",
+      properties: {
+        ids: {
+          type: "array",
+          items: { type: "string" },
+          minItems: 1,
+          description:
+            "IDs of the entries to average. A repeated ID counts once.",
+        },
+        filter: {
+          type: "object",
+          description:
+            "Average every entry whose metadata matches these key/value pairs, instead of listing IDs.",
+          additionalProperties: {
+            type: ["string", "number", "boolean"],
+          },
+        },
+        limit: {
+          type: "number",
+          description: "Maximum number of nearest entries to return.",
+          default: 5,
+        },
+        namespace: namespaceSchema,
+      },
+    },
+  },
 ];
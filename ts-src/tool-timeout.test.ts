@@ -0,0 +1,103 @@
+import { describe, it, expect, beforeEach, afterEach } from "vitest";
+import * as fs from "fs/promises";
+import { VecFSStorage } from "./storage.js";
+import { createToolHandlers } from "./tool-handlers.js";
+import {
+  callWithTimeout,
+  ToolTimeoutError,
+  REQUEST_TIMEOUT_CODE,
+} from "./tool-timeout.js";
+import { SearchOptions, SearchResult, SparseVector } from "./types.js";
+
+/** Storage whose searches stall, standing in for a slow backend. */
+class SlowStorage extends VecFSStorage {
+  async search(
+    queryVector: SparseVector,
+    limit?: number,
+    options?: SearchOptions,
+  ): Promise<SearchResult[]> {
+    await new Promise((resolve) => setTimeout(resolve, 200));
+    return super.search(queryVector, limit, options);
+  }
+}
+
+describe("callWithTimeout", () => {
+  const testFilePath = "./test-tool-timeout.jsonl";
+
+  beforeEach(async () => {
+    try {
+      await fs.unlink(testFilePath);
+    } catch {}
+  });
+
+  afterEach(async () => {
+    try {
+      await fs.unlink(testFilePath);
+    } catch {}
+  });
+
+  it("should report the stage and elapsed time on timeout", async () => {
+    const storage = new SlowStorage(testFilePath);
+    await storage.ensureFile();
+    const handlers = createToolHandlers(storage);
+
+    const call = callWithTimeout("search", 20, (ctx) =>
+      handlers.search({ vector: { "0": 1 } }, ctx),
+    );
+
+    const error = await call.catch((e) => e);
+    expect(error).toBeInstanceOf(ToolTimeoutError);
+    expect(error.code).toBe(REQUEST_TIMEOUT_CODE);
+    expect(error.data.tool).toBe("search");
+    expect(error.data.stage).toBe("storage");
+    // Timers can fire up to a millisecond early as measured by Date.now.
+    expect(error.data.elapsedMs).toBeGreaterThanOrEqual(19);
+  });
+
+  it("should abort the context's signal with the timeout error", async () => {
+    let signal: AbortSignal | undefined;
+    const error = await callWithTimeout("noop", 10, (ctx) => {
+      signal = ctx.signal;
+      return new Promise<never>(() => {});
+    }).catch((e) => e);
+
+    expect(signal?.aborted).toBe(true);
+    expect(signal?.reason).toBe(error);
+  });
+
+  it("should not start the next stage once timed out", async () => {
+    const storage = new VecFSStorage(testFilePath);
+    await storage.ensureFile();
+    const handlers = createToolHandlers(storage);
+    const controller = new AbortController();
+    controller.abort(new Error("deadline passed"));
+
+    const call = handlers.memorize(
+      { id: "late", vector: { "0": 1 } },
+      { stage: "validate", signal: controller.signal },
+    );
+
+    await expect(call).rejects.toThrow("deadline passed");
+    expect(await storage.get("late")).toBeUndefined();
+  });
+
+  it("should return the result when the call finishes in time", async () => {
+    const storage = new VecFSStorage(testFilePath);
+    await storage.ensureFile();
+    const handlers = createToolHandlers(storage);
+
+    const result = await callWithTimeout("search", 1000, (ctx) =>
+      handlers.search({ vector: { "0": 1 } }, ctx),
+    );
+    expect(JSON.parse(result.content[0].text)).toEqual([]);
+  });
+
+  it("should not time out without a deadline", async () => {
+    const result = await callWithTimeout(
+      "noop",
+      undefined,
+      async (ctx) => ctx.stage,
+    );
+    expect(result).toBe("validate");
+  });
+});
@@ -0,0 +1,90 @@
+/**
+ * JSON-RPC error code for a timed-out request. Matches the MCP SDK's
+ * `ErrorCode.RequestTimeout`.
+ */
+export const REQUEST_TIMEOUT_CODE = -32001;
+
+/**
+ * Mutable progress marker for one tool call. Handlers set `stage` as they
+ * move between phases (`validate`, `storage`, `render`) so that a timeout
+ * can report where the time went.
+ */
+export interface ToolCallContext {
+  stage: string;
+  /**
+   * Aborted, with the {@link ToolTimeoutError} as its reason, when the
+   * call's deadline passes. Handlers check it on entering each stage so a
+   * timed-out call does not go on to start work nobody will see.
+   */
+  signal?: AbortSignal;
+}
+
+/** Diagnostic payload attached to a {@link ToolTimeoutError}. */
+export interface ToolTimeoutData {
+  /** Name of the tool that timed out. */
+  tool: string;
+  /** Stage the handler had reached when the deadline passed. */
+  stage: string;
+  /** Milliseconds between the start of the call and the timeout. */
+  elapsedMs: number;
+}
+
+/**
+ * Raised when a tool call exceeds its deadline. The MCP SDK copies `code`
+ * and `data` from a thrown error into the JSON-RPC error response.
+ */
+export class ToolTimeoutError extends Error {
+  readonly code = REQUEST_TIMEOUT_CODE;
+  readonly data: ToolTimeoutData;
+
+  constructor(data: ToolTimeoutData, timeoutMs: number) {
+    super(
+      `Tool '${data.tool}' timed out after ${timeoutMs} ms ` +
+        `during the ${data.stage} stage.`,
+    );
+    this.name = "ToolTimeoutError";
+    this.data = data;
+  }
+}
+
+/**
+ * Runs a tool call with a deadline.
+ *
+ * On timeout the context's signal is aborted, so the call stops before
+ * its next stage. Work already in flight (such as a file write) still
+ * completes; only the response is abandoned.
+ *
+ * @param tool - Tool name, reported in the error.
+ * @param timeoutMs - Deadline in milliseconds, or undefined for none.
+ * @param run - Performs the call, updating the context's stage as it goes.
+ * @throws ToolTimeoutError if the deadline passes first.
+ */
+export async function callWithTimeout<T>(
+  tool: string,
+  timeoutMs: number | undefined,
+  run: (ctx: ToolCallContext) => Promise<T>,
+): Promise<T> {
+  const ctx: ToolCallContext = { stage: "validate" };
+  if (timeoutMs === undefined) return run(ctx);
+
+  const started = Date.now();
+  const controller = new AbortController();
+  ctx.signal = controller.signal;
+  let timer: NodeJS.Timeout | undefined;
+  const deadline = new Promise<never>((_, reject) => {
+    timer = setTimeout(() => {
+      const elapsedMs = Date.now() - started;
+      const error = new ToolTimeoutError(
+        { tool, stage: ctx.stage, elapsedMs },
+        timeoutMs,
+      );
+      controller.abort(error);
+      reject(error);
+    }, timeoutMs);
+  });
+  try {
+    return await Promise.race([run(ctx), deadline]);
+  } finally {
+    clearTimeout(timer);
+  }
+}
@@ -0,0 +1,21 @@
+import { bench, describe } from "vitest";
+import { topK } from "./top-k.js";
+import { seededRandom } from "./seeded-random.js";
+
+describe("pick 5 of 50k ranked results", () => {
+  const random = seededRandom(42);
+  const items = Array.from({ length: 50_000 }, (_, i) => ({
+    id: `entry-${i}`,
+    rank: random(),
+  }));
+  const byRankThenId = (a: (typeof items)[0], b: (typeof items)[0]) =>
+    b.rank - a.rank || a.id.localeCompare(b.id);
+
+  bench("full sort", () => {
+    [...items].sort(byRankThenId).slice(0, 5);
+  });
+
+  bench("bounded heap", () => {
+    topK(items, 5, byRankThenId);
+  });
+});
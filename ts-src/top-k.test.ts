@@ -0,0 +1,39 @@
+import { describe, it, expect } from "vitest";
+import { topK } from "./top-k.js";
+import { seededRandom } from "./seeded-random.js";
+
+describe("topK", () => {
+  const byValueThenId = (
+    a: { id: number; value: number },
+    b: { id: number; value: number },
+  ) => b.value - a.value || a.id - b.id;
+
+  it("should match a full sort for several random seeds", () => {
+    for (const seed of [1, 2, 3, 42, 1234]) {
+      const random = seededRandom(seed);
+      // Coarse values so ties are common and the tiebreak is exercised.
+      const items = Array.from({ length: 500 }, (_, id) => ({
+        id,
+        value: Math.floor(random() * 20),
+      }));
+      const naive = [...items].sort(byValueThenId);
+      for (const k of [1, 5, 37, 500, 600]) {
+        expect(topK(items, k, byValueThenId)).toEqual(naive.slice(0, k));
+      }
+    }
+  });
+
+  it("should return nothing for a non-positive k", () => {
+    expect(topK([3, 1, 2], 0, (a, b) => b - a)).toEqual([]);
+  });
+
+  it("should round a fractional k down", () => {
+    expect(topK([3, 1, 2], 2.5, (a, b) => b - a)).toEqual([3, 2]);
+  });
+
+  it("should not reorder the input", () => {
+    const items = [3, 1, 2];
+    topK(items, 2, (a, b) => b - a);
+    expect(items).toEqual([3, 1, 2]);
+  });
+});
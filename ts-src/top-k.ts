@@ -0,0 +1,57 @@
+/**
+ * Returns the `k` best items in order, where `compare(a, b) < 0` means `a`
+ * ranks ahead of `b`. Keeps a bounded heap of the current best `k` rather
+ * than sorting every item, so picking a few results from a large store
+ * costs O(n log k) instead of O(n log n).
+ *
+ * The result matches `[...items].sort(compare).slice(0, k)` whenever
+ * `compare` is a total order, as it is for ranks tie-broken by id. A
+ * fractional `k` is rounded down.
+ */
+export function topK<T>(
+  items: Iterable<T>,
+  k: number,
+  compare: (a: T, b: T) => number,
+): T[] {
+  k = Math.floor(k);
+  if (k <= 0) return [];
+  // Max-heap by `compare`: the root is the worst of the kept items.
+  const heap: T[] = [];
+  const worse = (i: number, j: number) => compare(heap[i], heap[j]) > 0;
+  const swap = (i: number, j: number) => {
+    [heap[i], heap[j]] = [heap[j], heap[i]];
+  };
+
+  const siftUp = (i: number) => {
+    while (i > 0) {
+      const parent = (i - 1) >> 1;
+      if (!worse(i, parent)) return;
+      swap(i, parent);
+      i = parent;
+    }
+  };
+
+  const siftDown = (i: number) => {
+    for (;;) {
+      const left = 2 * i + 1;
+      const right = left + 1;
+      let largest = i;
+      if (left < heap.length && worse(left, largest)) largest = left;
+      if (right < heap.length && worse(right, largest)) largest = right;
+      if (largest === i) return;
+      swap(i, largest);
+      i = largest;
+    }
+  };
+
+  for (const item of items) {
+    if (heap.length < k) {
+      heap.push(item);
+      siftUp(heap.length - 1);
+    } else if (compare(item, heap[0]) < 0) {
+      heap[0] = item;
+      siftDown(0);
+    }
+  }
+  return heap.sort(compare);
+}
@@ -20,6 +20,32 @@ export interface VecFSEntry {
   score: number;
   /** Timestamp when the entry was created or last modified (milliseconds since epoch). */
   timestamp: number;
+  /**
+   * When the entry was soft-deleted (milliseconds since epoch). Soft-deleted
+   * entries stay in the file for auditing but are hidden from every read.
+   * A plain `deleted` flag is not used because it marks a {@link Tombstone}.
+   */
+  deletedAt?: number;
+  /**
+   * When the entry expires (milliseconds since epoch). Expired entries are
+   * hidden from reads and removed by a sweep.
+   */
+  expiresAt?: number;
+  /** On an earlier version kept by `keepVersions`, the ID of its entry. */
+  versionOf?: string;
+  /** On an earlier version, its number, counting from 1 for the oldest. */
+  version?: number;
+}
+
+/**
+ * A log record marking an entry as deleted.
+ * Written instead of rewriting the file when deletes are appended.
+ */
+export interface Tombstone {
+  /** Identifier of the deleted entry. */
+  id: string;
+  /** Always true; distinguishes a tombstone from an entry. */
+  deleted: true;
 }
 
 /**
@@ -30,3 +56,79 @@ export interface SearchResult extends VecFSEntry {
   /** Cosine similarity score between the query vector and this entry (0 to 1). */
   similarity: number;
 }
+
+/**
+ * Metadata equality constraints: an entry matches when every key holds the
+ * given value. A metadata array matches when it contains the value.
+ */
+export type MetadataFilter = Record<string, string | number | boolean>;
+
+/**
+ * How a query is compared with stored vectors: the name of a registered
+ * similarity function. `cosine`, `dot` and `euclidean` are built in; `dot`
+ * skips the norm computations and equals `cosine` only for unit-length
+ * vectors.
+ */
+export type SimilarityMetric = string;
+
+/**
+ * Optional controls applied by the query engine when ranking a search.
+ */
+export interface SearchOptions {
+  /** Numeric metadata field whose weighted value is added to the combined rank. */
+  boostField?: string;
+  /** Multiplier applied to the boost field value. Defaults to 0.1. */
+  boostWeight?: number;
+  /** Only entries whose metadata matches are ranked. */
+  filter?: MetadataFilter;
+  /** Only entries with a timestamp at or after this epoch-ms time rank. */
+  after?: number;
+  /** Only entries with a timestamp before this epoch-ms time are ranked. */
+  before?: number;
+  /** Leave out entries with zero similarity (no shared dimensions). */
+  dropZero?: boolean;
+  /** Similarity metric; defaults to the storage's metric, then `cosine`. */
+  metric?: SimilarityMetric;
+  /** Among results with near-equal rank, put the newest first. */
+  recencyTiebreak?: boolean;
+  /** Tags that raise an entry's rank when its `tags` metadata has them. */
+  boostTags?: string[];
+  /** Tags that lower an entry's rank when its `tags` metadata has them. */
+  penaltyTags?: string[];
+}
+
+/**
+ * One page of ranked search results, with the number of entries that
+ * matched the search so callers can page through them.
+ */
+export interface SearchPage {
+  /** Results on this page, in ranked order. */
+  results: SearchResult[];
+  /** Total number of entries the search ranked. */
+  total: number;
+}
+
+/**
+ * One page of entries returned by a listing, with the size of the whole store
+ * so callers can paginate.
+ */
+export interface EntryPage {
+  /** Entries on this page, most recently modified first. */
+  entries: VecFSEntry[];
+  /** Total number of entries in the store. */
+  total: number;
+}
+
+/**
+ * Turns texts into sparse vectors, one per text and in the same order,
+ * such as by running an embedding model.
+ */
+export type Embedder = (texts: string[]) => Promise<SparseVector[]>;
+
+/** What a reindex did, for reporting. */
+export interface ReindexCounts {
+  /** Entries whose vector was recomputed from their stored text. */
+  reindexed: number;
+  /** Entries left as they were because they have no stored text. */
+  skipped: number;
+}
@@ -0,0 +1,125 @@
+import { z } from "zod";
+import { ToolCallContext } from "./tool-timeout.js";
+import {
+  ToolContext,
+  ToolHandlerMap,
+  ToolResult,
+  enterStage,
+} from "./tool-context.js";
+import { namespaceSchema, validateArgs } from "./tool-args.js";
+
+const feedbackArgsSchema = z.object({
+  id: z.string(),
+  scoreAdjustment: z.number(),
+  namespace: namespaceSchema.optional(),
+});
+
+const updateMetadataArgsSchema = z.object({
+  id: z.string(),
+  metadata: z.preprocess(
+    (v) => (typeof v === "string" ? JSON.parse(v) : v),
+    z.record(z.string(), z.unknown()),
+  ),
+  replace: z.boolean().optional(),
+  namespace: namespaceSchema.optional(),
+});
+
+const deleteArgsSchema = z.object({
+  id: z.string(),
+  hard: z.boolean().optional(),
+  namespace: namespaceSchema.optional(),
+});
+
+const renameArgsSchema = z.object({
+  id: z.string(),
+  newId: z.string(),
+  namespace: namespaceSchema.optional(),
+});
+
+/**
+ * Builds the tools that change or remove one stored entry: `feedback`,
+ * `update_metadata`, `delete` and `rename`.
+ */
+export function updateTools(context: ToolContext): ToolHandlerMap {
+  const { storageFor } = context;
+  return {
+    async feedback(args: unknown, ctx?: ToolCallContext): Promise<ToolResult> {
+      const { id, scoreAdjustment, namespace } = validateArgs(
+        feedbackArgsSchema,
+        args,
+        "feedback",
+      );
+      enterStage(ctx, "storage");
+      const store = await storageFor(namespace);
+      const found = await store.updateScore(id, scoreAdjustment);
+      if (!found) {
+        return {
+          content: [{ type: "text", text: `Entry not found: ${id}` }],
+        };
+      }
+      return {
+        content: [{ type: "text", text: `Updated score for entry: ${id}` }],
+      };
+    },
+
+    async update_metadata(
+      args: unknown,
+      ctx?: ToolCallContext,
+    ): Promise<ToolResult> {
+      const { id, metadata, replace, namespace } = validateArgs(
+        updateMetadataArgsSchema,
+        args,
+        "update_metadata",
+      );
+      enterStage(ctx, "storage");
+      const store = await storageFor(namespace);
+      const found = await store.updateMetadata(id, metadata, replace);
+      if (!found) {
+        return {
+          content: [{ type: "text", text: `Entry not found: ${id}` }],
+        };
+      }
+      return {
+        content: [{ type: "text", text: `Updated metadata for entry: ${id}` }],
+      };
+    },
+
+    async delete(args: unknown, ctx?: ToolCallContext): Promise<ToolResult> {
+      const { id, hard, namespace } = validateArgs(
+        deleteArgsSchema,
+        args,
+        "delete",
+      );
+      enterStage(ctx, "storage");
+      const store = await storageFor(namespace);
+      const found = await store.delete(id, { hard });
+      if (!found) {
+        return {
+          content: [{ type: "text", text: `Entry not found: ${id}` }],
+        };
+      }
+      return {
+        content: [{ type: "text", text: `Deleted entry: ${id}` }],
+      };
+    },
+
+    async rename(args: unknown, ctx?: ToolCallContext): Promise<ToolResult> {
+      const { id, newId, namespace } = validateArgs(
+        renameArgsSchema,
+        args,
+        "rename",
+      );
+      enterStage(ctx, "storage");
+      const store = await storageFor(namespace);
+      const found = await store.rename(id, newId);
+      if (!found) {
+        return {
+          content: [{ type: "text", text: `Entry not found: ${id}` }],
+        };
+      }
+      return {
+        content: [{ type: "text", text: `Renamed entry: ${id} -> ${newId}` }],
+      };
+    },
+  };
+}
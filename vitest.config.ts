@@ -5,6 +5,7 @@ export default defineConfig({
     globals: true,
     environment: "node",
     include: ["ts-src/**/*.test.ts"],
+    benchmark: { include: ["ts-src/**/*.bench.ts"] },
     // Allow process spawning
     isolate: false,
   },